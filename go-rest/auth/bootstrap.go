@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/manish-npx/go-lang/go-rest/models"
+	"github.com/manish-npx/go-lang/go-rest/repository"
+)
+
+// EnsureAdmin makes sure the user with email exists, is an admin and logs
+// in with password. Missing users are created; existing ones are promoted
+// and get the new password. A deleted user keeps its email, so one
+// with email is restored rather than left to block the admin account.
+func EnsureAdmin(ctx context.Context, users repository.UserRepository, email, password string) error {
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return fmt.Errorf("admin password must be between %d and %d bytes", MinPasswordLength, MaxPasswordLength)
+	}
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	user, err := users.GetByEmail(ctx, email)
+	if errors.Is(err, repository.ErrNotFound) {
+		user, err = restoreByEmail(ctx, users, email)
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		admin := models.User{Name: "Admin", Email: email, Role: models.RoleAdmin, PasswordHash: hash}
+		if errs := admin.Validate(); len(errs) > 0 {
+			return fmt.Errorf("admin account: %s %s", errs[0].Field, errs[0].Message)
+		}
+		return users.Create(ctx, &admin)
+	}
+	if err != nil {
+		return err
+	}
+
+	user.Role = models.RoleAdmin
+	user.PasswordHash = hash
+	return users.Update(ctx, &user)
+}
+
+// restoreByEmail restores the deleted user with email and returns it
+func restoreByEmail(ctx context.Context, users repository.UserRepository, email string) (models.User, error) {
+	deleted, err := users.GetDeletedByEmail(ctx, email)
+	if err != nil {
+		return models.User{}, err
+	}
+	if err := users.Restore(ctx, deleted.ID); err != nil {
+		return models.User{}, err
+	}
+	return users.Get(ctx, deleted.ID)
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/manish-npx/go-lang/go-rest/models"
+	"github.com/manish-npx/go-lang/go-rest/repository"
+)
+
+func TestEnsureAdmin(t *testing.T) {
+	sqlite, err := repository.OpenSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlite.Close()
+
+	for name, users := range map[string]repository.UserRepository{
+		"memory": repository.NewMemoryStore().Users,
+		"sqlite": sqlite.Users,
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			carol := models.User{Name: "Carol", Email: "carol@example.com", Role: models.RoleReader}
+			dan := models.User{Name: "Dan", Email: "dan@example.com", Role: models.RoleEditor}
+			for _, u := range []*models.User{&carol, &dan} {
+				if err := users.Create(ctx, u); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := users.Delete(ctx, dan.ID, dan.Version); err != nil {
+				t.Fatal(err)
+			}
+
+			tests := []struct {
+				name  string
+				email string
+				// id is the user that must end up admin, 0 for a new one
+				id int
+			}{
+				{"missing user is created", "root@example.com", 0},
+				{"existing user is promoted", "CAROL@example.com", carol.ID},
+				{"deleted user is restored", "dan@example.com", dan.ID},
+				{"again, nothing changes", "dan@example.com", dan.ID},
+			}
+			for _, tt := range tests {
+				if err := EnsureAdmin(ctx, users, tt.email, "admin password"); err != nil {
+					t.Fatalf("%s: %v", tt.name, err)
+				}
+				admin, err := users.GetByEmail(ctx, tt.email)
+				if err != nil || admin.Role != models.RoleAdmin || !CheckPassword(admin.PasswordHash, "admin password") ||
+					(tt.id != 0 && admin.ID != tt.id) {
+					t.Fatalf("%s: admin %+v, %v", tt.name, admin, err)
+				}
+			}
+
+			if err := EnsureAdmin(ctx, users, "carol@example.com", "short"); err == nil {
+				t.Fatal("a short admin password was accepted")
+			}
+			if err := EnsureAdmin(ctx, users, "not an email", "admin password"); err == nil {
+				t.Fatal("an invalid admin email was accepted")
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/manish-npx/go-lang/go-rest/apierror"
+	"github.com/manish-npx/go-lang/go-rest/models"
+	"github.com/manish-npx/go-lang/go-rest/repository"
+)
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx that carries the authenticated user
+func NewContext(ctx context.Context, user models.User) context.Context {
+	return context.WithValue(ctx, contextKey{}, user)
+}
+
+// UserFromContext returns the authenticated user, if the request had a valid token
+func UserFromContext(ctx context.Context) (models.User, bool) {
+	user, ok := ctx.Value(contextKey{}).(models.User)
+	return user, ok
+}
+
+// Caller returns the authenticated user, or nil for anonymous requests.
+// This is the form the policy package expects.
+func Caller(ctx context.Context) *models.User {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return &user
+}
+
+// Authenticate reads an optional "Authorization: Bearer <access token>"
+// header. A valid token puts its user in the request context; a missing one
+// lets the request through anonymously; a bad one is rejected with 401.
+func Authenticate(tokens *Tokens, users repository.UserRepository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			if header == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token, ok := strings.CutPrefix(header, "Bearer ")
+			if !ok {
+				unauthorized(w, r, "Authorization header must be \"Bearer <token>\"")
+				return
+			}
+
+			userID, err := tokens.Verify(strings.TrimSpace(token), TypeAccess)
+			if err != nil {
+				unauthorized(w, r, "Invalid or expired access token")
+				return
+			}
+
+			// Load the user so deleted users cannot keep using old tokens
+			user, err := users.Get(r.Context(), userID)
+			if errors.Is(err, repository.ErrNotFound) {
+				unauthorized(w, r, "Invalid or expired access token")
+				return
+			}
+			if err != nil {
+				apierror.Write(w, r, apierror.Internal())
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), user)))
+		})
+	}
+}
+
+// RequireUser only lets requests with an authenticated user reach next
+func RequireUser(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := UserFromContext(r.Context()); !ok {
+			unauthorized(w, r, "Authentication required")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// unauthorized writes a 401 that tells the client how to authenticate
+func unauthorized(w http.ResponseWriter, r *http.Request, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="go-rest"`)
+	apierror.Write(w, r, apierror.Unauthorized(message))
+}
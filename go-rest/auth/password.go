@@ -0,0 +1,28 @@
+package auth
+
+import "golang.org/x/crypto/bcrypt"
+
+// MinPasswordLength is the shortest password accepted at registration.
+// bcrypt only looks at the first 72 bytes, so that is the longest.
+const (
+	MinPasswordLength = 8
+	MaxPasswordLength = 72
+)
+
+// HashPassword returns the bcrypt hash of password
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether password matches hash.
+// An empty hash (a user created without a password) never matches.
+func CheckPassword(hash, password string) bool {
+	if hash == "" {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,115 @@
+// Package auth handles passwords, JWT access and refresh tokens and the
+// middleware that tells handlers who is calling.
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Token lifetimes. Access tokens are short-lived; refresh tokens only
+// serve to get a new pair from POST /auth/refresh.
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 7 * 24 * time.Hour
+)
+
+// Token types, stored in the "typ" claim so one kind cannot be used as the other
+const (
+	TypeAccess  = "access"
+	TypeRefresh = "refresh"
+)
+
+const issuer = "go-rest"
+
+// ErrInvalidToken is returned for any token that is malformed, expired,
+// badly signed or of the wrong type
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// claims is the payload of our JWTs; the user ID is the subject
+type claims struct {
+	Type string `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// TokenPair is what login and refresh answer with
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	// ExpiresIn is the access token lifetime in seconds
+	ExpiresIn int `json:"expires_in"`
+}
+
+// Tokens issues and verifies HS256-signed JWTs
+type Tokens struct {
+	secret []byte
+	now    func() time.Time
+}
+
+// NewTokens returns a Tokens signing with secret
+func NewTokens(secret []byte) *Tokens {
+	return &Tokens{secret: secret, now: time.Now}
+}
+
+// Issue returns a new access and refresh token for the user
+func (t *Tokens) Issue(userID int) (TokenPair, error) {
+	access, err := t.sign(userID, TypeAccess, AccessTokenTTL)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	refresh, err := t.sign(userID, TypeRefresh, RefreshTokenTTL)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// Verify checks a token of the given type and returns the user ID in it
+func (t *Tokens) Verify(token, wantType string) (int, error) {
+	var c claims
+	_, err := jwt.ParseWithClaims(token, &c, func(*jwt.Token) (any, error) {
+		return t.secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(t.now),
+	)
+	if err != nil || c.Type != wantType {
+		return 0, ErrInvalidToken
+	}
+
+	userID, err := strconv.Atoi(c.Subject)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	return userID, nil
+}
+
+func (t *Tokens) sign(userID int, typ string, ttl time.Duration) (string, error) {
+	now := t.now()
+	c := claims{
+		Type: typ,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Subject:   strconv.Itoa(userID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString(t.secret)
+	if err != nil {
+		return "", fmt.Errorf("sign %s token: %w", typ, err)
+	}
+	return signed, nil
+}
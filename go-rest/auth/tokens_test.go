@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// newTestTokens returns Tokens whose clock is *now
+func newTestTokens(secret string, now *time.Time) *Tokens {
+	t := NewTokens([]byte(secret))
+	t.now = func() time.Time { return *now }
+	return t
+}
+
+// forge signs c with method and key, bypassing Tokens
+func forge(t *testing.T, method jwt.SigningMethod, key any, c jwt.Claims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, c).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestVerifyRejects(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	tokens := newTestTokens("secret", &now)
+	pair, err := tokens.Issue(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := func(typ string) claims {
+		return claims{Type: typ, RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Subject:   "7",
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		}}
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		wantType string
+		// later moves the clock forward before verifying
+		later time.Duration
+	}{
+		{"empty", "", TypeAccess, 0},
+		{"not a JWT", "not.a.token", TypeAccess, 0},
+		{"wrong signature", forge(t, jwt.SigningMethodHS256, []byte("other secret"), valid(TypeAccess)), TypeAccess, 0},
+		{"tampered signature", pair.AccessToken[:len(pair.AccessToken)-2] + "AA", TypeAccess, 0},
+		{"other algorithm", forge(t, jwt.SigningMethodHS512, []byte("secret"), valid(TypeAccess)), TypeAccess, 0},
+		{"unsigned", forge(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, valid(TypeAccess)), TypeAccess, 0},
+		{"expired access token", pair.AccessToken, TypeAccess, AccessTokenTTL + time.Second},
+		{"expired refresh token", pair.RefreshToken, TypeRefresh, RefreshTokenTTL + time.Second},
+		{"refresh token used as access token", pair.RefreshToken, TypeAccess, 0},
+		{"access token used as refresh token", pair.AccessToken, TypeRefresh, 0},
+		{"no type", forge(t, jwt.SigningMethodHS256, []byte("secret"), valid("")), TypeAccess, 0},
+		{"no expiry", forge(t, jwt.SigningMethodHS256, []byte("secret"), func() claims {
+			c := valid(TypeAccess)
+			c.ExpiresAt = nil
+			return c
+		}()), TypeAccess, 0},
+		{"other issuer", forge(t, jwt.SigningMethodHS256, []byte("secret"), func() claims {
+			c := valid(TypeAccess)
+			c.Issuer = "someone-else"
+			return c
+		}()), TypeAccess, 0},
+		{"subject is not a user ID", forge(t, jwt.SigningMethodHS256, []byte("secret"), func() claims {
+			c := valid(TypeAccess)
+			c.Subject = "alice"
+			return c
+		}()), TypeAccess, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at := now.Add(tt.later)
+			id, err := newTestTokens("secret", &at).Verify(tt.token, tt.wantType)
+			if !errors.Is(err, ErrInvalidToken) || id != 0 {
+				t.Fatalf("Verify = %d, %v; want ErrInvalidToken", id, err)
+			}
+		})
+	}
+}
+
+func TestRefreshFlow(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	tokens := newTestTokens("secret", &now)
+	pair, err := tokens.Issue(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pair.TokenType != "Bearer" || pair.ExpiresIn != int(AccessTokenTTL.Seconds()) {
+		t.Fatalf("pair %+v", pair)
+	}
+	if id, err := tokens.Verify(pair.AccessToken, TypeAccess); err != nil || id != 7 {
+		t.Fatalf("fresh access token: %d, %v", id, err)
+	}
+
+	// Once the access token has expired, the refresh token gets a new pair
+	now = now.Add(AccessTokenTTL + time.Minute)
+	if _, err := tokens.Verify(pair.AccessToken, TypeAccess); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expired access token: %v", err)
+	}
+	id, err := tokens.Verify(pair.RefreshToken, TypeRefresh)
+	if err != nil || id != 7 {
+		t.Fatalf("refresh token: %d, %v", id, err)
+	}
+	renewed, err := tokens.Issue(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := tokens.Verify(renewed.AccessToken, TypeAccess); err != nil || id != 7 {
+		t.Fatalf("renewed access token: %d, %v", id, err)
+	}
+
+	// Tokens of another secret, e.g. before a rotation, are refused
+	if _, err := newTestTokens("rotated", &now).Verify(renewed.RefreshToken, TypeRefresh); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("refresh token after a secret rotation: %v", err)
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !CheckPassword(hash, "correct horse") {
+		t.Fatal("the right password does not match")
+	}
+
+	tests := []struct {
+		name     string
+		hash     string
+		password string
+	}{
+		{"wrong password", hash, "correct horsE"},
+		{"empty password", hash, ""},
+		{"prefix of the password", hash, "correct"},
+		{"user without a password", "", ""},
+		{"password as the hash", "correct horse", "correct horse"},
+		{"broken hash", hash[:len(hash)-5], "correct horse"},
+	}
+	for _, tt := range tests {
+		if CheckPassword(tt.hash, tt.password) {
+			t.Errorf("%s: CheckPassword matched", tt.name)
+		}
+	}
+
+	// bcrypt refuses what it would silently cut short
+	if _, err := HashPassword(strings.Repeat("x", MaxPasswordLength+1)); err == nil {
+		t.Error("a password over MaxPasswordLength was hashed")
+	}
+}
@@ -0,0 +1,18 @@
+package client
+
+import (
+	"context"
+	"iter"
+
+	"github.com/manish-npx/go-lang/go-rest/models"
+)
+
+// ListAudit fetches one page of the audit trail. Admins only.
+func (c *Client) ListAudit(ctx context.Context, opts ListOptions) (*Page[models.AuditRecord], error) {
+	return list[models.AuditRecord](ctx, c, "/audit", opts)
+}
+
+// AllAudit iterates over the whole audit trail. Admins only.
+func (c *Client) AllAudit(ctx context.Context, opts ListOptions) iter.Seq2[models.AuditRecord, error] {
+	return all[models.AuditRecord](ctx, c, "/audit", opts)
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+
+	"github.com/manish-npx/go-lang/go-rest/models"
+)
+
+// TokenPair is the answer of Login and Refresh
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	// ExpiresIn is the lifetime of the access token, in seconds
+	ExpiresIn int `json:"expires_in"`
+}
+
+// RegisterRequest is the body of Register
+type RegisterRequest struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// Register signs up a new user. It does not log in, see Login.
+func (c *Client) Register(ctx context.Context, req RegisterRequest) (*models.User, error) {
+	var user models.User
+	if _, err := c.do(ctx, request{method: "POST", path: "/auth/register", body: req}, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Login trades an email and password for tokens. The access token is
+// used for the following requests of c.
+func (c *Client) Login(ctx context.Context, email, password string) (*TokenPair, error) {
+	body := map[string]string{"email": email, "password": password}
+	return c.tokens(ctx, "/auth/login", body)
+}
+
+// Refresh trades a refresh token for new tokens. The new access token is
+// used for the following requests of c.
+func (c *Client) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	body := map[string]string{"refresh_token": refreshToken}
+	return c.tokens(ctx, "/auth/refresh", body)
+}
+
+func (c *Client) tokens(ctx context.Context, path string, body any) (*TokenPair, error) {
+	var pair TokenPair
+	if _, err := c.do(ctx, request{method: "POST", path: path, body: body}, &pair); err != nil {
+		return nil, err
+	}
+	c.SetToken(pair.AccessToken)
+	return &pair, nil
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"iter"
+
+	"github.com/manish-npx/go-lang/go-rest/models"
+)
+
+// BlogSearchResult is one hit of SearchBlogs
+type BlogSearchResult struct {
+	models.Blog
+	Score float64 `json:"score"`
+	// Highlights maps field names to HTML snippets, matches wrapped in <mark>
+	Highlights map[string]string `json:"highlights"`
+}
+
+// ListBlogs fetches one page of blogs
+func (c *Client) ListBlogs(ctx context.Context, opts ListOptions) (*Page[models.Blog], error) {
+	return list[models.Blog](ctx, c, "/blogs", opts)
+}
+
+// AllBlogs iterates over every blog, fetching pages as needed
+func (c *Client) AllBlogs(ctx context.Context, opts ListOptions) iter.Seq2[models.Blog, error] {
+	return all[models.Blog](ctx, c, "/blogs", opts)
+}
+
+// SearchBlogs fetches one page of the blogs matching query, best first
+func (c *Client) SearchBlogs(ctx context.Context, query string, opts ListOptions) (*Page[BlogSearchResult], error) {
+	return list[BlogSearchResult](ctx, c, "/blogs/search", opts.withFilter("q", query))
+}
+
+// AllSearchResults iterates over every blog matching query, best first
+func (c *Client) AllSearchResults(ctx context.Context, query string, opts ListOptions) iter.Seq2[BlogSearchResult, error] {
+	return all[BlogSearchResult](ctx, c, "/blogs/search", opts.withFilter("q", query))
+}
+
+// GetBlog fetches the blog with the given ID
+func (c *Client) GetBlog(ctx context.Context, id int) (*models.Blog, error) {
+	return c.blog(ctx, request{method: "GET", path: blogPath(id)})
+}
+
+// CreateBlog publishes a blog written by the logged in user
+func (c *Client) CreateBlog(ctx context.Context, blog models.Blog) (*models.Blog, error) {
+	return c.blog(ctx, request{method: "POST", path: "/blogs", body: blog})
+}
+
+// UpdateBlog replaces the blog with blog.ID. When blog.Version is set the
+// update fails with ErrPreconditionFailed if someone changed the blog in
+// between, see UpdateUser.
+func (c *Client) UpdateBlog(ctx context.Context, blog models.Blog) (*models.Blog, error) {
+	return c.blog(ctx, request{method: "PUT", path: blogPath(blog.ID), body: blog, ifMatch: versionETag(blog.Version)})
+}
+
+// PatchBlog changes only the given fields, e.g. {"title": "Hello"}
+func (c *Client) PatchBlog(ctx context.Context, id int, fields map[string]any) (*models.Blog, error) {
+	return c.blog(ctx, request{method: "PATCH", path: blogPath(id), body: fields})
+}
+
+// DeleteBlog deletes a blog. It can be brought back with RestoreBlog.
+func (c *Client) DeleteBlog(ctx context.Context, id int) error {
+	_, err := c.do(ctx, request{method: "DELETE", path: blogPath(id)}, nil)
+	return err
+}
+
+// RestoreBlog brings back a deleted blog
+func (c *Client) RestoreBlog(ctx context.Context, id int) (*models.Blog, error) {
+	return c.blog(ctx, request{method: "POST", path: blogPath(id) + "/restore"})
+}
+
+func (c *Client) blog(ctx context.Context, req request) (*models.Blog, error) {
+	var blog models.Blog
+	if _, err := c.do(ctx, req, &blog); err != nil {
+		return nil, err
+	}
+	return &blog, nil
+}
+
+func blogPath(id int) string {
+	return fmt.Sprintf("/blogs/%d", id)
+}
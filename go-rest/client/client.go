@@ -0,0 +1,206 @@
+// Package client is a typed Go client for the go-rest API.
+//
+//	c, err := client.New("http://localhost:8080", client.Options{})
+//	if _, err := c.Login(ctx, "alice@example.com", "password123"); err != nil { ... }
+//	for user, err := range c.AllUsers(ctx, client.ListOptions{Sort: "name"}) { ... }
+//
+// Error responses come back as *Error, which matches the sentinel errors
+// with errors.Is, e.g. errors.Is(err, client.ErrNotFound). Requests that
+// fail with 429 or, when it is safe to send them again, with a 5xx or a
+// network error are retried with exponential backoff. Every method stops
+// as soon as its context is done.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults used for the zero fields of Options
+const (
+	DEFAULT_MAX_RETRIES = 3
+	DEFAULT_MIN_BACKOFF = 100 * time.Millisecond
+	DEFAULT_MAX_BACKOFF = 5 * time.Second
+)
+
+// Options tune a Client. The zero value is ready to use.
+type Options struct {
+	// HTTPClient sends the requests, http.DefaultClient when nil
+	HTTPClient *http.Client
+	// Token is the access token sent with every request, see also Login
+	Token string
+	// MaxRetries is how many times a failed request is sent again;
+	// DEFAULT_MAX_RETRIES when zero, no retries when negative
+	MaxRetries int
+	// MinBackoff is the wait before the first retry, doubled after each
+	// one up to MaxBackoff
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// UserAgent is sent in the User-Agent header when set
+	UserAgent string
+}
+
+// Client calls the go-rest API. It is safe for concurrent use.
+type Client struct {
+	base *url.URL
+	http *http.Client
+	opts Options
+
+	mu    sync.RWMutex
+	token string
+}
+
+// New returns a client for the API at baseURL, e.g. "http://localhost:8080"
+func New(baseURL string, opts Options) (*Client, error) {
+	base, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("client: invalid base URL: %w", err)
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("client: base URL %q must be http or https", baseURL)
+	}
+
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = DEFAULT_MAX_RETRIES
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = DEFAULT_MIN_BACKOFF
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DEFAULT_MAX_BACKOFF
+	}
+	return &Client{base: base, http: opts.HTTPClient, opts: opts, token: opts.Token}, nil
+}
+
+// SetToken changes the access token sent with later requests.
+// An empty token sends requests anonymously.
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+}
+
+// Token returns the access token in use
+func (c *Client) Token() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token
+}
+
+// request is one API call
+type request struct {
+	method string
+	// path is relative to the base URL, e.g. "/users/3"
+	path  string
+	query url.Values
+	// body is encoded as JSON when not nil
+	body any
+	// ifMatch is sent in If-Match when not empty
+	ifMatch string
+}
+
+// do sends req, retrying as described in the package doc, and decodes the
+// JSON answer into out unless out is nil. It returns the final response,
+// whose body is already closed.
+func (c *Client) do(ctx context.Context, req request, out any) (*http.Response, error) {
+	var body []byte
+	if req.body != nil {
+		var err error
+		if body, err = json.Marshal(req.body); err != nil {
+			return nil, fmt.Errorf("client: encoding request body: %w", err)
+		}
+	}
+
+	u := c.base.JoinPath(req.path)
+	if len(req.query) > 0 {
+		u.RawQuery = req.query.Encode()
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, data, err := c.send(ctx, req, u.String(), body)
+		if err == nil && resp.StatusCode < 400 {
+			if out != nil && len(data) > 0 {
+				if err := json.Unmarshal(data, out); err != nil {
+					return resp, fmt.Errorf("client: decoding %s %s: %w", req.method, req.path, err)
+				}
+			}
+			return resp, nil
+		}
+		if err == nil {
+			err = newError(resp, data)
+		}
+
+		wait, retry := c.retryAfter(ctx, req.method, attempt, resp, err)
+		if !retry {
+			return resp, err
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return resp, err
+		}
+	}
+}
+
+// send makes one attempt and reads the whole response body
+func (c *Client) send(ctx context.Context, req request, u string, body []byte) (*http.Response, []byte, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, u, reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("client: %w", err)
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if req.ifMatch != "" {
+		httpReq.Header.Set("If-Match", req.ifMatch)
+	}
+	if token := c.Token(); token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+	if c.opts.UserAgent != "" {
+		httpReq.Header.Set("User-Agent", c.opts.UserAgent)
+	}
+
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp, nil, err
+	}
+	return resp, data, nil
+}
+
+// sleep waits for d, or returns the context error if ctx is done first
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// contextError reports whether err came from the context rather than the server
+func contextError(ctx context.Context, err error) bool {
+	return ctx.Err() != nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded))
+}
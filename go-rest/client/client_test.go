@@ -0,0 +1,425 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/manish-npx/go-lang/go-rest/auth"
+	"github.com/manish-npx/go-lang/go-rest/events"
+	"github.com/manish-npx/go-lang/go-rest/live"
+	"github.com/manish-npx/go-lang/go-rest/metrics"
+	"github.com/manish-npx/go-lang/go-rest/middleware"
+	"github.com/manish-npx/go-lang/go-rest/models"
+	"github.com/manish-npx/go-lang/go-rest/render"
+	"github.com/manish-npx/go-lang/go-rest/repository"
+	"github.com/manish-npx/go-lang/go-rest/routes"
+	"github.com/manish-npx/go-lang/go-rest/webhooks"
+)
+
+// testAPI is the real API on an in-memory store, seeded with the admin
+// Alice (ID 1) and the editor Bob (ID 2)
+type testAPI struct {
+	server *httptest.Server
+	store  *repository.Store
+	tokens *auth.Tokens
+	// lists counts the GET requests to list endpoints
+	lists atomic.Int32
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	api := &testAPI{
+		store:  repository.NewMemoryStore(),
+		tokens: auth.NewTokens([]byte("test-secret")),
+	}
+	router := routes.NewRouter(api.store,
+		api.tokens,
+		metrics.New(),
+		render.Default(),
+		events.NewBroadcaster(events.DEFAULT_LOG_SIZE),
+		live.NewHubs(),
+		webhooks.NewDispatcher(api.store.Webhooks, api.store.WebhookDeliveries, webhooks.Options{}),
+	)
+	h := middleware.Chain(router, middleware.RequestID, auth.Authenticate(api.tokens, api.store.Users))
+	api.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" && (r.URL.Path == "/users" || r.URL.Path == "/blogs") {
+			api.lists.Add(1)
+		}
+		h.ServeHTTP(w, r)
+	}))
+	t.Cleanup(api.server.Close)
+	return api
+}
+
+// client returns a client signed in as userID, or anonymous for 0
+func (api *testAPI) client(t *testing.T, userID int) *Client {
+	t.Helper()
+	opts := Options{MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	if userID != 0 {
+		pair, err := api.tokens.Issue(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts.Token = pair.AccessToken
+	}
+	c, err := New(api.server.URL, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// stubClient returns a client for handler, retrying quickly
+func stubClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	c, err := New(server.URL, Options{MinBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestAllUsersFollowsCursors(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+	for i := range 5 {
+		user := models.User{Name: fmt.Sprintf("User %d", i), Email: fmt.Sprintf("user%d@example.com", i), Role: models.RoleReader}
+		if err := api.store.Users.Create(ctx, &user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := api.client(t, 1)
+
+	var ids []int
+	for user, err := range c.AllUsers(ctx, ListOptions{Limit: 2}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, user.ID)
+	}
+	if want := []int{1, 2, 3, 4, 5, 6, 7}; !slices.Equal(ids, want) {
+		t.Fatalf("AllUsers gave %v, want %v", ids, want)
+	}
+	if n := api.lists.Load(); n != 4 {
+		t.Fatalf("AllUsers fetched %d pages, want 4", n)
+	}
+
+	// Descending, starting at an offset, stopping early
+	ids = nil
+	for user, err := range c.AllUsers(ctx, ListOptions{Limit: 2, Offset: 1, Sort: "-id"}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids = append(ids, user.ID); len(ids) == 3 {
+			break
+		}
+	}
+	if want := []int{6, 5, 4}; !slices.Equal(ids, want) {
+		t.Fatalf("AllUsers(-id, offset 1) gave %v, want %v", ids, want)
+	}
+}
+
+func TestAllBlogsFollowsCursors(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+	c := api.client(t, 1)
+	for i := range 4 {
+		if _, err := c.CreateBlog(ctx, models.Blog{Title: fmt.Sprintf("Go tips %d", i), Body: "Body"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var titles []string
+	for blog, err := range c.AllBlogs(ctx, ListOptions{Limit: 3, Filters: map[string]string{"title[contains]": "tips"}}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		titles = append(titles, blog.Title)
+	}
+	if want := []string{"Go tips 0", "Go tips 1", "Go tips 2", "Go tips 3"}; !slices.Equal(titles, want) {
+		t.Fatalf("AllBlogs gave %v, want %v", titles, want)
+	}
+	if n := api.lists.Load(); n != 2 {
+		t.Fatalf("AllBlogs fetched %d pages, want 2", n)
+	}
+}
+
+func TestAllStopsAtTheFirstError(t *testing.T) {
+	api := newTestAPI(t)
+	c := api.client(t, 1)
+
+	var got []error
+	for _, err := range c.AllUsers(context.Background(), ListOptions{Sort: "password"}) {
+		got = append(got, err)
+	}
+	if len(got) != 1 || !errors.Is(got[0], ErrBadRequest) {
+		t.Fatalf("AllUsers yielded %v, want one ErrBadRequest", got)
+	}
+}
+
+func TestErrorsMatchTheirCodes(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+	admin, anonymous := api.client(t, 1), api.client(t, 0)
+
+	stale, err := admin.GetUser(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.PatchUser(ctx, 2, map[string]any{"name": "Robert"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		call   func() error
+		want   error
+		status int
+	}{
+		{"missing user", func() error { _, err := admin.GetUser(ctx, 999); return err }, ErrNotFound, 404},
+		{"missing blog", func() error { return admin.DeleteBlog(ctx, 999) }, ErrNotFound, 404},
+		{"invalid user", func() error {
+			_, err := admin.CreateUser(ctx, models.User{Name: "", Email: "not an email"})
+			return err
+		}, ErrValidationFailed, 422},
+		{"anonymous write", func() error {
+			_, err := anonymous.CreateBlog(ctx, models.Blog{Title: "Hi", Body: "There"})
+			return err
+		}, ErrUnauthorized, 401},
+		{"stale version", func() error { _, err := admin.UpdateUser(ctx, *stale); return err }, ErrPreconditionFailed, 412},
+		{"wrong password", func() error {
+			_, err := anonymous.Login(ctx, "alice@example.com", "wrong")
+			return err
+		}, ErrUnauthorized, 401},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			var apiErr *Error
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("err is %T, want *Error", err)
+			}
+			if apiErr.Status != tt.status || apiErr.Message == "" || apiErr.RequestID == "" {
+				t.Fatalf("error %+v, want status %d with a message and a request ID", apiErr, tt.status)
+			}
+			for _, other := range []error{ErrNotFound, ErrValidationFailed, ErrUnauthorized, ErrPreconditionFailed} {
+				if other != tt.want && errors.Is(err, other) {
+					t.Fatalf("err also matches %v", other)
+				}
+			}
+		})
+	}
+}
+
+func TestValidationErrorsListFields(t *testing.T) {
+	api := newTestAPI(t)
+	_, err := api.client(t, 1).CreateUser(context.Background(), models.User{Name: "", Email: "not an email"})
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want *Error", err)
+	}
+	var fields []string
+	for _, f := range apiErr.FieldErrors() {
+		fields = append(fields, f.Field)
+	}
+	slices.Sort(fields)
+	if want := []string{"email", "name"}; !slices.Equal(fields, want) {
+		t.Fatalf("invalid fields %v, want %v", fields, want)
+	}
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+	c := api.client(t, 0)
+
+	user, err := c.Register(ctx, RegisterRequest{Name: "Carol", Email: "carol@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Login(ctx, "carol@example.com", "password123"); err != nil {
+		t.Fatal(err)
+	}
+	if c.Token() == "" {
+		t.Fatal("Login did not keep the access token")
+	}
+	// Only the user themselves (or an admin) sees the email
+	got, err := c.GetUser(ctx, user.ID)
+	if err != nil || got.Email != "carol@example.com" {
+		t.Fatalf("GetUser = %+v, %v", got, err)
+	}
+}
+
+func TestErrorsWithoutEnvelope(t *testing.T) {
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusBadGateway, ErrInternal},
+		{http.StatusServiceUnavailable, ErrUnavailable},
+	}
+	for _, tt := range tests {
+		c := stubClient(t, func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "<html>proxy error</html>", tt.status)
+		})
+		c.opts.MaxRetries = -1
+		_, err := c.GetUser(context.Background(), 1)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%d: err = %v, want %v", tt.status, err, tt.want)
+		}
+	}
+}
+
+// flaky answers the first failures requests with status, then a user
+func flaky(failures int32, status int, calls *atomic.Int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			w.Header().Set("Retry-After", "0")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			fmt.Fprintf(w, `{"error": {"code": "%s", "message": "try again"}}`, statusCodes[status])
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id": 1, "name": "Alice"}`)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		failures int32
+		method   string
+		// calls is how many requests reach the server
+		calls int32
+		want  error
+	}{
+		{"503 on GET", http.StatusServiceUnavailable, 2, "GET", 3, nil},
+		{"500 on PUT", http.StatusInternalServerError, 1, "PUT", 2, nil},
+		{"429 on GET", http.StatusTooManyRequests, 3, "GET", 4, nil},
+		{"429 on POST", http.StatusTooManyRequests, 1, "POST", 2, nil},
+		{"503 on POST is not retried", http.StatusServiceUnavailable, 1, "POST", 1, ErrUnavailable},
+		{"501 is not retried", http.StatusNotImplemented, 1, "GET", 1, ErrInternal},
+		{"404 is not retried", http.StatusNotFound, 1, "GET", 1, ErrNotFound},
+		{"retries run out", http.StatusServiceUnavailable, 10, "GET", 1 + DEFAULT_MAX_RETRIES, ErrUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			c := stubClient(t, flaky(tt.failures, tt.status, &calls))
+
+			var user models.User
+			_, err := c.do(context.Background(), request{method: tt.method, path: "/users/1", body: map[string]string{}}, &user)
+			if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if n := calls.Load(); n != tt.calls {
+				t.Fatalf("%d requests reached the server, want %d", n, tt.calls)
+			}
+			if tt.want == nil && user.Name != "Alice" {
+				t.Fatalf("decoded %+v", user)
+			}
+		})
+	}
+}
+
+func TestRetryAfterIsHonoured(t *testing.T) {
+	var calls atomic.Int32
+	var first time.Time
+	c := stubClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			first = time.Now()
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if waited := time.Since(first); waited < 900*time.Millisecond {
+			t.Errorf("retried after %v, the server asked for 1s", waited)
+		}
+		fmt.Fprint(w, `{"id": 1}`)
+	})
+	c.opts.MaxBackoff = 2 * time.Second
+
+	if _, err := c.GetUser(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBackoffGrowsAndIsCapped(t *testing.T) {
+	c, _ := New("http://localhost", Options{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second})
+	for attempt, limit := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		limit *= time.Millisecond
+		for range 20 {
+			if wait := c.backoff(attempt); wait < limit/2 || wait > limit {
+				t.Fatalf("backoff(%d) = %v, want between %v and %v", attempt, wait, limit/2, limit)
+			}
+		}
+	}
+	if wait := c.backoff(100); wait > time.Second {
+		t.Fatalf("backoff(100) = %v overflows the cap", wait)
+	}
+}
+
+func TestCancelDuringBackoff(t *testing.T) {
+	var calls atomic.Int32
+	reached := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		reached <- struct{}{}
+	}))
+	t.Cleanup(server.Close)
+	c, err := New(server.URL, Options{MinBackoff: time.Hour, MaxBackoff: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.GetUser(ctx, 1)
+		done <- err
+	}()
+
+	<-reached
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("err = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the client kept waiting after its context was cancelled")
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("%d requests reached the server, want 1", n)
+	}
+}
+
+func TestNewRejectsBadURLs(t *testing.T) {
+	for _, u := range []string{"localhost:8080", "ftp://example.com", "://"} {
+		if _, err := New(u, Options{}); err == nil {
+			t.Errorf("New(%q) did not fail", u)
+		}
+	}
+	c, err := New("http://example.com/api/", Options{})
+	if err != nil || !strings.HasSuffix(c.base.String(), "/api") {
+		t.Fatalf("New trimmed the base URL to %v, %v", c.base, err)
+	}
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/manish-npx/go-lang/go-rest/apierror"
+	"github.com/manish-npx/go-lang/go-rest/models"
+)
+
+// Errors matched by *Error with errors.Is, one per error code of the API
+var (
+	ErrBadRequest           = errors.New("bad request")
+	ErrUnauthorized         = errors.New("unauthorized")
+	ErrForbidden            = errors.New("forbidden")
+	ErrNotFound             = errors.New("not found")
+	ErrConflict             = errors.New("conflict")
+	ErrMethodNotAllowed     = errors.New("method not allowed")
+	ErrNotAcceptable        = errors.New("not acceptable")
+	ErrPreconditionFailed   = errors.New("precondition failed")
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	ErrPayloadTooLarge      = errors.New("payload too large")
+	ErrValidationFailed     = errors.New("validation failed")
+	ErrTooManyRequests      = errors.New("too many requests")
+	ErrInternal             = errors.New("internal server error")
+	ErrUnavailable          = errors.New("service unavailable")
+)
+
+var codeErrors = map[string]error{
+	apierror.CodeBadRequest:           ErrBadRequest,
+	apierror.CodeUnauthorized:         ErrUnauthorized,
+	apierror.CodeForbidden:            ErrForbidden,
+	apierror.CodeNotFound:             ErrNotFound,
+	apierror.CodeConflict:             ErrConflict,
+	apierror.CodeMethodNotAllowed:     ErrMethodNotAllowed,
+	apierror.CodeNotAcceptable:        ErrNotAcceptable,
+	apierror.CodePreconditionFailed:   ErrPreconditionFailed,
+	apierror.CodeUnsupportedMediaType: ErrUnsupportedMediaType,
+	apierror.CodePayloadTooLarge:      ErrPayloadTooLarge,
+	apierror.CodeValidationFailed:     ErrValidationFailed,
+	apierror.CodeTooManyRequests:      ErrTooManyRequests,
+	apierror.CodeInternal:             ErrInternal,
+	apierror.CodeUnavailable:          ErrUnavailable,
+}
+
+// Error is an error response of the API, see package apierror
+type Error struct {
+	// Status is the HTTP status code
+	Status    int
+	Code      string
+	Message   string
+	RequestID string
+	// Details is the raw "details" of the envelope, see FieldErrors
+	Details json.RawMessage
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("go-rest: %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// Is makes errors.Is(err, ErrNotFound) and friends work
+func (e *Error) Is(target error) bool {
+	return codeErrors[e.Code] == target
+}
+
+// FieldErrors returns the invalid fields of a validation error (422),
+// nil for other errors
+func (e *Error) FieldErrors() []models.FieldError {
+	if e.Code != apierror.CodeValidationFailed {
+		return nil
+	}
+	var fields []models.FieldError
+	json.Unmarshal(e.Details, &fields)
+	return fields
+}
+
+// newError reads the error envelope from data. Answers that are not an
+// envelope, e.g. from a proxy, get a code guessed from the status.
+func newError(resp *http.Response, data []byte) *Error {
+	var envelope struct {
+		Error struct {
+			Code      string          `json:"code"`
+			Message   string          `json:"message"`
+			Details   json.RawMessage `json:"details"`
+			RequestID string          `json:"request_id"`
+		} `json:"error"`
+	}
+	e := &Error{Status: resp.StatusCode}
+	if json.Unmarshal(data, &envelope) == nil && envelope.Error.Code != "" {
+		e.Code = envelope.Error.Code
+		e.Message = envelope.Error.Message
+		e.Details = envelope.Error.Details
+		e.RequestID = envelope.Error.RequestID
+		return e
+	}
+
+	e.Code = statusCodes[resp.StatusCode]
+	if e.Code == "" && resp.StatusCode >= 500 {
+		e.Code = apierror.CodeInternal
+	}
+	e.Message = http.StatusText(resp.StatusCode)
+	return e
+}
+
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            apierror.CodeBadRequest,
+	http.StatusUnauthorized:          apierror.CodeUnauthorized,
+	http.StatusForbidden:             apierror.CodeForbidden,
+	http.StatusNotFound:              apierror.CodeNotFound,
+	http.StatusConflict:              apierror.CodeConflict,
+	http.StatusMethodNotAllowed:      apierror.CodeMethodNotAllowed,
+	http.StatusNotAcceptable:         apierror.CodeNotAcceptable,
+	http.StatusPreconditionFailed:    apierror.CodePreconditionFailed,
+	http.StatusUnsupportedMediaType:  apierror.CodeUnsupportedMediaType,
+	http.StatusRequestEntityTooLarge: apierror.CodePayloadTooLarge,
+	http.StatusUnprocessableEntity:   apierror.CodeValidationFailed,
+	http.StatusTooManyRequests:       apierror.CodeTooManyRequests,
+	http.StatusServiceUnavailable:    apierror.CodeUnavailable,
+}
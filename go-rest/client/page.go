@@ -0,0 +1,107 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"maps"
+	"net/url"
+	"strconv"
+)
+
+// Page is one page of a list endpoint
+type Page[T any] struct {
+	Data   []T `json:"data"`
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+	// NextCursor is empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+	Next       string `json:"next,omitempty"`
+}
+
+// ListOptions are the query parameters of list endpoints. Zero fields
+// are left out, so the server defaults apply.
+type ListOptions struct {
+	Limit  int
+	Offset int
+	// Cursor is the NextCursor of the previous page
+	Cursor string
+	// Sort is a comma separated list of fields, "-" first for descending,
+	// e.g. "name,-id"
+	Sort string
+	// Filters maps fields to values, e.g. {"name": "Bob"} for an exact
+	// match or {"title[contains]": "go"} for a substring
+	Filters map[string]string
+}
+
+func (o ListOptions) values() url.Values {
+	v := url.Values{}
+	for field, value := range o.Filters {
+		v.Set(field, value)
+	}
+	if o.Limit > 0 {
+		v.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Offset > 0 {
+		v.Set("offset", strconv.Itoa(o.Offset))
+	}
+	if o.Cursor != "" {
+		v.Set("cursor", o.Cursor)
+	}
+	if o.Sort != "" {
+		v.Set("sort", o.Sort)
+	}
+	return v
+}
+
+// withFilter returns a copy of o with one more filter, leaving the
+// caller's map alone
+func (o ListOptions) withFilter(field, value string) ListOptions {
+	o.Filters = maps.Clone(o.Filters)
+	if o.Filters == nil {
+		o.Filters = map[string]string{}
+	}
+	o.Filters[field] = value
+	return o
+}
+
+// list fetches one page of the list endpoint at path
+func list[T any](ctx context.Context, c *Client, path string, opts ListOptions) (*Page[T], error) {
+	var page Page[T]
+	if _, err := c.do(ctx, request{method: "GET", path: path, query: opts.values()}, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// all iterates over every item of the list endpoint at path, starting at
+// the page opts points to and following the next pages. It stops at the
+// first error, which it yields with a zero item.
+func all[T any](ctx context.Context, c *Client, path string, opts ListOptions) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		opts := opts
+		for {
+			page, err := list[T](ctx, c, path, opts)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range page.Data {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if page.Next == "" {
+				return
+			}
+			// The server keeps paging by offset when asked with one, and
+			// by cursor otherwise
+			if page.NextCursor != "" {
+				opts.Cursor = page.NextCursor
+			} else {
+				opts.Offset = page.Offset + len(page.Data)
+			}
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retryAfter decides whether the attempt-th try of a method that ended
+// with resp and err is worth sending again, and how long to wait first.
+//
+// 429 is always retried: the server did not act on the request. 5xx
+// answers and network errors are only retried for idempotent methods,
+// since a POST may have been carried out before things went wrong.
+func (c *Client) retryAfter(ctx context.Context, method string, attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	if attempt >= c.opts.MaxRetries || contextError(ctx, err) {
+		return 0, false
+	}
+
+	switch {
+	case resp != nil && resp.StatusCode == http.StatusTooManyRequests:
+		// The server knows best when a token will be back in the bucket
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return min(time.Duration(seconds)*time.Second, c.opts.MaxBackoff), true
+		}
+	case !idempotent(method):
+		return 0, false
+	case resp == nil:
+		// Network error, nothing was received
+	case resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented:
+	default:
+		return 0, false
+	}
+	return c.backoff(attempt), true
+}
+
+// backoff returns the wait before retry number attempt+1: MinBackoff
+// doubled attempt times, capped at MaxBackoff, with full jitter so many
+// clients do not all come back at the same moment
+func (c *Client) backoff(attempt int) time.Duration {
+	wait := c.opts.MinBackoff << min(attempt, 30)
+	if wait <= 0 || wait > c.opts.MaxBackoff {
+		wait = c.opts.MaxBackoff
+	}
+	return wait/2 + rand.N(wait/2+1)
+}
+
+// idempotent reports whether sending method twice has the same effect as once
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"iter"
+
+	"github.com/manish-npx/go-lang/go-rest/models"
+)
+
+// ListUsers fetches one page of users
+func (c *Client) ListUsers(ctx context.Context, opts ListOptions) (*Page[models.User], error) {
+	return list[models.User](ctx, c, "/users", opts)
+}
+
+// AllUsers iterates over every user, fetching pages as needed
+func (c *Client) AllUsers(ctx context.Context, opts ListOptions) iter.Seq2[models.User, error] {
+	return all[models.User](ctx, c, "/users", opts)
+}
+
+// GetUser fetches the user with the given ID
+func (c *Client) GetUser(ctx context.Context, id int) (*models.User, error) {
+	return c.user(ctx, request{method: "GET", path: userPath(id)})
+}
+
+// CreateUser creates a user. Admins only; everyone else uses Register.
+func (c *Client) CreateUser(ctx context.Context, user models.User) (*models.User, error) {
+	return c.user(ctx, request{method: "POST", path: "/users", body: user})
+}
+
+// UpdateUser replaces the user with user.ID. When user.Version is set,
+// e.g. because user was fetched with GetUser, the update fails with
+// ErrPreconditionFailed if someone changed the user in between.
+func (c *Client) UpdateUser(ctx context.Context, user models.User) (*models.User, error) {
+	return c.user(ctx, request{method: "PUT", path: userPath(user.ID), body: user, ifMatch: versionETag(user.Version)})
+}
+
+// PatchUser changes only the given fields, e.g. {"name": "Bob"}
+func (c *Client) PatchUser(ctx context.Context, id int, fields map[string]any) (*models.User, error) {
+	return c.user(ctx, request{method: "PATCH", path: userPath(id), body: fields})
+}
+
+// DeleteUser deletes a user. It can be brought back with RestoreUser.
+func (c *Client) DeleteUser(ctx context.Context, id int) error {
+	_, err := c.do(ctx, request{method: "DELETE", path: userPath(id)}, nil)
+	return err
+}
+
+// RestoreUser brings back a deleted user. Admins only.
+func (c *Client) RestoreUser(ctx context.Context, id int) (*models.User, error) {
+	return c.user(ctx, request{method: "POST", path: userPath(id) + "/restore"})
+}
+
+// ListUserBlogs fetches one page of the blogs written by a user
+func (c *Client) ListUserBlogs(ctx context.Context, userID int, opts ListOptions) (*Page[models.Blog], error) {
+	return list[models.Blog](ctx, c, userPath(userID)+"/blogs", opts)
+}
+
+// AllUserBlogs iterates over every blog written by a user
+func (c *Client) AllUserBlogs(ctx context.Context, userID int, opts ListOptions) iter.Seq2[models.Blog, error] {
+	return all[models.Blog](ctx, c, userPath(userID)+"/blogs", opts)
+}
+
+func (c *Client) user(ctx context.Context, req request) (*models.User, error) {
+	var user models.User
+	if _, err := c.do(ctx, req, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func userPath(id int) string {
+	return fmt.Sprintf("/users/%d", id)
+}
+
+// versionETag returns the ETag the server gives version, or "" (no
+// If-Match) for version 0
+func versionETag(version int) string {
+	if version == 0 {
+		return ""
+	}
+	return fmt.Sprintf(`"v%d"`, version)
+}
@@ -0,0 +1,412 @@
+// Package config holds the settings that change how go-rest runs.
+//
+// Every setting can come from four places. Later ones win:
+//
+//  1. the built-in default
+//  2. an optional YAML (.yaml/.yml) or TOML (.toml) file, given with
+//     -config or CONFIG_FILE
+//  3. an environment variable, e.g. ADDR=:9090
+//  4. a command-line flag, e.g. -addr=:9090
+//
+// Run the server with -print-config to see the effective configuration
+// with secrets redacted.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/manish-npx/go-lang/go-rest/ratelimit"
+	"gopkg.in/yaml.v3"
+)
+
+// Storage backends understood by main
+const (
+	StorageMemory = "memory"
+	StorageSQLite = "sqlite"
+)
+
+// MinSecretLength is the shortest JWT secret accepted, in bytes
+const MinSecretLength = 32
+
+// Config is the effective configuration of the server
+type Config struct {
+	// Addr is the address the HTTP server listens on, e.g. ":8080"
+	Addr string
+	// Server timeouts, see http.Server for what each one covers
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// ShutdownTimeout is how long in-flight requests get to finish on SIGINT/SIGTERM
+	ShutdownTimeout time.Duration
+
+	// Storage picks the backend: "memory" (default) or "sqlite"
+	Storage string
+	// DSN is the SQLite database file, only used by the sqlite backend
+	DSN string
+
+	// LogLevel is the lowest level that gets logged: debug, info, warn or error
+	LogLevel slog.Level
+	// CORSOrigins are the browser origins allowed to call the API ("*" for any)
+	CORSOrigins []string
+
+	// JWTSecret signs the auth tokens. Never printed.
+	JWTSecret string
+	// AdminEmail and AdminPassword, when set, make sure an admin account
+	// exists at startup, so a fresh install can be managed. The password is never printed.
+	AdminEmail    string
+	AdminPassword string
+
+	// RateLimit is how many requests each IP address, and each signed-in
+	// user, may make, e.g. 300/1m. RateLimitRoutes override it for
+	// matching routes. Probes and /metrics are never limited.
+	RateLimit       ratelimit.Rule
+	RateLimitRoutes []ratelimit.RouteRule
+
+	// PrintConfig asks main to print the effective config and exit
+	PrintConfig bool
+}
+
+// setting ties one Config field to its flag, environment variable and file key
+type setting struct {
+	// name is the flag name and the key in the config file
+	name   string
+	env    string
+	usage  string
+	secret bool
+	set    func(cfg *Config, value string) error
+	get    func(cfg Config) string
+}
+
+var settings = []setting{
+	{
+		name: "addr", env: "ADDR", usage: "address to listen on",
+		set: func(c *Config, v string) error { c.Addr = v; return nil },
+		get: func(c Config) string { return c.Addr },
+	},
+	durationSetting("read-timeout", "READ_TIMEOUT", "max time to read a whole request",
+		func(c *Config) *time.Duration { return &c.ReadTimeout }),
+	durationSetting("read-header-timeout", "READ_HEADER_TIMEOUT", "max time to read request headers",
+		func(c *Config) *time.Duration { return &c.ReadHeaderTimeout }),
+	durationSetting("write-timeout", "WRITE_TIMEOUT", "max time to write a response",
+		func(c *Config) *time.Duration { return &c.WriteTimeout }),
+	durationSetting("idle-timeout", "IDLE_TIMEOUT", "max time to keep an idle keep-alive connection",
+		func(c *Config) *time.Duration { return &c.IdleTimeout }),
+	durationSetting("shutdown-timeout", "SHUTDOWN_TIMEOUT", "max time to drain requests on shutdown",
+		func(c *Config) *time.Duration { return &c.ShutdownTimeout }),
+	{
+		name: "storage", env: "STORAGE", usage: "storage backend: memory or sqlite",
+		set: func(c *Config, v string) error { c.Storage = v; return nil },
+		get: func(c Config) string { return c.Storage },
+	},
+	{
+		name: "dsn", env: "DATABASE_DSN", usage: "SQLite database file (sqlite storage only)",
+		set: func(c *Config, v string) error { c.DSN = v; return nil },
+		get: func(c Config) string { return c.DSN },
+	},
+	{
+		name: "log-level", env: "LOG_LEVEL", usage: "debug, info, warn or error",
+		set: func(c *Config, v string) error { return c.LogLevel.UnmarshalText([]byte(v)) },
+		get: func(c Config) string { return strings.ToLower(c.LogLevel.String()) },
+	},
+	{
+		name: "cors-origins", env: "CORS_ORIGINS", usage: "comma-separated browser origins allowed by CORS, * for any",
+		set: func(c *Config, v string) error { c.CORSOrigins = splitList(v); return nil },
+		get: func(c Config) string { return strings.Join(c.CORSOrigins, ",") },
+	},
+	{
+		name: "jwt-secret", env: "JWT_SECRET", usage: "secret used to sign auth tokens", secret: true,
+		set: func(c *Config, v string) error { c.JWTSecret = v; return nil },
+		get: func(c Config) string { return c.JWTSecret },
+	},
+	{
+		name: "admin-email", env: "ADMIN_EMAIL", usage: "email of the admin account created or promoted at startup",
+		set: func(c *Config, v string) error { c.AdminEmail = v; return nil },
+		get: func(c Config) string { return c.AdminEmail },
+	},
+	{
+		name: "admin-password", env: "ADMIN_PASSWORD", usage: "password of that admin account", secret: true,
+		set: func(c *Config, v string) error { c.AdminPassword = v; return nil },
+		get: func(c Config) string { return c.AdminPassword },
+	},
+	{
+		name: "rate-limit", env: "RATE_LIMIT", usage: "requests per IP and per signed-in user, e.g. 300/1m, or off",
+		set: func(c *Config, v string) (err error) { c.RateLimit, err = ratelimit.ParseRule(v); return err },
+		get: func(c Config) string { return c.RateLimit.String() },
+	},
+	{
+		name: "rate-limit-routes", env: "RATE_LIMIT_ROUTES", usage: `comma-separated per-route limits, e.g. "POST /users=10/1m"`,
+		set: func(c *Config, v string) error {
+			c.RateLimitRoutes = nil
+			for _, item := range splitList(v) {
+				rr, err := ratelimit.ParseRouteRule(item)
+				if err != nil {
+					return err
+				}
+				c.RateLimitRoutes = append(c.RateLimitRoutes, rr)
+			}
+			return nil
+		},
+		get: func(c Config) string {
+			items := make([]string, len(c.RateLimitRoutes))
+			for i, rr := range c.RateLimitRoutes {
+				items[i] = rr.String()
+			}
+			return strings.Join(items, ",")
+		},
+	},
+}
+
+// durationSetting builds a setting for a time.Duration field.
+// Durations use Go syntax: "500ms", "15s", "2m".
+func durationSetting(name, env, usage string, field func(*Config) *time.Duration) setting {
+	return setting{
+		name: name, env: env, usage: usage,
+		set: func(c *Config, v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return err
+			}
+			*field(c) = d
+			return nil
+		},
+		get: func(c Config) string { return field(&c).String() },
+	}
+}
+
+// Default returns the configuration used when nothing is set
+func Default() Config {
+	return Config{
+		Addr:              ":8080",
+		ReadTimeout:       15 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       60 * time.Second,
+		ShutdownTimeout:   20 * time.Second,
+		Storage:           StorageMemory,
+		DSN:               "go-rest.db",
+		LogLevel:          slog.LevelInfo,
+		RateLimit:         ratelimit.Rule{Requests: 300, Per: time.Minute},
+		// Sign-ups and logins are the usual targets of floods and guessing
+		RateLimitRoutes: []ratelimit.RouteRule{
+			{Pattern: "POST /users", Rule: ratelimit.Rule{Requests: 10, Per: time.Minute}},
+			{Pattern: "POST /auth/register", Rule: ratelimit.Rule{Requests: 5, Per: time.Minute}},
+			{Pattern: "POST /auth/login", Rule: ratelimit.Rule{Requests: 10, Per: time.Minute}},
+		},
+	}
+}
+
+// Load builds the configuration from defaults, the config file, the
+// environment and the command-line args (without the program name),
+// then validates it.
+func Load(args []string) (Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("go-rest", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "optional YAML or TOML config file (env CONFIG_FILE)")
+	fs.BoolVar(&cfg.PrintConfig, "print-config", false, "print the effective config (secrets redacted) and exit")
+	flagValues := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		flagValues[s.name] = fs.String(s.name, "", fmt.Sprintf("%s (env %s)", s.usage, s.env))
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	if *configFile != "" {
+		if err := loadFile(&cfg, *configFile); err != nil {
+			return Config{}, err
+		}
+	}
+
+	for _, s := range settings {
+		if v := os.Getenv(s.env); v != "" {
+			if err := s.set(&cfg, v); err != nil {
+				return Config{}, fmt.Errorf("env %s: %w", s.env, err)
+			}
+		}
+	}
+
+	// Only flags that were actually passed override the rest
+	var flagErrs []error
+	fs.Visit(func(f *flag.Flag) {
+		s, ok := findSetting(f.Name)
+		if !ok {
+			return // -config and -print-config are not settings
+		}
+		if err := s.set(&cfg, *flagValues[f.Name]); err != nil {
+			flagErrs = append(flagErrs, fmt.Errorf("flag -%s: %w", f.Name, err))
+		}
+	})
+	if err := errors.Join(flagErrs...); err != nil {
+		return Config{}, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// loadFile applies the settings found in a YAML or TOML file.
+// Keys are the flag names, e.g. "addr" or "read-timeout".
+func loadFile(cfg *Config, path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	values := map[string]any{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(raw, &values)
+	case ".toml":
+		err = toml.Unmarshal(raw, &values)
+	default:
+		return fmt.Errorf("config file %s: unsupported extension %q, use .yaml, .yml or .toml", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	for key, value := range values {
+		s, ok := findSetting(key)
+		if !ok {
+			return fmt.Errorf("config file %s: unknown setting %q", path, key)
+		}
+		if err := s.set(cfg, fileValue(value)); err != nil {
+			return fmt.Errorf("config file %s: %s: %w", path, key, err)
+		}
+	}
+	return nil
+}
+
+// fileValue turns a decoded YAML/TOML value into the string form flags use;
+// lists become comma-separated
+func fileValue(value any) string {
+	if list, ok := value.([]any); ok {
+		items := make([]string, len(list))
+		for i, item := range list {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(value)
+}
+
+func findSetting(name string) (setting, bool) {
+	for _, s := range settings {
+		if s.name == name {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+// Validate reports every problem with the configuration at once
+func (c Config) Validate() error {
+	var errs []error
+
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		errs = append(errs, fmt.Errorf("addr %q: %w", c.Addr, err))
+	}
+
+	timeouts := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"read-timeout", c.ReadTimeout},
+		{"read-header-timeout", c.ReadHeaderTimeout},
+		{"write-timeout", c.WriteTimeout},
+		{"idle-timeout", c.IdleTimeout},
+		{"shutdown-timeout", c.ShutdownTimeout},
+	}
+	for _, t := range timeouts {
+		if t.d <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive, got %s", t.name, t.d))
+		}
+	}
+
+	switch c.Storage {
+	case StorageMemory:
+	case StorageSQLite:
+		if c.DSN == "" {
+			errs = append(errs, errors.New("dsn is required with sqlite storage"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("storage must be %q or %q, got %q", StorageMemory, StorageSQLite, c.Storage))
+	}
+
+	for _, origin := range c.CORSOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			errs = append(errs, fmt.Errorf("cors origin %q must look like https://host[:port]", origin))
+		}
+	}
+
+	if c.JWTSecret != "" && len(c.JWTSecret) < MinSecretLength {
+		errs = append(errs, fmt.Errorf("jwt-secret must be at least %d bytes", MinSecretLength))
+	}
+
+	if (c.AdminEmail == "") != (c.AdminPassword == "") {
+		errs = append(errs, errors.New("admin-email and admin-password must be set together"))
+	}
+
+	// Patterns are only checked by building the rule set
+	if _, err := ratelimit.NewRules(c.RateLimit, c.RateLimitRoutes); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Print writes the effective configuration as "name = value" lines,
+// with secrets redacted
+func (c Config) Print(w io.Writer) {
+	for _, s := range settings {
+		fmt.Fprintf(w, "%-20s = %s\n", s.name, s.display(c))
+	}
+}
+
+// LogValue lets slog log the config with secrets redacted
+func (c Config) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, len(settings))
+	for _, s := range settings {
+		attrs = append(attrs, slog.String(s.name, s.display(c)))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// display returns the value of s in c, or a placeholder for a set secret
+func (s setting) display(c Config) string {
+	value := s.get(c)
+	if s.secret && value != "" {
+		return "[REDACTED]"
+	}
+	return value
+}
+
+// splitList splits a comma-separated value, dropping empty entries
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,268 @@
+package config
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/manish-npx/go-lang/go-rest/ratelimit"
+)
+
+// clearEnv unsets every variable Load reads, for the rest of the test
+func clearEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", "")
+	for _, s := range settings {
+		t.Setenv(s.env, "")
+	}
+}
+
+// writeFile writes a config file named name and returns its path
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  string
+		flag string
+		want string
+	}{
+		{"default", "", "", "", ":8080"},
+		{"file over default", ":7001", "", "", ":7001"},
+		{"env over file", ":7001", ":7002", "", ":7002"},
+		{"flag over env", ":7001", ":7002", ":7003", ":7003"},
+		{"flag over file", ":7001", "", ":7003", ":7003"},
+		{"flag over default", "", "", ":7003", ":7003"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			var args []string
+			if tt.file != "" {
+				args = append(args, "-config", writeFile(t, "config.yaml", "addr: \""+tt.file+"\"\n"))
+			}
+			if tt.env != "" {
+				t.Setenv("ADDR", tt.env)
+			}
+			if tt.flag != "" {
+				args = append(args, "-addr", tt.flag)
+			}
+
+			cfg, err := Load(args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Addr != tt.want {
+				t.Fatalf("addr %q, want %q", cfg.Addr, tt.want)
+			}
+			// Settings set nowhere keep their default
+			if cfg.ShutdownTimeout != Default().ShutdownTimeout {
+				t.Fatalf("shutdown-timeout %s", cfg.ShutdownTimeout)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFileFromEnv(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("CONFIG_FILE", writeFile(t, "config.toml", `storage = "sqlite"`))
+	cfg, err := Load(nil)
+	if err != nil || cfg.Storage != StorageSQLite {
+		t.Fatalf("storage %q, %v", cfg.Storage, err)
+	}
+}
+
+func TestLoadFileFormats(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": `
+addr: ":9090"
+read-timeout: 2s
+log-level: debug
+storage: sqlite
+dsn: /var/lib/go-rest.db
+cors-origins:
+  - https://example.com
+  - http://localhost:3000
+rate-limit: 100/1m
+rate-limit-routes: ["POST /auth/login=3/1m"]
+`,
+		"config.yml": `
+addr: ":9090"
+read-timeout: "2s"
+log-level: DEBUG
+storage: "sqlite"
+dsn: "/var/lib/go-rest.db"
+cors-origins: [https://example.com, "http://localhost:3000"]
+rate-limit: "100/1m"
+rate-limit-routes: POST /auth/login=3/1m
+`,
+		"config.toml": `
+addr = ":9090"
+read-timeout = "2s"
+log-level = "debug"
+storage = "sqlite"
+dsn = "/var/lib/go-rest.db"
+cors-origins = ["https://example.com", "http://localhost:3000"]
+rate-limit = "100/1m"
+rate-limit-routes = ["POST /auth/login=3/1m"]
+`,
+	}
+
+	want := Default()
+	want.Addr = ":9090"
+	want.ReadTimeout = 2 * time.Second
+	want.LogLevel = slog.LevelDebug
+	want.Storage = StorageSQLite
+	want.DSN = "/var/lib/go-rest.db"
+	want.CORSOrigins = []string{"https://example.com", "http://localhost:3000"}
+	want.RateLimit = ratelimit.Rule{Requests: 100, Per: time.Minute}
+	want.RateLimitRoutes = []ratelimit.RouteRule{{Pattern: "POST /auth/login", Rule: ratelimit.Rule{Requests: 3, Per: time.Minute}}}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			clearEnv(t)
+			cfg, err := Load([]string{"-config", writeFile(t, name, content)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cfg, want) {
+				t.Fatalf("got  %+v\nwant %+v", cfg, want)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string // name and content, separated by the first newline
+		env  map[string]string
+		args []string
+		// err must be part of the error
+		err string
+	}{
+		{"unknown file key", "config.yaml\nport: 8080", nil, nil, `unknown setting "port"`},
+		{"unsupported file type", "config.json\n{}", nil, nil, `unsupported extension ".json"`},
+		{"malformed YAML", "config.yaml\naddr: [", nil, nil, "config.yaml"},
+		{"malformed TOML", "config.toml\naddr = ", nil, nil, "config.toml"},
+		{"bad value in the file", "config.toml\nread-timeout = \"soon\"", nil, nil, "read-timeout"},
+		{"missing file", "", nil, []string{"-config", "/does/not/exist.yaml"}, "config file"},
+		{"bad env value", "", map[string]string{"READ_TIMEOUT": "soon"}, nil, "env READ_TIMEOUT"},
+		{"bad flag value", "", nil, []string{"-log-level", "loud"}, "flag -log-level"},
+		{"invalid result", "", map[string]string{"STORAGE": "postgres"}, nil, `storage must be "memory" or "sqlite"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			args := tt.args
+			if tt.file != "" {
+				name, content, _ := strings.Cut(tt.file, "\n")
+				args = append(args, "-config", writeFile(t, name, content))
+			}
+			_, err := Load(args)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*Config)
+		// errs must each be part of the error, none when empty
+		errs []string
+	}{
+		{"default", func(*Config) {}, nil},
+		{"port only", func(c *Config) { c.Addr = ":0" }, nil},
+		{"no port", func(c *Config) { c.Addr = "localhost" }, []string{`addr "localhost"`}},
+		{"zero timeout", func(c *Config) { c.WriteTimeout = 0 }, []string{"write-timeout must be positive"}},
+		{"negative timeout", func(c *Config) { c.ShutdownTimeout = -time.Second }, []string{"shutdown-timeout must be positive"}},
+		{"unknown storage", func(c *Config) { c.Storage = "postgres" }, []string{"storage must be"}},
+		{"sqlite without dsn", func(c *Config) { c.Storage, c.DSN = StorageSQLite, "" }, []string{"dsn is required"}},
+		{"memory without dsn", func(c *Config) { c.DSN = "" }, nil},
+		{"any origin", func(c *Config) { c.CORSOrigins = []string{"*"} }, nil},
+		{"origin with a path", func(c *Config) { c.CORSOrigins = []string{"https://example.com/app"} }, []string{"cors origin"}},
+		{"origin without a scheme", func(c *Config) { c.CORSOrigins = []string{"example.com"} }, []string{"cors origin"}},
+		{"short secret", func(c *Config) { c.JWTSecret = "short" }, []string{"jwt-secret must be at least"}},
+		{"long secret", func(c *Config) { c.JWTSecret = strings.Repeat("s", MinSecretLength) }, nil},
+		{"admin email alone", func(c *Config) { c.AdminEmail = "root@example.com" }, []string{"set together"}},
+		{"admin password alone", func(c *Config) { c.AdminPassword = "password" }, []string{"set together"}},
+		{"bad route pattern", func(c *Config) {
+			c.RateLimitRoutes = []ratelimit.RouteRule{{Pattern: "POST", Rule: ratelimit.Rule{Requests: 1, Per: time.Second}}}
+		}, []string{"POST"}},
+		{"every problem at once", func(c *Config) {
+			c.Addr, c.IdleTimeout, c.Storage, c.JWTSecret = "nowhere", 0, "postgres", "short"
+		}, []string{"addr", "idle-timeout", "storage", "jwt-secret"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.change(&cfg)
+			err := cfg.Validate()
+			if len(tt.errs) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("no error, want %q", tt.errs)
+			}
+			for _, want := range tt.errs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSecretsAreRedacted(t *testing.T) {
+	cfg := Default()
+	cfg.JWTSecret = strings.Repeat("j", MinSecretLength)
+	cfg.AdminEmail = "root@example.com"
+	cfg.AdminPassword = "hunter2hunter2"
+
+	var logged bytes.Buffer
+	slog.New(slog.NewJSONHandler(&logged, nil)).Info("config", "config", cfg)
+	var printed bytes.Buffer
+	cfg.Print(&printed)
+
+	for name, out := range map[string]string{"LogValue": logged.String(), "Print": printed.String()} {
+		if strings.Contains(out, cfg.JWTSecret) || strings.Contains(out, cfg.AdminPassword) {
+			t.Errorf("%s shows a secret: %s", name, out)
+		}
+		if strings.Count(out, "[REDACTED]") != 2 {
+			t.Errorf("%s does not redact both secrets: %s", name, out)
+		}
+		// Other settings are shown as they are
+		if !strings.Contains(out, "root@example.com") || !strings.Contains(out, ":8080") {
+			t.Errorf("%s hides settings that are not secret: %s", name, out)
+		}
+	}
+
+	// An unset secret is shown as unset, not as redacted
+	var unset bytes.Buffer
+	slog.New(slog.NewJSONHandler(&unset, nil)).Info("config", "config", Default())
+	if strings.Contains(unset.String(), "[REDACTED]") || !strings.Contains(unset.String(), `"jwt-secret":""`) {
+		t.Errorf("unset secrets logged as %s", unset.String())
+	}
+}
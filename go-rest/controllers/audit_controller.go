@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/manish-npx/go-lang/go-rest/auth"
+	"github.com/manish-npx/go-lang/go-rest/events"
+	"github.com/manish-npx/go-lang/go-rest/models"
+	"github.com/manish-npx/go-lang/go-rest/policy"
+	"github.com/manish-npx/go-lang/go-rest/repository"
+)
+
+// AuditController serves GET /audit
+type AuditController struct {
+	records repository.AuditRepository
+}
+
+// NewAuditController returns an AuditController reading records
+func NewAuditController(records repository.AuditRepository) *AuditController {
+	return &AuditController{records: records}
+}
+
+// auditListSpec lists what GET /audit can sort and filter on
+var auditListSpec = listSpec[models.AuditRecord]{
+	defaultSort: "-id",
+	fields: map[string]listField[models.AuditRecord]{
+		"id": {
+			key:  func(a models.AuditRecord) string { return intKey(a.ID) },
+			text: func(a models.AuditRecord) string { return strconv.Itoa(a.ID) },
+		},
+		"time": {
+			key: func(a models.AuditRecord) string { return timeKey(a.Time) },
+		},
+		"actor_id": {
+			key:  func(a models.AuditRecord) string { return intKey(a.ActorID) },
+			text: func(a models.AuditRecord) string { return strconv.Itoa(a.ActorID) },
+		},
+		"action": {
+			text: func(a models.AuditRecord) string { return a.Action },
+		},
+		"resource": {
+			text: func(a models.AuditRecord) string { return a.Resource },
+		},
+		"resource_id": {
+			key:  func(a models.AuditRecord) string { return intKey(a.ResourceID) },
+			text: func(a models.AuditRecord) string { return strconv.Itoa(a.ResourceID) },
+		},
+	},
+}
+
+// GetAudit handles GET /audit, e.g. /audit?resource=blog&actor_id=2. Admins only.
+func (c *AuditController) GetAudit(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, policy.CanAudit(auth.Caller(r.Context()))) {
+		return
+	}
+
+	records, err := c.records.List(r.Context())
+	if err != nil {
+		storageError(w, r, err, "Audit record not found")
+		return
+	}
+
+	page, ok := paginate(w, r, records, auditListSpec)
+	if !ok {
+		return
+	}
+	writeList(w, r, page)
+}
+
+// auditor appends an audit record for every change the controllers make,
+// and publishes it to the live event stream
+type auditor struct {
+	records repository.AuditRepository
+	events  *events.Broadcaster
+}
+
+// Event topic and type published for each audited resource and action
+var (
+	eventTopics = map[string]string{
+		models.ResourceUser:    events.TopicUsers,
+		models.ResourceBlog:    events.TopicBlogs,
+		models.ResourceComment: events.TopicComments,
+	}
+	eventTypes = map[string]string{
+		models.AuditCreate:  events.Created,
+		models.AuditUpdate:  events.Updated,
+		models.AuditDelete:  events.Deleted,
+		models.AuditRestore: events.Restored,
+	}
+)
+
+// record appends that actorID did action to the resource with the given ID,
+// turning before and after (nil when there is none) into a field diff.
+// The change itself is already made by then, so a failure is logged
+// rather than sent to the client.
+func (a auditor) record(ctx context.Context, actorID int, action, resource string, id int, before, after any) {
+	record := models.AuditRecord{
+		Time:       time.Now().UTC(),
+		ActorID:    actorID,
+		Action:     action,
+		Resource:   resource,
+		ResourceID: id,
+		Changes:    diff(before, after),
+	}
+	if err := a.records.Append(ctx, &record); err != nil {
+		slog.ErrorContext(ctx, "audit record lost", "error", err, "action", action, "resource", resource, "resource_id", id)
+	}
+
+	// Only resources with a topic are streamed; webhooks are not
+	if topic, ok := eventTopics[resource]; ok && a.events != nil {
+		a.events.Publish(topic, eventTypes[action], id, publicView(after))
+	}
+}
+
+// publicView returns v as anyone may see it: the event stream is open to
+// all, so user emails are left out
+func publicView(v any) any {
+	if user, ok := v.(models.User); ok {
+		user.Email = ""
+		return user
+	}
+	return v
+}
+
+// recordCaller is record with the signed-in user as the actor
+func (a auditor) recordCaller(r *http.Request, action, resource string, id int, before, after any) {
+	actorID := 0
+	if caller := auth.Caller(r.Context()); caller != nil {
+		actorID = caller.ID
+	}
+	a.record(r.Context(), actorID, action, resource, id, before, after)
+}
+
+// diff compares the JSON fields of before and after. Fields hidden from
+// JSON, such as password hashes, never end up in the trail.
+func diff(before, after any) map[string]models.Change {
+	was, now := jsonFields(before), jsonFields(after)
+	changes := map[string]models.Change{}
+	for name, value := range now {
+		if !reflect.DeepEqual(was[name], value) {
+			changes[name] = models.Change{Before: was[name], After: value}
+		}
+	}
+	for name, value := range was {
+		if _, ok := now[name]; !ok {
+			changes[name] = models.Change{Before: value}
+		}
+	}
+	return changes
+}
+
+// jsonFields returns the fields of v as they would be sent as JSON
+func jsonFields(v any) map[string]any {
+	fields := map[string]any{}
+	if v == nil {
+		return fields
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fields
+	}
+	json.Unmarshal(raw, &fields)
+	return fields
+}
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/manish-npx/go-lang/go-rest/apierror"
+	"github.com/manish-npx/go-lang/go-rest/auth"
+	"github.com/manish-npx/go-lang/go-rest/events"
+	"github.com/manish-npx/go-lang/go-rest/models"
+	"github.com/manish-npx/go-lang/go-rest/repository"
+)
+
+// AuthController serves registration, login and token refresh
+type AuthController struct {
+	users  repository.UserRepository
+	tokens *auth.Tokens
+	audit  auditor
+}
+
+// NewAuthController returns an AuthController that issues tokens for users.
+// Sign-ups are recorded in audit and published to broadcaster.
+func NewAuthController(users repository.UserRepository, tokens *auth.Tokens, audit repository.AuditRepository, broadcaster *events.Broadcaster) *AuthController {
+	return &AuthController{users: users, tokens: tokens, audit: auditor{records: audit, events: broadcaster}}
+}
+
+// RegisterRequest is the body of POST /auth/register
+type RegisterRequest struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// LoginRequest is the body of POST /auth/login
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// RefreshRequest is the body of POST /auth/refresh
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Register handles POST /auth/register and creates a user with a password
+func (c *AuthController) Register(w http.ResponseWriter, r *http.Request) {
+	var req RegisterRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	// Everyone who signs up gets the default role; only admins hand out others
+	newUser := models.User{Name: req.Name, Email: req.Email, Role: models.DefaultRole}
+	errs := newUser.Validate()
+	if n := len(req.Password); n < auth.MinPasswordLength || n > auth.MaxPasswordLength {
+		errs = append(errs, models.FieldError{
+			Field:   "password",
+			Message: fmt.Sprintf("must be between %d and %d bytes", auth.MinPasswordLength, auth.MaxPasswordLength),
+		})
+	}
+	if validationFailed(w, r, errs) {
+		return
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal())
+		return
+	}
+	newUser.PasswordHash = hash
+
+	if err := c.users.Create(r.Context(), &newUser); err != nil {
+		userStorageError(w, r, err)
+		return
+	}
+	// Nobody is signed in yet: the new user is the one making the change
+	c.audit.record(r.Context(), newUser.ID, models.AuditCreate, models.ResourceUser, newUser.ID, nil, newUser)
+
+	w.Header().Set("Location", fmt.Sprintf("/users/%d", newUser.ID))
+	w.Header().Set("ETag", userETag(r, newUser))
+	respond(w, r, http.StatusCreated, newUser)
+}
+
+// Login handles POST /auth/login and trades an email and password for tokens
+func (c *AuthController) Login(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	user, err := c.users.GetByEmail(r.Context(), req.Email)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		apierror.Write(w, r, apierror.Internal())
+		return
+	}
+	// Same answer for an unknown email and a wrong password,
+	// so the endpoint cannot be used to find out who has an account
+	if err != nil || !auth.CheckPassword(user.PasswordHash, req.Password) {
+		apierror.Write(w, r, apierror.Unauthorized("Invalid email or password"))
+		return
+	}
+
+	c.issue(w, r, user.ID)
+}
+
+// Refresh handles POST /auth/refresh and trades a refresh token for a new pair
+func (c *AuthController) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	userID, err := c.tokens.Verify(req.RefreshToken, auth.TypeRefresh)
+	if err != nil {
+		apierror.Write(w, r, apierror.Unauthorized("Invalid or expired refresh token"))
+		return
+	}
+
+	// The user may have been deleted since the token was issued
+	if _, err := c.users.Get(r.Context(), userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.Unauthorized("Invalid or expired refresh token"))
+			return
+		}
+		apierror.Write(w, r, apierror.Internal())
+		return
+	}
+
+	c.issue(w, r, userID)
+}
+
+// issue sends a fresh token pair for userID
+func (c *AuthController) issue(w http.ResponseWriter, r *http.Request, userID int) {
+	pair, err := c.tokens.Issue(userID)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal())
+		return
+	}
+
+	// Tokens are credentials: never let a cache keep them
+	w.Header().Set("Cache-Control", "no-store")
+	respond(w, r, http.StatusOK, pair)
+}
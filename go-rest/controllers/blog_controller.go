@@ -1,19 +1,321 @@
 package controllers
 
 import (
-	"encoding/json"
+	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/manish-npx/go-lang/go-rest/apierror"
+	"github.com/manish-npx/go-lang/go-rest/auth"
+	"github.com/manish-npx/go-lang/go-rest/events"
 	"github.com/manish-npx/go-lang/go-rest/models"
+	"github.com/manish-npx/go-lang/go-rest/policy"
+	"github.com/manish-npx/go-lang/go-rest/repository"
 )
 
-var blogs = []models.Blog{
-	{ID: 1, Title: "New Blog Title-1"},
-	{ID: 2, Title: "New Blog Title-2"},
+// BlogController serves the /blogs routes.
+// It needs the users too, to check that every blog has a real author.
+type BlogController struct {
+	blogs    repository.BlogRepository
+	users    repository.UserRepository
+	searcher repository.BlogSearcher
+	audit    auditor
 }
 
-func GetBlogs(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set(CONTENT_TYPE, APPLICATION_JSON)
-	json.NewEncoder(w).Encode(blogs)
+// NewBlogController returns a BlogController backed by blogs and users,
+// searching blogs with searcher, recording every change in audit and
+// publishing it to broadcaster
+func NewBlogController(blogs repository.BlogRepository, users repository.UserRepository, searcher repository.BlogSearcher, audit repository.AuditRepository, broadcaster *events.Broadcaster) *BlogController {
+	return &BlogController{blogs: blogs, users: users, searcher: searcher, audit: auditor{records: audit, events: broadcaster}}
+}
+
+// blogListSpec lists what GET /blogs can sort and filter on
+var blogListSpec = listSpec[models.Blog]{
+	defaultSort: "id",
+	fields: map[string]listField[models.Blog]{
+		"id": {
+			key:  func(b models.Blog) string { return intKey(b.ID) },
+			text: func(b models.Blog) string { return strconv.Itoa(b.ID) },
+		},
+		"title": {
+			key:  func(b models.Blog) string { return strings.ToLower(b.Title) },
+			text: func(b models.Blog) string { return b.Title },
+		},
+		"body": {
+			text: func(b models.Blog) string { return b.Body },
+		},
+		"author_id": {
+			key:  func(b models.Blog) string { return intKey(b.AuthorID) },
+			text: func(b models.Blog) string { return strconv.Itoa(b.AuthorID) },
+		},
+		"created_at": {
+			key: func(b models.Blog) string { return timeKey(b.CreatedAt) },
+		},
+		"updated_at": {
+			key: func(b models.Blog) string { return timeKey(b.UpdatedAt) },
+		},
+	},
+}
+
+// GetBlogs handles GET /blogs, e.g. /blogs?sort=-created_at&title[contains]=go
+func (c *BlogController) GetBlogs(w http.ResponseWriter, r *http.Request) {
+	blogs, err := c.blogs.List(r.Context())
+	if err != nil {
+		storageError(w, r, err, "Blog not found")
+		return
+	}
+
+	c.writePage(w, r, blogs)
+}
+
+// GetBlogByID handles GET /blogs/{id}
+func (c *BlogController) GetBlogByID(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	blog, err := c.blogs.Get(r.Context(), id)
+	if err != nil {
+		storageError(w, r, err, "Blog not found")
+		return
+	}
+
+	if notModified(w, r, blogETag(r, blog)) {
+		return
+	}
+
+	respond(w, r, http.StatusOK, blog)
+}
+
+// GetUserBlogs handles GET /users/{id}/blogs and lists the blogs written by that user
+func (c *BlogController) GetUserBlogs(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := c.users.Get(r.Context(), id); err != nil {
+		storageError(w, r, err, "User not found")
+		return
+	}
+
+	authored, err := c.blogs.ListByAuthor(r.Context(), id)
+	if err != nil {
+		storageError(w, r, err, "Blog not found")
+		return
+	}
+
+	c.writePage(w, r, authored)
+}
+
+// writePage sends one page of blogs in the list envelope
+func (c *BlogController) writePage(w http.ResponseWriter, r *http.Request, blogs []models.Blog) {
+	page, ok := paginate(w, r, blogs, blogListSpec)
+	if !ok {
+		return
+	}
+
+	writeList(w, r, page)
+}
+
+// CreateBlog handles POST /blogs. The signed-in user is the author.
+func (c *BlogController) CreateBlog(w http.ResponseWriter, r *http.Request) {
+	var newBlog models.Blog
+	if !decodeJSON(w, r, &newBlog) {
+		return
+	}
+
+	// author_id may be left out; if it is sent it must be the caller
+	caller := auth.Caller(r.Context())
+	if newBlog.AuthorID == 0 && caller != nil {
+		newBlog.AuthorID = caller.ID
+	}
+	if caller == nil || newBlog.AuthorID != caller.ID {
+		apierror.Write(w, r, apierror.Forbidden("You can only publish blogs as yourself"))
+		return
+	}
+	if !authorize(w, r, policy.CanBlog(caller, policy.Create, newBlog)) {
+		return
+	}
+
+	if validationFailed(w, r, newBlog.Validate()) {
+		return
+	}
+
+	if newBlog.Tags == nil {
+		newBlog.Tags = []string{}
+	}
+	newBlog.CreatedAt = time.Now().UTC()
+	newBlog.UpdatedAt = newBlog.CreatedAt
+	// Blogs are only ever deleted through DELETE /blogs/{id}
+	newBlog.DeletedAt = nil
+	if err := c.blogs.Create(r.Context(), &newBlog); err != nil {
+		storageError(w, r, err, "Blog not found")
+		return
+	}
+	c.audit.recordCaller(r, models.AuditCreate, models.ResourceBlog, newBlog.ID, nil, newBlog)
+
+	w.Header().Set("Location", fmt.Sprintf("/blogs/%d", newBlog.ID))
+	w.Header().Set("ETag", blogETag(r, newBlog))
+	respond(w, r, http.StatusCreated, newBlog)
+}
+
+// UpdateBlog handles PUT /blogs/{id} and replaces the whole blog
+func (c *BlogController) UpdateBlog(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	stored, ok := c.authorizedBlog(w, r, policy.Update, id)
+	if !ok {
+		return
+	}
+
+	var updated models.Blog
+	if !decodeJSON(w, r, &updated) {
+		return
+	}
+	// author_id may be left out of a PUT; it cannot be changed anyway
+	if updated.AuthorID == 0 {
+		updated.AuthorID = stored.AuthorID
+	}
+
+	c.saveBlog(w, r, stored, updated)
+}
+
+// PatchBlog handles PATCH /blogs/{id} and only changes the fields sent
+func (c *BlogController) PatchBlog(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	stored, ok := c.authorizedBlog(w, r, policy.Update, id)
+	if !ok {
+		return
+	}
+
+	// Decoding on top of the stored blog keeps every field the body leaves
+	// out. The tags get their own array, or decoding would overwrite the
+	// stored ones in place and the audit entry would show the wrong before.
+	patched := stored
+	patched.Tags = slices.Clone(stored.Tags)
+	if !decodeJSON(w, r, &patched) {
+		return
+	}
+
+	c.saveBlog(w, r, stored, patched)
+}
+
+// DeleteBlog handles DELETE /blogs/{id}. The blog is only marked deleted
+// and can be brought back with POST /blogs/{id}/restore.
+func (c *BlogController) DeleteBlog(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	stored, ok := c.authorizedBlog(w, r, policy.Delete, id)
+	if !ok {
+		return
+	}
+
+	if err := c.blogs.Delete(r.Context(), id, stored.Version); err != nil {
+		storageError(w, r, err, "Blog not found")
+		return
+	}
+	deleted, err := c.blogs.GetDeleted(r.Context(), id)
+	if err != nil {
+		storageError(w, r, err, "Blog not found")
+		return
+	}
+	c.audit.recordCaller(r, models.AuditDelete, models.ResourceBlog, id, stored, deleted)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RestoreBlog handles POST /blogs/{id}/restore and undoes a delete
+func (c *BlogController) RestoreBlog(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	deleted, err := c.blogs.GetDeleted(r.Context(), id)
+	if err != nil {
+		storageError(w, r, err, "No deleted blog with this id")
+		return
+	}
+	if !authorize(w, r, policy.CanBlog(auth.Caller(r.Context()), policy.Restore, deleted)) {
+		return
+	}
+
+	if err := c.blogs.Restore(r.Context(), id); err != nil {
+		storageError(w, r, err, "No deleted blog with this id")
+		return
+	}
+	restored, err := c.blogs.Get(r.Context(), id)
+	if err != nil {
+		storageError(w, r, err, "Blog not found")
+		return
+	}
+	c.audit.recordCaller(r, models.AuditRestore, models.ResourceBlog, id, deleted, restored)
+
+	w.Header().Set("ETag", blogETag(r, restored))
+	respond(w, r, http.StatusOK, restored)
+}
+
+// saveBlog stores blog after validating it, keeping the server-owned
+// fields (ID, CreatedAt and Version) from the stored copy. Blogs cannot
+// change author.
+func (c *BlogController) saveBlog(w http.ResponseWriter, r *http.Request, stored, blog models.Blog) {
+	if validationFailed(w, r, blog.Validate()) {
+		return
+	}
+	if blog.AuthorID != stored.AuthorID {
+		apierror.Write(w, r, apierror.Forbidden("The author of a blog cannot be changed"))
+		return
+	}
+
+	blog.ID = stored.ID
+	if blog.Tags == nil {
+		blog.Tags = []string{}
+	}
+	blog.CreatedAt = stored.CreatedAt
+	blog.UpdatedAt = time.Now().UTC()
+	// Deleting goes through DELETE, with its own policy and audit entry
+	blog.DeletedAt = stored.DeletedAt
+	// A concurrent update since the If-Match check gives 412
+	blog.Version = stored.Version
+	if err := c.blogs.Update(r.Context(), &blog); err != nil {
+		storageError(w, r, err, "Blog not found")
+		return
+	}
+	c.audit.recordCaller(r, models.AuditUpdate, models.ResourceBlog, blog.ID, stored, blog)
+
+	w.Header().Set("ETag", blogETag(r, blog))
+	respond(w, r, http.StatusOK, blog)
+}
 
+// authorizedBlog loads the blog with the given ID and checks that the
+// caller may perform action on it and that If-Match, if sent, names its
+// current ETag. Otherwise it writes a 404, 403 or 412.
+func (c *BlogController) authorizedBlog(w http.ResponseWriter, r *http.Request, action policy.Action, id int) (models.Blog, bool) {
+	blog, err := c.blogs.Get(r.Context(), id)
+	if err != nil {
+		storageError(w, r, err, "Blog not found")
+		return models.Blog{}, false
+	}
+	if !authorize(w, r, policy.CanBlog(auth.Caller(r.Context()), action, blog)) {
+		return models.Blog{}, false
+	}
+	if preconditionFailed(w, r, blogETag(r, blog)) {
+		return models.Blog{}, false
+	}
+	return blog, true
 }
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/manish-npx/go-lang/go-rest/apierror"
+	"github.com/manish-npx/go-lang/go-rest/models"
+	"github.com/manish-npx/go-lang/go-rest/search"
+)
+
+// BlogSearchResult is one blog found by GET /blogs/search
+type BlogSearchResult struct {
+	models.Blog
+	// Score is the relevance of the blog to the query; higher is better
+	Score float64 `json:"score"`
+	// Highlights has a snippet of every field that matched ("title", "body"
+	// or "tags"). Snippets are HTML-escaped, with matches in <mark></mark>.
+	Highlights map[string]string `json:"highlights"`
+}
+
+// blogSearchSpec lists what GET /blogs/search can sort and filter on
+var blogSearchSpec = listSpec[BlogSearchResult]{
+	defaultSort: "-score",
+	params:      []string{"q"},
+	fields: map[string]listField[BlogSearchResult]{
+		"score": {
+			key: func(b BlogSearchResult) string { return fmt.Sprintf("%020.4f", b.Score) },
+		},
+		"id": {
+			key:  func(b BlogSearchResult) string { return intKey(b.ID) },
+			text: func(b BlogSearchResult) string { return strconv.Itoa(b.ID) },
+		},
+		"author_id": {
+			key:  func(b BlogSearchResult) string { return intKey(b.AuthorID) },
+			text: func(b BlogSearchResult) string { return strconv.Itoa(b.AuthorID) },
+		},
+		"created_at": {
+			key: func(b BlogSearchResult) string { return timeKey(b.CreatedAt) },
+		},
+	},
+}
+
+// SearchBlogs handles GET /blogs/search?q=..., e.g. ?q="rest api" gopher*
+func (c *BlogController) SearchBlogs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	if strings.TrimSpace(query) == "" {
+		apierror.Write(w, r, apierror.BadRequest("q is required"))
+		return
+	}
+
+	hits, err := c.searcher.Search(r.Context(), query)
+	if errors.Is(err, search.ErrEmptyQuery) {
+		apierror.Write(w, r, apierror.BadRequest("q must contain at least one word"))
+		return
+	}
+	if err != nil {
+		storageError(w, r, err, "Blog not found")
+		return
+	}
+
+	// Hits only carry IDs: join them with the blogs themselves
+	blogs, err := c.blogs.List(r.Context())
+	if err != nil {
+		storageError(w, r, err, "Blog not found")
+		return
+	}
+	byID := make(map[int]models.Blog, len(blogs))
+	for _, blog := range blogs {
+		byID[blog.ID] = blog
+	}
+
+	results := []BlogSearchResult{}
+	for _, hit := range hits {
+		blog, ok := byID[hit.ID]
+		if !ok {
+			continue // deleted since the search
+		}
+		results = append(results, BlogSearchResult{
+			Blog:       blog,
+			Score:      math.Round(hit.Score*10000) / 10000,
+			Highlights: hit.Highlights,
+		})
+	}
+
+	page, ok := paginate(w, r, results, blogSearchSpec)
+	if !ok {
+		return
+	}
+	writeList(w, r, page)
+}
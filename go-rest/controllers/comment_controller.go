@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/manish-npx/go-lang/go-rest/apierror"
+	"github.com/manish-npx/go-lang/go-rest/auth"
+	"github.com/manish-npx/go-lang/go-rest/events"
+	"github.com/manish-npx/go-lang/go-rest/live"
+	"github.com/manish-npx/go-lang/go-rest/models"
+	"github.com/manish-npx/go-lang/go-rest/policy"
+	"github.com/manish-npx/go-lang/go-rest/repository"
+)
+
+// CommentController handles the comments of blogs, over REST and WebSocket
+type CommentController struct {
+	comments repository.CommentRepository
+	blogs    repository.BlogRepository
+	audit    auditor
+	// hubs has one room per blog, keyed by blog ID
+	hubs     *live.Hubs
+	upgrader websocket.Upgrader
+}
+
+// commentListSpec lists what GET /blogs/{id}/comments can sort and filter on
+var commentListSpec = listSpec[models.Comment]{
+	defaultSort: "id",
+	fields: map[string]listField[models.Comment]{
+		"id": {
+			key:  func(c models.Comment) string { return intKey(c.ID) },
+			text: func(c models.Comment) string { return strconv.Itoa(c.ID) },
+		},
+		"author_id": {
+			key:  func(c models.Comment) string { return intKey(c.AuthorID) },
+			text: func(c models.Comment) string { return strconv.Itoa(c.AuthorID) },
+		},
+		"created_at": {
+			key: func(c models.Comment) string { return timeKey(c.CreatedAt) },
+		},
+	},
+}
+
+// NewCommentController returns a CommentController storing comments of
+// blogs and pushing new ones to the readers connected to hubs. Changes
+// are recorded in audit and published to broadcaster.
+//
+// WebSocket connections are only accepted from pages served by the API's
+// own host, or from clients that send no Origin, such as other servers.
+func NewCommentController(comments repository.CommentRepository, blogs repository.BlogRepository, hubs *live.Hubs, audit repository.AuditRepository, broadcaster *events.Broadcaster) *CommentController {
+	return &CommentController{
+		comments: comments,
+		blogs:    blogs,
+		audit:    auditor{records: audit, events: broadcaster},
+		hubs:     hubs,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			Error:           upgradeError,
+		},
+	}
+}
+
+// GetComments handles GET /blogs/{id}/comments
+func (c *CommentController) GetComments(w http.ResponseWriter, r *http.Request) {
+	blog, ok := c.blog(w, r)
+	if !ok {
+		return
+	}
+
+	comments, err := c.comments.ListByBlog(r.Context(), blog.ID)
+	if err != nil {
+		storageError(w, r, err, "Comment not found")
+		return
+	}
+
+	page, ok := paginate(w, r, comments, commentListSpec)
+	if !ok {
+		return
+	}
+	writeList(w, r, page)
+}
+
+// CreateComment handles POST /blogs/{id}/comments. The signed-in user is
+// the author; readers connected to the blog get the comment right away.
+func (c *CommentController) CreateComment(w http.ResponseWriter, r *http.Request) {
+	blog, ok := c.blog(w, r)
+	if !ok {
+		return
+	}
+
+	var comment models.Comment
+	if !decodeJSON(w, r, &comment) {
+		return
+	}
+
+	// Everything but the body is set by the server
+	caller := auth.Caller(r.Context())
+	comment = models.Comment{BlogID: blog.ID, Body: comment.Body, CreatedAt: time.Now().UTC()}
+	if caller != nil {
+		comment.AuthorID = caller.ID
+	}
+	if !authorize(w, r, policy.CanComment(caller, policy.Create, comment)) {
+		return
+	}
+	if validationFailed(w, r, comment.Validate()) {
+		return
+	}
+
+	if err := c.comments.Create(r.Context(), &comment); err != nil {
+		storageError(w, r, err, "Blog not found")
+		return
+	}
+	c.audit.recordCaller(r, models.AuditCreate, models.ResourceComment, comment.ID, nil, comment)
+
+	if message, err := json.Marshal(comment); err == nil {
+		c.hubs.Broadcast(blog.ID, message)
+	}
+
+	respond(w, r, http.StatusCreated, comment)
+}
+
+// LiveComments handles GET /blogs/{id}/comments/live. It upgrades to a
+// WebSocket on which every new comment of the blog is pushed as a JSON
+// message, like the ones POST /blogs/{id}/comments answers with.
+func (c *CommentController) LiveComments(w http.ResponseWriter, r *http.Request) {
+	blog, ok := c.blog(w, r)
+	if !ok {
+		return
+	}
+
+	// Upgrade answers the client itself when it fails
+	conn, err := c.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c.hubs.Serve(blog.ID, conn)
+}
+
+// blog loads the blog of the {id} wildcard. On failure it writes a 400
+// or 404 response and returns ok=false.
+func (c *CommentController) blog(w http.ResponseWriter, r *http.Request) (models.Blog, bool) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return models.Blog{}, false
+	}
+	blog, err := c.blogs.Get(r.Context(), id)
+	if err != nil {
+		storageError(w, r, err, "Blog not found")
+		return models.Blog{}, false
+	}
+	return blog, true
+}
+
+// upgradeError sends failed WebSocket handshakes in the JSON error envelope
+func upgradeError(w http.ResponseWriter, r *http.Request, status int, reason error) {
+	code := apierror.CodeBadRequest
+	switch status {
+	case http.StatusForbidden:
+		code = apierror.CodeForbidden
+	case http.StatusMethodNotAllowed:
+		code = apierror.CodeMethodNotAllowed
+	}
+	apierror.Write(w, r, apierror.New(status, code, "WebSocket handshake failed: "+reason.Error()))
+}
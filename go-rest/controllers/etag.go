@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/manish-npx/go-lang/go-rest/apierror"
+	"github.com/manish-npx/go-lang/go-rest/models"
+	"github.com/manish-npx/go-lang/go-rest/render"
+)
+
+// STALE_MESSAGE is sent with 412 when If-Match does not name the current version
+const STALE_MESSAGE = "The resource was changed since you read it; fetch it again and retry"
+
+// Conditional requests
+//
+// Single resources get a strong ETag made of their version, e.g. "v3",
+// and of the format when it is not JSON, e.g. "v3-xml": each format is
+// another representation, so it needs another strong ETag. Lists get the
+// hash of the page, which includes the version of every item.
+// GETs answer 304 Not Modified when If-None-Match names the current ETag;
+// PUT, PATCH and DELETE answer 412 Precondition Failed when If-Match is sent
+// and does not. Without If-Match writes go through as before.
+
+// blogETag returns the ETag of blog in the format negotiated for r
+func blogETag(r *http.Request, blog models.Blog) string {
+	return versionETag(r, fmt.Sprintf("v%d", blog.Version))
+}
+
+// userETag returns the ETag of user as shown to the caller, in the format
+// negotiated for r. Callers who cannot see the email get another
+// representation, hence another ETag.
+func userETag(r *http.Request, user models.User) string {
+	if user.Email == "" {
+		return versionETag(r, fmt.Sprintf("v%d-public", user.Version))
+	}
+	return versionETag(r, fmt.Sprintf("v%d", user.Version))
+}
+
+// versionETag quotes tag, followed by the name of the format negotiated
+// for r unless it is JSON
+func versionETag(r *http.Request, tag string) string {
+	if format := render.FromContext(r.Context()); format.Name != render.JSON.Name {
+		tag += "-" + format.Name
+	}
+	return `"` + tag + `"`
+}
+
+// notModified sets the ETag header and, when If-None-Match names etag,
+// answers 304 Not Modified and returns true
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, etag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// preconditionFailed answers 412 and returns true when If-Match is sent
+// and does not name etag, the ETag of the resource about to be changed
+func preconditionFailed(w http.ResponseWriter, r *http.Request, etag string) bool {
+	match := r.Header.Get("If-Match")
+	if match == "" || etagMatches(match, etag, false) {
+		return false
+	}
+	apierror.Write(w, r, apierror.PreconditionFailed(STALE_MESSAGE))
+	return true
+}
+
+// etagMatches reports whether header, a comma separated list of ETags or
+// "*", names etag. If-None-Match compares weakly (W/"x" matches "x"),
+// If-Match strongly (weak ETags never match).
+func etagMatches(header, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeList sends a page of a list endpoint with an ETag made from its
+// content, or 304 when the client already has that exact page.
+// The next page is also linked in a Link header, for formats like CSV
+// that only carry the items.
+func writeList[T any](w http.ResponseWriter, r *http.Request, page List[T]) {
+	body, format, ok := encode(w, r, page)
+	if !ok {
+		return
+	}
+	// Each format gives another body, hence another ETag
+	sum := sha256.Sum256(body)
+	if notModified(w, r, `"`+hex.EncodeToString(sum[:16])+`"`) {
+		return
+	}
+
+	if page.Next != "" {
+		w.Header().Set("Link", "<"+page.Next+`>; rel="next"`)
+	}
+	w.Header().Set(CONTENT_TYPE, format.MediaType)
+	w.Write(body)
+}
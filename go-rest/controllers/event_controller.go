@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/manish-npx/go-lang/go-rest/apierror"
+	"github.com/manish-npx/go-lang/go-rest/events"
+)
+
+// Timing of the event stream
+const (
+	// HEARTBEAT_INTERVAL is how often a comment is sent on a quiet stream,
+	// so proxies do not close it and dead clients are noticed
+	HEARTBEAT_INTERVAL = 15 * time.Second
+	// EVENT_WRITE_TIMEOUT bounds each write to a stream. It replaces the
+	// server write timeout, which would cut every stream short.
+	EVENT_WRITE_TIMEOUT = 10 * time.Second
+	// EVENT_RETRY is how long clients should wait before reconnecting
+	EVENT_RETRY = 2 * time.Second
+)
+
+// EventController serves the live stream of changes
+type EventController struct {
+	events *events.Broadcaster
+}
+
+// NewEventController returns an EventController streaming what
+// broadcaster publishes
+func NewEventController(broadcaster *events.Broadcaster) *EventController {
+	return &EventController{events: broadcaster}
+}
+
+// Stream handles GET /events, a Server-Sent Events stream of changes to
+// users, blogs and comments:
+//
+//	id: 42
+//	event: blogs.created
+//	data: {"id":42,"topic":"blogs","type":"created","resource_id":7,...}
+//
+// ?topics=users,blogs picks the topics (all by default). A client that
+// reconnects with Last-Event-ID first gets the events it missed; when
+// they are no longer all in the log it gets a "reset" event instead of
+// the ones that are gone, and should reload what it shows.
+func (c *EventController) Stream(w http.ResponseWriter, r *http.Request) {
+	var topics []string
+	if param := r.URL.Query().Get("topics"); param != "" {
+		for _, topic := range strings.Split(param, ",") {
+			topic = strings.TrimSpace(topic)
+			if !events.ValidTopic(topic) {
+				apierror.Write(w, r, apierror.BadRequest(fmt.Sprintf(
+					"Unknown topic %q, use %s", topic, strings.Join(events.Topics, " or "))))
+				return
+			}
+			topics = append(topics, topic)
+		}
+	}
+	lastID, err := events.ParseID(r.Header.Get("Last-Event-ID"))
+	if err != nil || lastID < -1 {
+		apierror.Write(w, r, apierror.BadRequest("Last-Event-ID must be the id of an event"))
+		return
+	}
+
+	sub, backlog, complete := c.events.Subscribe(topics, lastID)
+	defer sub.Close()
+
+	w.Header().Set(CONTENT_TYPE, "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	// Stops nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	stream := eventStream{w: w, rc: http.NewResponseController(w)}
+	if !stream.write(fmt.Sprintf("retry: %d\n\n", EVENT_RETRY.Milliseconds())) {
+		return
+	}
+	if !complete && !stream.write("event: reset\ndata: {}\n\n") {
+		return
+	}
+	for _, e := range backlog {
+		if !stream.send(e) {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(HEARTBEAT_INTERVAL)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.C:
+			// Closed on shutdown, or because we were too slow: the
+			// client reconnects and catches up with Last-Event-ID
+			if !ok || !stream.send(e) {
+				return
+			}
+		case <-heartbeat.C:
+			if !stream.write(": heartbeat\n\n") {
+				return
+			}
+		}
+	}
+}
+
+// eventStream writes Server-Sent Events, flushing each one
+type eventStream struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func (s eventStream) send(e events.Event) bool {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return false
+	}
+	return s.write(fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Name(), data))
+}
+
+// write sends text and reports whether the client is still there
+func (s eventStream) write(text string) bool {
+	// Not every writer supports deadlines; the server timeout applies then
+	s.rc.SetWriteDeadline(time.Now().Add(EVENT_WRITE_TIMEOUT))
+	if _, err := io.WriteString(s.w, text); err != nil {
+		return false
+	}
+	return s.rc.Flush() == nil
+}
@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/manish-npx/go-lang/go-rest/events"
+)
+
+// sseMessage is one message of an event stream
+type sseMessage struct {
+	id, event, data string
+}
+
+// openStream connects to the event stream of srv with the given query
+// and Last-Event-ID, and returns a function reading its next message
+func openStream(t *testing.T, srv *httptest.Server, query, lastID string) func() (sseMessage, error) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/events"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	res, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+	if res.StatusCode != http.StatusOK || res.Header.Get(CONTENT_TYPE) != "text/event-stream" {
+		t.Fatalf("status %d, %s", res.StatusCode, res.Header.Get(CONTENT_TYPE))
+	}
+
+	lines := bufio.NewScanner(res.Body)
+	return func() (sseMessage, error) {
+		var msg sseMessage
+		for lines.Scan() {
+			field, value, _ := strings.Cut(lines.Text(), ": ")
+			switch field {
+			case "":
+				return msg, nil
+			case "id":
+				msg.id = value
+			case "event":
+				msg.event = value
+			case "data":
+				msg.data = value
+			case "retry":
+				msg.event = "retry"
+			}
+		}
+		if err := lines.Err(); err != nil {
+			return msg, err
+		}
+		return msg, io.EOF
+	}
+}
+
+// names reads n messages and returns their event names
+func names(t *testing.T, next func() (sseMessage, error), n int) []string {
+	t.Helper()
+	var got []string
+	for range n {
+		msg, err := next()
+		if err != nil {
+			t.Fatalf("after %v: %v", got, err)
+		}
+		got = append(got, msg.event)
+	}
+	return got
+}
+
+func newStreamServer(t *testing.T, logSize int) (*httptest.Server, *events.Broadcaster) {
+	t.Helper()
+	b := events.NewBroadcaster(logSize)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /events", NewEventController(b).Stream)
+	srv := httptest.NewServer(mux)
+	// Cleanups run last first: the streams end before the server closes,
+	// which would otherwise wait for them
+	t.Cleanup(srv.Close)
+	t.Cleanup(b.Close)
+	return srv, b
+}
+
+func TestStreamResumesWithLastEventID(t *testing.T) {
+	srv, b := newStreamServer(t, events.DEFAULT_LOG_SIZE)
+	b.Publish(events.TopicBlogs, events.Created, 1, map[string]string{"title": "First"})
+	b.Publish(events.TopicUsers, events.Created, 1, nil)
+	b.Publish(events.TopicBlogs, events.Updated, 1, map[string]string{"title": "Second"})
+	b.Publish(events.TopicBlogs, events.Deleted, 1, nil)
+
+	next := openStream(t, srv, "?topics=blogs", "1")
+	if got, want := names(t, next, 3), []string{"retry", "blogs.updated", "blogs.deleted"}; !slices.Equal(got, want) {
+		t.Fatalf("resumed stream %v, want %v", got, want)
+	}
+
+	// Then live events of the topic, and not the others
+	b.Publish(events.TopicUsers, events.Updated, 1, nil)
+	b.Publish(events.TopicBlogs, events.Restored, 1, map[string]string{"title": "Back"})
+	msg, err := next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e events.Event
+	if err := json.Unmarshal([]byte(msg.data), &e); err != nil {
+		t.Fatalf("data %q: %v", msg.data, err)
+	}
+	if msg.id != "6" || msg.event != "blogs.restored" || e.ID != 6 || e.Data.(map[string]any)["title"] != "Back" {
+		t.Fatalf("live message %+v", msg)
+	}
+}
+
+func TestStreamResetsWhenEventsWereMissed(t *testing.T) {
+	srv, b := newStreamServer(t, 2)
+	for i := range 4 {
+		b.Publish(events.TopicComments, events.Created, i, nil)
+	}
+
+	next := openStream(t, srv, "", "1")
+	if got, want := names(t, next, 4), []string{"retry", "reset", "comments.created", "comments.created"}; !slices.Equal(got, want) {
+		t.Fatalf("stream %v, want %v", got, want)
+	}
+}
+
+func TestStreamEndsOnShutdown(t *testing.T) {
+	srv, b := newStreamServer(t, events.DEFAULT_LOG_SIZE)
+	next := openStream(t, srv, "", "")
+	names(t, next, 1) // retry
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := next()
+		done <- err
+	}()
+	b.Close()
+	select {
+	case err := <-done:
+		if err != io.EOF {
+			t.Fatalf("stream ended with %v, want io.EOF", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stream still open a second after Close")
+	}
+}
+
+func TestStreamRejectsBadRequests(t *testing.T) {
+	srv, _ := newStreamServer(t, events.DEFAULT_LOG_SIZE)
+	tests := []struct {
+		query, lastID string
+	}{
+		{"?topics=blogs,posts", ""},
+		{"", "abc"},
+		{"", "-2"},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/events"+tt.query, nil)
+		req.Header.Set("Last-Event-ID", tt.lastID)
+		res, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s with Last-Event-ID %q: status %d, want 400", tt.query, tt.lastID, res.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/manish-npx/go-lang/go-rest/apierror"
+)
+
+// READY_TIMEOUT bounds how long /readyz waits for the storage backend
+const READY_TIMEOUT = 2 * time.Second
+
+// Pinger is a backend that can tell whether it is reachable,
+// such as *repository.Store
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// HealthController serves the probes used by load balancers and orchestrators
+type HealthController struct {
+	storage Pinger
+}
+
+// NewHealthController returns a HealthController that checks storage
+func NewHealthController(storage Pinger) *HealthController {
+	return &HealthController{storage: storage}
+}
+
+// HealthStatus is the body of a successful probe
+type HealthStatus struct {
+	Status string `json:"status"`
+}
+
+// Healthz handles GET /healthz. It only says the process is up and serving.
+func (c *HealthController) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeStatus(w, r, "ok")
+}
+
+// Readyz handles GET /readyz. It answers 503 while the storage backend
+// cannot be reached, so no traffic is sent our way until it can.
+func (c *HealthController) Readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), READY_TIMEOUT)
+	defer cancel()
+
+	if err := c.storage.Ping(ctx); err != nil {
+		apierror.Write(w, r, apierror.Unavailable("Storage is not reachable"))
+		return
+	}
+	writeStatus(w, r, "ready")
+}
+
+func writeStatus(w http.ResponseWriter, r *http.Request, status string) {
+	// Probes must never be answered from a cache
+	w.Header().Set("Cache-Control", "no-store")
+	respond(w, r, http.StatusOK, HealthStatus{Status: status})
+}
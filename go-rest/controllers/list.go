@@ -0,0 +1,284 @@
+package controllers
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/manish-npx/go-lang/go-rest/apierror"
+)
+
+// Page size used when ?limit= is missing, and the largest one allowed
+const (
+	DEFAULT_LIMIT = 20
+	MAX_LIMIT     = 100
+)
+
+// List is the envelope every list endpoint answers with.
+//
+// Offset is the position of the first item of Data in the whole (filtered
+// and sorted) result. Next is a ready-to-follow link to the next page, and
+// NextCursor the cursor inside it; both are empty on the last page.
+type List[T any] struct {
+	Data       []T    `json:"data"`
+	Total      int    `json:"total"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Next       string `json:"next,omitempty"`
+}
+
+// Rows makes the items the CSV form of a page, see render.Tabular.
+// The rest of the page is in the Link header.
+func (l List[T]) Rows() any {
+	return l.Data
+}
+
+// listField describes a field of T that clients can sort or filter on
+type listField[T any] struct {
+	// key returns a string that sorts the same way as the field,
+	// see intKey and timeKey. Nil means the field is not sortable.
+	key func(T) string
+	// text returns the value filters compare against.
+	// Nil means the field is not filterable.
+	text func(T) string
+}
+
+// listSpec is what a list endpoint supports
+type listSpec[T any] struct {
+	fields map[string]listField[T]
+	// defaultSort is used when ?sort= is missing
+	defaultSort string
+	// params are query parameters the handler reads itself, e.g. ?q=,
+	// so they are not taken for filters
+	params []string
+}
+
+// sortKey is one entry of ?sort=, e.g. "-id" is {field: "id", desc: true}
+type sortKey struct {
+	field string
+	desc  bool
+}
+
+// cursor marks the last item of a page. Keys are that item's sort keys,
+// so the next page starts right after it even if items were added or
+// removed in between. Sort makes sure the cursor is used with the same order.
+type cursor struct {
+	Sort string   `json:"s"`
+	Keys []string `json:"k"`
+}
+
+// listParams are the query parameters that are not filters
+var listParams = []string{"limit", "offset", "cursor", "sort", "format"}
+
+// paginate filters, sorts and slices items according to the query string:
+//
+//	?limit=10&offset=20            offset pagination
+//	?limit=10&cursor=...           cursor pagination (cursor from next_cursor)
+//	?sort=name,-id                 sort by name, then by id descending
+//	?email=bob@example.com         exact match (case-insensitive)
+//	?title[contains]=go            substring match (case-insensitive)
+//
+// On a bad parameter it writes a 400 response and returns ok=false.
+func paginate[T any](w http.ResponseWriter, r *http.Request, items []T, spec listSpec[T]) (List[T], bool) {
+	query := r.URL.Query()
+
+	limit, err := intParam(query, "limit", DEFAULT_LIMIT)
+	if err != nil || limit < 1 || limit > MAX_LIMIT {
+		apierror.Write(w, r, apierror.BadRequest(fmt.Sprintf("limit must be a number between 1 and %d", MAX_LIMIT)))
+		return List[T]{}, false
+	}
+	offset, err := intParam(query, "offset", 0)
+	if err != nil || offset < 0 {
+		apierror.Write(w, r, apierror.BadRequest("offset must be a non-negative number"))
+		return List[T]{}, false
+	}
+	if query.Has("offset") && query.Has("cursor") {
+		apierror.Write(w, r, apierror.BadRequest("Use either offset or cursor, not both"))
+		return List[T]{}, false
+	}
+
+	// Filters: every parameter that is not a list parameter
+	for param, values := range query {
+		if slices.Contains(listParams, param) || slices.Contains(spec.params, param) {
+			continue
+		}
+		name, op := param, "eq"
+		if i := strings.IndexByte(param, '['); i > 0 && strings.HasSuffix(param, "]") {
+			name, op = param[:i], param[i+1:len(param)-1]
+		}
+		field, known := spec.fields[name]
+		if !known || field.text == nil {
+			apierror.Write(w, r, apierror.BadRequest(fmt.Sprintf("Unknown filter %q", name)))
+			return List[T]{}, false
+		}
+		if op != "eq" && op != "contains" {
+			apierror.Write(w, r, apierror.BadRequest(fmt.Sprintf("Unknown filter operator %q, use eq or contains", op)))
+			return List[T]{}, false
+		}
+		items = filterItems(items, field.text, op, values[0])
+	}
+
+	// Sorting: the requested keys, then id so the order is always total
+	sortParam := query.Get("sort")
+	if sortParam == "" {
+		sortParam = spec.defaultSort
+	}
+	keys, err := parseSort(sortParam, spec.fields)
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest(err.Error()))
+		return List[T]{}, false
+	}
+	slices.SortStableFunc(items, func(a, b T) int {
+		return compareKeys(keys, sortValues(keys, spec.fields, a), sortValues(keys, spec.fields, b))
+	})
+
+	// Where the page starts: at the offset, or right after the cursor
+	start := offset
+	if query.Has("cursor") {
+		after, err := decodeCursor(query.Get("cursor"), sortParam)
+		if err != nil || len(after.Keys) != len(keys) {
+			apierror.Write(w, r, apierror.BadRequest("Invalid cursor"))
+			return List[T]{}, false
+		}
+		start, _ = slices.BinarySearchFunc(items, after.Keys, func(item T, target []string) int {
+			if compareKeys(keys, sortValues(keys, spec.fields, item), target) <= 0 {
+				return -1
+			}
+			return 1
+		})
+	}
+
+	total := len(items)
+	start = min(start, total)
+	end := min(start+limit, total)
+
+	list := List[T]{
+		Data:   items[start:end],
+		Total:  total,
+		Limit:  limit,
+		Offset: start,
+	}
+
+	if end < total {
+		next := url.Values{}
+		for k, v := range query {
+			next[k] = v
+		}
+		next.Set("limit", strconv.Itoa(limit))
+		// Keep using offsets when the client asked with one, cursors otherwise
+		if query.Has("offset") {
+			next.Set("offset", strconv.Itoa(end))
+		} else {
+			list.NextCursor = encodeCursor(cursor{Sort: sortParam, Keys: sortValues(keys, spec.fields, items[end-1])})
+			next.Set("cursor", list.NextCursor)
+		}
+		list.Next = r.URL.Path + "?" + next.Encode()
+	}
+
+	return list, true
+}
+
+// intParam reads an integer query parameter, or returns fallback when it is missing
+func intParam(query url.Values, name string, fallback int) (int, error) {
+	if !query.Has(name) {
+		return fallback, nil
+	}
+	return strconv.Atoi(query.Get(name))
+}
+
+// filterItems keeps the items whose text matches value with op
+func filterItems[T any](items []T, text func(T) string, op, value string) []T {
+	value = strings.ToLower(value)
+	kept := []T{}
+	for _, item := range items {
+		got := strings.ToLower(text(item))
+		if (op == "eq" && got == value) || (op == "contains" && strings.Contains(got, value)) {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// parseSort turns "name,-id" into sort keys, appending "id" as a tie-breaker
+func parseSort[T any](param string, fields map[string]listField[T]) ([]sortKey, error) {
+	var keys []sortKey
+	hasID := false
+	for _, name := range strings.Split(param, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		key := sortKey{field: strings.TrimPrefix(name, "-"), desc: strings.HasPrefix(name, "-")}
+		if f, ok := fields[key.field]; !ok || f.key == nil {
+			return nil, fmt.Errorf("Cannot sort by %q", key.field)
+		}
+		hasID = hasID || key.field == "id"
+		keys = append(keys, key)
+	}
+	if !hasID {
+		keys = append(keys, sortKey{field: "id"})
+	}
+	return keys, nil
+}
+
+// sortValues returns the sort keys of item, in the order of keys
+func sortValues[T any](keys []sortKey, fields map[string]listField[T], item T) []string {
+	values := make([]string, len(keys))
+	for i, k := range keys {
+		values[i] = fields[k.field].key(item)
+	}
+	return values
+}
+
+// compareKeys compares two sets of sort values, honouring descending keys
+func compareKeys(keys []sortKey, a, b []string) int {
+	for i, k := range keys {
+		c := cmp.Compare(a[i], b[i])
+		if k.desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func encodeCursor(c cursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeCursor parses a cursor and checks that it was made for sortParam
+func decodeCursor(s, sortParam string) (cursor, error) {
+	var c cursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return c, err
+	}
+	if c.Sort != sortParam {
+		return c, fmt.Errorf("cursor was made for sort %q", c.Sort)
+	}
+	return c, nil
+}
+
+// intKey formats a non-negative int so that string order matches number order
+func intKey(n int) string {
+	return fmt.Sprintf("%020d", n)
+}
+
+// timeKey formats t so that string order matches time order
+func timeKey(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000000000Z")
+}
@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+type item struct {
+	ID   int
+	Name string
+}
+
+var itemSpec = listSpec[item]{
+	defaultSort: "id",
+	fields: map[string]listField[item]{
+		"id": {
+			key:  func(i item) string { return intKey(i.ID) },
+			text: func(i item) string { return strconv.Itoa(i.ID) },
+		},
+		"name": {
+			key:  func(i item) string { return i.Name },
+			text: func(i item) string { return i.Name },
+		},
+	},
+}
+
+// makeItems returns items with the given IDs, named after them
+func makeItems(ids ...int) []item {
+	items := make([]item, len(ids))
+	for i, id := range ids {
+		items[i] = item{ID: id, Name: "item " + strconv.Itoa(id)}
+	}
+	return items
+}
+
+// listItems runs paginate on items for the query string, returning the page
+// and the status it wrote, 200 when it wrote nothing
+func listItems(t *testing.T, items []item, query string) (List[item], int) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/items?"+query, nil)
+	page, ok := paginate(w, r, slices.Clone(items), itemSpec)
+	if ok != (w.Code == http.StatusOK) {
+		t.Fatalf("paginate(%q) returned ok=%v but wrote %d", query, ok, w.Code)
+	}
+	return page, w.Code
+}
+
+func itemIDs(page List[item]) []int {
+	var ids []int
+	for _, i := range page.Data {
+		ids = append(ids, i.ID)
+	}
+	return ids
+}
+
+func TestPaginateLimitBounds(t *testing.T) {
+	items := makeItems(1, 2, 3)
+	tests := []struct {
+		query     string
+		wantCode  int
+		wantLimit int
+	}{
+		{"", http.StatusOK, DEFAULT_LIMIT},
+		{"limit=1", http.StatusOK, 1},
+		{"limit=" + strconv.Itoa(MAX_LIMIT), http.StatusOK, MAX_LIMIT},
+		{"limit=0", http.StatusBadRequest, 0},
+		{"limit=-1", http.StatusBadRequest, 0},
+		{"limit=" + strconv.Itoa(MAX_LIMIT+1), http.StatusBadRequest, 0},
+		{"limit=ten", http.StatusBadRequest, 0},
+		{"offset=-1", http.StatusBadRequest, 0},
+		{"offset=1&cursor=abc", http.StatusBadRequest, 0},
+		{"offset=10", http.StatusOK, DEFAULT_LIMIT},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			page, code := listItems(t, items, tt.query)
+			if code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", code, tt.wantCode)
+			}
+			if code == http.StatusOK && page.Limit != tt.wantLimit {
+				t.Errorf("limit = %d, want %d", page.Limit, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestPaginateInvalidCursor(t *testing.T) {
+	items := makeItems(1, 2, 3, 4)
+	first, _ := listItems(t, items, "limit=2")
+	if first.NextCursor == "" {
+		t.Fatal("first page has no next cursor")
+	}
+	b64 := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	tests := map[string]string{
+		"not base64":       "!!!",
+		"not json":         b64("not json"),
+		"wrong sort":       first.NextCursor + "&sort=name",
+		"too few keys":     b64(`{"s":"id","k":[]}`),
+		"too many keys":    b64(`{"s":"id","k":["1","2"]}`),
+		"tampered":         first.NextCursor[:len(first.NextCursor)-2],
+		"sort for another": b64(`{"s":"-id","k":["00000000000000000002"]}`),
+	}
+	for name, c := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, code := listItems(t, items, "limit=2&cursor="+c); code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400", code)
+			}
+		})
+	}
+}
+
+func TestPaginateCursorStableAcrossChanges(t *testing.T) {
+	tests := []struct {
+		name string
+		sort string
+		// before is the data the first page is read from, after the data
+		// the second page is read from
+		before, after []int
+		first, second []int
+	}{
+		{
+			name:   "insert before the cursor",
+			before: []int{2, 4, 6, 8, 10},
+			after:  []int{1, 2, 3, 4, 6, 8, 10},
+			first:  []int{2, 4},
+			second: []int{6, 8},
+		},
+		{
+			name:   "insert after the cursor",
+			before: []int{2, 4, 6, 8, 10},
+			after:  []int{2, 4, 5, 6, 8, 10},
+			first:  []int{2, 4},
+			second: []int{5, 6},
+		},
+		{
+			name:   "delete the cursor item",
+			before: []int{2, 4, 6, 8, 10},
+			after:  []int{2, 6, 8, 10},
+			first:  []int{2, 4},
+			second: []int{6, 8},
+		},
+		{
+			name:   "delete before and after the cursor",
+			before: []int{2, 4, 6, 8, 10},
+			after:  []int{4, 8, 10},
+			first:  []int{2, 4},
+			second: []int{8, 10},
+		},
+		{
+			name:   "descending",
+			sort:   "-id",
+			before: []int{2, 4, 6, 8, 10},
+			after:  []int{1, 2, 4, 6, 9, 10, 11},
+			first:  []int{10, 8},
+			second: []int{6, 4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := url.Values{"limit": {"2"}}
+			if tt.sort != "" {
+				query.Set("sort", tt.sort)
+			}
+			first, code := listItems(t, makeItems(tt.before...), query.Encode())
+			if code != http.StatusOK || !slices.Equal(itemIDs(first), tt.first) {
+				t.Fatalf("first page = %v (%d), want %v", itemIDs(first), code, tt.first)
+			}
+
+			query.Set("cursor", first.NextCursor)
+			second, code := listItems(t, makeItems(tt.after...), query.Encode())
+			if code != http.StatusOK || !slices.Equal(itemIDs(second), tt.second) {
+				t.Fatalf("second page = %v (%d), want %v", itemIDs(second), code, tt.second)
+			}
+		})
+	}
+}
+
+func TestPaginateWalksEveryItemOnce(t *testing.T) {
+	items := makeItems(5, 3, 9, 1, 7, 2, 8)
+	for i := range items {
+		// Names tie in pairs, so the id tie-breaker decides
+		items[i].Name = "name " + strconv.Itoa(items[i].ID/2)
+	}
+
+	var seen []int
+	query := url.Values{"limit": {"3"}, "sort": {"-name"}}
+	for range len(items) {
+		page, code := listItems(t, items, query.Encode())
+		if code != http.StatusOK {
+			t.Fatalf("status = %d", code)
+		}
+		seen = append(seen, itemIDs(page)...)
+		if page.NextCursor == "" {
+			break
+		}
+		query.Set("cursor", page.NextCursor)
+	}
+
+	want := []int{8, 9, 7, 5, 2, 3, 1}
+	if !slices.Equal(seen, want) {
+		t.Fatalf("walked %v, want %v", seen, want)
+	}
+}
@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/manish-npx/go-lang/go-rest/apierror"
+	"github.com/manish-npx/go-lang/go-rest/models"
+	"github.com/manish-npx/go-lang/go-rest/repository"
+)
+
+// MAX_BODY_BYTES caps the size of a JSON request body (1 MiB)
+const MAX_BODY_BYTES = 1 << 20
+
+// decodeJSON strictly decodes the request body into dst.
+// It rejects a missing or non-JSON Content-Type (415), bodies over
+// MAX_BODY_BYTES (413), and empty, malformed or trailing JSON and
+// unknown fields (400). On failure it writes the response and returns false.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get(CONTENT_TYPE))
+	if err != nil || mediaType != APPLICATION_JSON {
+		apierror.Write(w, r, apierror.New(http.StatusUnsupportedMediaType,
+			apierror.CodeUnsupportedMediaType, "Content-Type must be application/json"))
+		return false
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, MAX_BODY_BYTES)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		apierror.Write(w, r, decodeError(err))
+		return false
+	}
+
+	// The body must hold exactly one JSON value
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		apierror.Write(w, r, apierror.BadRequest("Request body must contain a single JSON object"))
+		return false
+	}
+
+	return true
+}
+
+// decodeError turns a json.Decoder error into an API error whose
+// message is safe to show to the client
+func decodeError(err error) *apierror.Error {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		maxErr    *http.MaxBytesError
+	)
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return apierror.BadRequest("Request body must not be empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return apierror.BadRequest("Request body contains malformed JSON")
+	case errors.As(err, &syntaxErr):
+		return apierror.BadRequest(fmt.Sprintf("Request body contains malformed JSON at position %d", syntaxErr.Offset))
+	case errors.As(err, &typeErr):
+		return apierror.BadRequest(fmt.Sprintf("Field %q must be of type %s", typeErr.Field, typeErr.Type))
+	case errors.As(err, &maxErr):
+		return apierror.New(http.StatusRequestEntityTooLarge, apierror.CodePayloadTooLarge,
+			fmt.Sprintf("Request body must not be larger than %d bytes", maxErr.Limit))
+	default:
+		// json reports unknown fields as `json: unknown field "x"`
+		var field string
+		if _, scanErr := fmt.Sscanf(err.Error(), "json: unknown field %q", &field); scanErr == nil {
+			return apierror.BadRequest(fmt.Sprintf("Unknown field %q", field))
+		}
+		return apierror.BadRequest("Invalid request body")
+	}
+}
+
+// validationFailed writes a 422 response listing every invalid field in
+// the error details and returns true, or returns false when errs is empty
+func validationFailed(w http.ResponseWriter, r *http.Request, errs []models.FieldError) bool {
+	if len(errs) == 0 {
+		return false
+	}
+
+	apierror.Write(w, r, apierror.ValidationFailed(errs))
+	return true
+}
+
+// authorize writes a 403 and returns false when allowed is false.
+// Use it with the policy package: authorize(w, r, policy.CanBlog(...)).
+func authorize(w http.ResponseWriter, r *http.Request, allowed bool) bool {
+	if !allowed {
+		apierror.Write(w, r, apierror.Forbidden("You are not allowed to do this"))
+	}
+	return allowed
+}
+
+// pathID reads the {id} wildcard from the route pattern.
+// On failure it writes a 400 response and returns ok=false.
+func pathID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("Invalid id"))
+		return 0, false
+	}
+	return id, true
+}
+
+// storageError turns a repository error into a response:
+// 404 with notFound as the message for ErrNotFound, 412 for
+// ErrVersionMismatch, 500 for anything else.
+func storageError(w http.ResponseWriter, r *http.Request, err error, notFound string) {
+	if errors.Is(err, repository.ErrNotFound) {
+		apierror.Write(w, r, apierror.NotFound(notFound))
+		return
+	}
+	if errors.Is(err, repository.ErrVersionMismatch) {
+		apierror.Write(w, r, apierror.PreconditionFailed(STALE_MESSAGE))
+		return
+	}
+	apierror.Write(w, r, apierror.Internal())
+}
@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+
+	"github.com/manish-npx/go-lang/go-rest/apierror"
+	"github.com/manish-npx/go-lang/go-rest/render"
+)
+
+// respond sends v with status, in the format negotiated for r (JSON
+// unless the route is wrapped in render.Negotiated)
+func respond(w http.ResponseWriter, r *http.Request, status int, v any) {
+	body, format, ok := encode(w, r, v)
+	if !ok {
+		return
+	}
+	w.Header().Set(CONTENT_TYPE, format.MediaType)
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// encode renders v in the format negotiated for r. The body is built in
+// memory first so that a failure can still be answered with a 500; then
+// it writes that response and returns ok=false.
+func encode(w http.ResponseWriter, r *http.Request, v any) (body []byte, format render.Format, ok bool) {
+	format = render.FromContext(r.Context())
+	var b bytes.Buffer
+	if err := format.Encode(&b, v); err != nil {
+		slog.ErrorContext(r.Context(), "encoding response", "format", format.Name, "error", err)
+		apierror.Write(w, r, apierror.Internal())
+		return nil, format, false
+	}
+	return b.Bytes(), format, true
+}
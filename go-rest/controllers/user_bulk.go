@@ -0,0 +1,438 @@
+package controllers
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/manish-npx/go-lang/go-rest/apierror"
+	"github.com/manish-npx/go-lang/go-rest/auth"
+	"github.com/manish-npx/go-lang/go-rest/models"
+	"github.com/manish-npx/go-lang/go-rest/policy"
+	"github.com/manish-npx/go-lang/go-rest/render"
+	"github.com/manish-npx/go-lang/go-rest/repository"
+)
+
+// Media types of bulk bodies
+const (
+	APPLICATION_NDJSON = "application/x-ndjson"
+	TEXT_CSV           = "text/csv"
+)
+
+// Bulk import and export tuning
+const (
+	// MAX_BULK_BYTES caps the body of POST /users:bulk (32 MiB)
+	MAX_BULK_BYTES = 32 << 20
+	// BULK_BATCH_SIZE is how many users are inserted per transaction
+	BULK_BATCH_SIZE = 500
+	// EXPORT_PAGE_SIZE is how many users GET /users:export reads at a time
+	EXPORT_PAGE_SIZE = 500
+	// EXPORT_WRITE_TIMEOUT bounds the write of each page of an export. It
+	// replaces the server write timeout, which would cut big exports short.
+	EXPORT_WRITE_TIMEOUT = 30 * time.Second
+)
+
+// Outcomes of a bulk import row
+const (
+	BulkCreated = "created"
+	BulkFailed  = "failed"
+	// BulkRolledBack rows were valid, but another row of an atomic import failed
+	BulkRolledBack = "rolled_back"
+)
+
+// userCSVColumns are the columns of a user export, in order. Imports take
+// any of them; id and version are set by the server and ignored.
+var userCSVColumns = []string{"id", "name", "email", "role", "version"}
+
+// exportFormats are the formats GET /users:export can stream. They are
+// only used to negotiate: the handler writes them itself, a page at a time.
+var exportFormats = render.NewRegistry(
+	render.Format{Name: "ndjson", MediaType: APPLICATION_NDJSON},
+	render.CSV,
+)
+
+// BulkReport is the answer to POST /users:bulk
+type BulkReport struct {
+	// Atomic is true for an all-or-nothing import
+	Atomic  bool `json:"atomic"`
+	Total   int  `json:"total"`
+	Created int  `json:"created"`
+	Failed  int  `json:"failed"`
+	// Results has the outcome of every row, in the order they were sent
+	Results []BulkRow `json:"rows"`
+}
+
+// Rows makes the CSV form of a report one line per row
+func (b BulkReport) Rows() any {
+	return b.Results
+}
+
+// BulkRow is the outcome of one row of a bulk import
+type BulkRow struct {
+	// Line is where the row starts in the body, counting from 1
+	Line   int    `json:"line"`
+	Status string `json:"status"`
+	Email  string `json:"email,omitempty"`
+	// ID is the ID of the user created from the row
+	ID     int                 `json:"id,omitempty"`
+	Errors []models.FieldError `json:"errors,omitempty"`
+}
+
+// BulkCreateUsers handles POST /users:bulk. Admins only.
+//
+// The body is NDJSON (one user object per line) or CSV (a header row
+// naming the columns, then one user per row), with the fields of
+// POST /users. Every row is validated; valid ones are inserted
+// BULK_BATCH_SIZE at a time while the body is still being read, and the
+// answer reports the outcome of each row.
+//
+// With ?atomic=true no user is created unless every row can be: the rows
+// are held until the end of the body and inserted in one transaction.
+//
+// Emails already in use fail their row, so an import that stopped half
+// way can simply be sent again.
+func (c *UserController) BulkCreateUsers(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, policy.CanBulkUsers(auth.Caller(r.Context()))) {
+		return
+	}
+	atomic, err := strconv.ParseBool(cmp.Or(r.URL.Query().Get("atomic"), "false"))
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("atomic must be true or false"))
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, MAX_BULK_BYTES)
+	rows, ok := newUserRows(w, r)
+	if !ok {
+		return
+	}
+
+	report := BulkReport{Atomic: atomic, Results: []BulkRow{}}
+	// batch holds the valid users not inserted yet, pending their index in report.Results
+	var (
+		batch   []models.User
+		pending []int
+	)
+	for {
+		user, line, errs, err := rows.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// The body cannot be read any further: keep what was read so far
+			report.Results = append(report.Results, BulkRow{Line: line, Status: BulkFailed, Errors: []models.FieldError{
+				{Field: "row", Message: readError(err)},
+			}})
+			break
+		}
+
+		// Everything but these fields is set by the server
+		user = models.User{Name: user.Name, Email: user.Email, Role: cmp.Or(user.Role, models.DefaultRole)}
+		if len(errs) == 0 {
+			errs = user.Validate()
+		}
+		row := BulkRow{Line: line, Email: user.Email}
+		if len(errs) > 0 {
+			row.Status, row.Errors = BulkFailed, errs
+			report.Results = append(report.Results, row)
+			continue
+		}
+		report.Results = append(report.Results, row)
+		batch = append(batch, user)
+		pending = append(pending, len(report.Results)-1)
+
+		if !atomic && len(batch) == BULK_BATCH_SIZE {
+			if !c.insertBatch(w, r, &report, batch, pending) {
+				return
+			}
+			batch, pending = batch[:0], pending[:0]
+		}
+	}
+
+	if atomic && slices.ContainsFunc(report.Results, func(row BulkRow) bool { return row.Status == BulkFailed }) {
+		// Nothing was inserted yet, so there is nothing to undo
+		for _, i := range pending {
+			report.Results[i].Status = BulkRolledBack
+		}
+	} else if len(batch) > 0 && !c.insertBatch(w, r, &report, batch, pending) {
+		return
+	}
+
+	report.Total = len(report.Results)
+	for _, row := range report.Results {
+		switch row.Status {
+		case BulkCreated:
+			report.Created++
+		case BulkFailed:
+			report.Failed++
+		}
+	}
+	respond(w, r, http.StatusOK, report)
+}
+
+// insertBatch creates the users of batch, whose rows are report.Results[pending[i]],
+// and records the outcome of each row. In an atomic import, one duplicate
+// email rolls the whole batch back. On a storage failure it writes a 500
+// and returns false.
+func (c *UserController) insertBatch(w http.ResponseWriter, r *http.Request, report *BulkReport, batch []models.User, pending []int) bool {
+	errs, err := c.users.CreateMany(r.Context(), batch, report.Atomic)
+	if err != nil {
+		storageError(w, r, err, "User not found")
+		return false
+	}
+	rolledBack := report.Atomic && slices.ContainsFunc(errs, func(err error) bool { return err != nil })
+
+	for i, user := range batch {
+		row := &report.Results[pending[i]]
+		switch {
+		case errors.Is(errs[i], repository.ErrConflict):
+			row.Status = BulkFailed
+			row.Errors = []models.FieldError{{Field: "email", Message: "is already in use"}}
+		case errs[i] != nil:
+			row.Status = BulkFailed
+			row.Errors = []models.FieldError{{Field: "row", Message: "could not be stored"}}
+		case rolledBack:
+			row.Status = BulkRolledBack
+		default:
+			row.Status, row.ID = BulkCreated, user.ID
+			c.audit.recordCaller(r, models.AuditCreate, models.ResourceUser, user.ID, nil, user)
+		}
+	}
+	return true
+}
+
+// ExportUsers handles GET /users:export. Admins only. Every user is
+// streamed as NDJSON (the default) or as CSV with ?format=csv or
+// Accept: text/csv, EXPORT_PAGE_SIZE users at a time, so the export
+// never holds them all in memory. The CSV can be imported again as is.
+//
+// Once the first page is sent the status cannot change anymore: if the
+// storage fails later, the export just ends early and the failure is logged.
+func (c *UserController) ExportUsers(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, policy.CanBulkUsers(auth.Caller(r.Context()))) {
+		return
+	}
+	format, err := exportFormats.Negotiate(r)
+	if err != nil {
+		apierror.Write(w, r, apierror.NotAcceptable("Exports are available as ndjson or csv"))
+		return
+	}
+
+	// The first page is read before answering, so an early failure can
+	// still get a proper error response
+	users, err := c.users.ListAfter(r.Context(), 0, EXPORT_PAGE_SIZE)
+	if err != nil {
+		storageError(w, r, err, "User not found")
+		return
+	}
+
+	w.Header().Set(CONTENT_TYPE, format.MediaType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="users.%s"`, format.Name))
+	w.Header().Set("Vary", "Accept")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	page := userPageWriter(w, format)
+	for {
+		// Not every writer supports deadlines; the server timeout applies then
+		rc.SetWriteDeadline(time.Now().Add(EXPORT_WRITE_TIMEOUT))
+		if err := page(users); err != nil {
+			return
+		}
+		if rc.Flush() != nil || len(users) < EXPORT_PAGE_SIZE {
+			return
+		}
+
+		if users, err = c.users.ListAfter(r.Context(), users[len(users)-1].ID, EXPORT_PAGE_SIZE); err != nil {
+			slog.ErrorContext(r.Context(), "user export cut short", "error", err)
+			return
+		}
+	}
+}
+
+// userPageWriter returns a function writing a page of users to w in format
+func userPageWriter(w io.Writer, format render.Format) func([]models.User) error {
+	if format.Name != render.CSV.Name {
+		enc := json.NewEncoder(w)
+		return func(users []models.User) error {
+			for _, user := range users {
+				if err := enc.Encode(user); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	header := true
+	return func(users []models.User) error {
+		if header {
+			cw.Write(userCSVColumns)
+			header = false
+		}
+		for _, user := range users {
+			cw.Write([]string{
+				strconv.Itoa(user.ID), render.CSVText(user.Name), render.CSVText(user.Email), user.Role, strconv.Itoa(user.Version),
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+}
+
+// userRows reads the users of a bulk import body, one row at a time
+type userRows interface {
+	// next returns the next user and the line it starts on. A row that
+	// cannot be parsed gives errs, and the following rows can still be
+	// read; err means the body cannot be read any further (io.EOF at
+	// its end).
+	next() (user models.User, line int, errs []models.FieldError, err error)
+}
+
+// newUserRows returns the reader of the body of r, picked by its
+// Content-Type. On failure it writes a 400 or 415 response and returns
+// ok=false.
+func newUserRows(w http.ResponseWriter, r *http.Request) (userRows, bool) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get(CONTENT_TYPE))
+	switch mediaType {
+	case APPLICATION_NDJSON:
+		scanner := bufio.NewScanner(r.Body)
+		scanner.Buffer(make([]byte, 0, 64<<10), MAX_BODY_BYTES)
+		return &ndjsonRows{scanner: scanner}, true
+	case TEXT_CSV:
+		rows, err := newCSVRows(r.Body)
+		if err != nil {
+			apierror.Write(w, r, apierror.BadRequest(err.Error()))
+			return nil, false
+		}
+		return rows, true
+	default:
+		apierror.Write(w, r, apierror.New(http.StatusUnsupportedMediaType, apierror.CodeUnsupportedMediaType,
+			"Content-Type must be "+APPLICATION_NDJSON+" or "+TEXT_CSV))
+		return nil, false
+	}
+}
+
+// ndjsonRows reads one JSON user per line. Blank lines are skipped.
+type ndjsonRows struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (n *ndjsonRows) next() (models.User, int, []models.FieldError, error) {
+	for n.scanner.Scan() {
+		n.line++
+		data := bytes.TrimSpace(n.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		var user models.User
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&user); err != nil {
+			return models.User{}, n.line, []models.FieldError{{Field: "row", Message: decodeError(err).Message}}, nil
+		}
+		if dec.More() {
+			return models.User{}, n.line, []models.FieldError{{Field: "row", Message: "Line must contain a single JSON object"}}, nil
+		}
+		return user, n.line, nil, nil
+	}
+	if err := n.scanner.Err(); err != nil {
+		return models.User{}, n.line + 1, nil, err
+	}
+	return models.User{}, n.line, nil, io.EOF
+}
+
+// csvRows reads one user per CSV record, after a header row naming the
+// columns, some of userCSVColumns in any order
+type csvRows struct {
+	reader  *csv.Reader
+	columns []string
+	line    int
+}
+
+func newCSVRows(body io.Reader) (*csvRows, error) {
+	reader := csv.NewReader(body)
+	header, err := reader.Read()
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return nil, errors.New(readError(err))
+		}
+		return nil, errors.New("CSV body must start with a header row")
+	}
+
+	columns := make([]string, len(header))
+	for i, name := range header {
+		// Spreadsheets may start the file with a byte order mark
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !slices.Contains(userCSVColumns, name) {
+			return nil, fmt.Errorf("Unknown CSV column %q, use %s", name, strings.Join(userCSVColumns, ", "))
+		}
+		if slices.Contains(columns, name) {
+			return nil, fmt.Errorf("CSV column %q appears twice", name)
+		}
+		columns[i] = name
+	}
+	for _, required := range []string{"name", "email"} {
+		if !slices.Contains(columns, required) {
+			return nil, fmt.Errorf("CSV column %q is required", required)
+		}
+	}
+	return &csvRows{reader: reader, columns: columns, line: 1}, nil
+}
+
+func (c *csvRows) next() (models.User, int, []models.FieldError, error) {
+	record, err := c.reader.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		c.line = parseErr.Line
+		return models.User{}, parseErr.StartLine, []models.FieldError{{Field: "row", Message: parseErr.Err.Error()}}, nil
+	}
+	if err != nil {
+		return models.User{}, c.line + 1, nil, err
+	}
+
+	c.line, _ = c.reader.FieldPos(len(record) - 1)
+	line, _ := c.reader.FieldPos(0)
+	var user models.User
+	for i, column := range c.columns {
+		value := render.ParseCSVText(strings.TrimSpace(record[i]))
+		switch column {
+		case "name":
+			user.Name = value
+		case "email":
+			user.Email = value
+		case "role":
+			user.Role = value
+		}
+	}
+	return user, line, nil, nil
+}
+
+// readError describes why a bulk body could not be read to the end
+func readError(err error) string {
+	var maxErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxErr):
+		return decodeError(err).Message
+	case errors.Is(err, bufio.ErrTooLong):
+		return fmt.Sprintf("Line must not be longer than %d bytes", MAX_BODY_BYTES)
+	default:
+		return "Request body could not be read"
+	}
+}
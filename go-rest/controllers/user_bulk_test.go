@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/manish-npx/go-lang/go-rest/models"
+	"github.com/manish-npx/go-lang/go-rest/render"
+)
+
+// parsedRow is what userRows.next gives for one row
+type parsedRow struct {
+	line   int
+	name   string
+	email  string
+	role   string
+	errors []string
+}
+
+// readRows reads every row of rows, and the error that ended them
+func readRows(rows userRows) ([]parsedRow, error) {
+	var parsed []parsedRow
+	for {
+		user, line, errs, err := rows.next()
+		if err != nil {
+			return parsed, err
+		}
+		row := parsedRow{line: line, name: user.Name, email: user.Email, role: user.Role}
+		for _, e := range errs {
+			row.errors = append(row.errors, e.Field+": "+e.Message)
+		}
+		parsed = append(parsed, row)
+	}
+}
+
+func equalRows(a, b parsedRow) bool {
+	return a.line == b.line && a.name == b.name && a.email == b.email && a.role == b.role &&
+		len(a.errors) == len(b.errors) && slices.EqualFunc(a.errors, b.errors, strings.HasPrefix)
+}
+
+func ndjson(body string) *ndjsonRows {
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64<<10), MAX_BODY_BYTES)
+	return &ndjsonRows{scanner: scanner}
+}
+
+func TestNDJSONRows(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []parsedRow
+	}{
+		{"empty", "", nil},
+		{"one per line", "{\"name\":\"Ann\",\"email\":\"ann@example.com\"}\n{\"name\":\"Ben\",\"email\":\"ben@example.com\",\"role\":\"editor\"}", []parsedRow{
+			{line: 1, name: "Ann", email: "ann@example.com"},
+			{line: 2, name: "Ben", email: "ben@example.com", role: "editor"},
+		}},
+		{"blank lines and CRLF", "\r\n  \n{\"name\":\"Ann\"}\r\n\n", []parsedRow{
+			{line: 3, name: "Ann"},
+		}},
+		{"malformed lines do not stop the import", "{\"name\":\n{\"name\":\"Ben\"}\nnot json\n[1]\n{\"name\":\"Cy\"}", []parsedRow{
+			{line: 1, errors: []string{"row: "}},
+			{line: 2, name: "Ben"},
+			{line: 3, errors: []string{"row: "}},
+			{line: 4, errors: []string{"row: "}},
+			{line: 5, name: "Cy"},
+		}},
+		{"unknown field", `{"name":"Ann","password":"x"}`, []parsedRow{
+			{line: 1, errors: []string{"row: "}},
+		}},
+		{"two objects on a line", `{"name":"Ann"} {"name":"Ben"}`, []parsedRow{
+			{line: 1, errors: []string{"row: Line must contain a single JSON object"}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readRows(ndjson(tt.body))
+			if !errors.Is(err, io.EOF) {
+				t.Fatalf("rows ended with %v, want io.EOF", err)
+			}
+			if !slices.EqualFunc(got, tt.want, equalRows) {
+				t.Fatalf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNDJSONLineTooLong(t *testing.T) {
+	body := "{\"name\":\"Ann\"}\n{\"name\":\"" + strings.Repeat("x", MAX_BODY_BYTES) + "\"}\n{\"name\":\"Ben\"}"
+	got, err := readRows(ndjson(body))
+	if !errors.Is(err, bufio.ErrTooLong) || len(got) != 1 {
+		t.Fatalf("rows %+v ended with %v, want one row then bufio.ErrTooLong", got, err)
+	}
+	if msg := readError(err); !strings.Contains(msg, "longer than") {
+		t.Fatalf("readError = %q", msg)
+	}
+	if _, line, _, _ := ndjson(body).next(); line != 1 {
+		t.Fatalf("first row on line %d", line)
+	}
+}
+
+func TestCSVHeader(t *testing.T) {
+	tests := []struct {
+		header  string
+		columns []string
+		err     string
+	}{
+		{"name,email", []string{"name", "email"}, ""},
+		{"Email , NAME,role", []string{"email", "name", "role"}, ""},
+		{"\ufeffid,name,email,role,version", []string{"id", "name", "email", "role", "version"}, ""},
+		{"", nil, "CSV body must start with a header row"},
+		{"name", nil, `CSV column "email" is required`},
+		{"email,role", nil, `CSV column "name" is required`},
+		{"name,email,password", nil, `Unknown CSV column "password"`},
+		{"name,email,Name", nil, `CSV column "name" appears twice`},
+		{`"name,email`, nil, "CSV body must start with a header row"},
+	}
+	for _, tt := range tests {
+		rows, err := newCSVRows(strings.NewReader(tt.header + "\n"))
+		switch {
+		case tt.err != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.err)):
+			t.Errorf("header %q: err = %v, want %q", tt.header, err, tt.err)
+		case tt.err == "" && err != nil:
+			t.Errorf("header %q: %v", tt.header, err)
+		case tt.err == "" && !slices.Equal(rows.columns, tt.columns):
+			t.Errorf("header %q: columns %v, want %v", tt.header, rows.columns, tt.columns)
+		}
+	}
+}
+
+func TestCSVRows(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []parsedRow
+	}{
+		{"header only", "name,email\n", nil},
+		{"columns in any order", "email,role,name\nann@example.com,editor,Ann\n", []parsedRow{
+			{line: 2, name: "Ann", email: "ann@example.com", role: "editor"},
+		}},
+		{"server columns are ignored", "id,name,email,version\n7,Ann,ann@example.com,3\n", []parsedRow{
+			{line: 2, name: "Ann", email: "ann@example.com"},
+		}},
+		{"spaces are trimmed and formulas unescaped", "name,email\n  '=Ann  , ann@example.com \n", []parsedRow{
+			{line: 2, name: "=Ann", email: "ann@example.com"},
+		}},
+		{"quoted fields over several lines", "name,email\n\"Ann\nSmith\",ann@example.com\nBen,ben@example.com\n", []parsedRow{
+			{line: 2, name: "Ann\nSmith", email: "ann@example.com"},
+			{line: 4, name: "Ben", email: "ben@example.com"},
+		}},
+		{"malformed rows do not stop the import", "name,email\nAnn\nBen,ben@example.com\nCy,cy@example.com,extra\n\"Di\"x,di@example.com\nEd,ed@example.com\n", []parsedRow{
+			{line: 2, errors: []string{"row: wrong number of fields"}},
+			{line: 3, name: "Ben", email: "ben@example.com"},
+			{line: 4, errors: []string{"row: wrong number of fields"}},
+			{line: 5, errors: []string{`row: extraneous or missing " in quoted-field`}},
+			{line: 6, name: "Ed", email: "ed@example.com"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := newCSVRows(strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := readRows(rows)
+			if !errors.Is(err, io.EOF) {
+				t.Fatalf("rows ended with %v, want io.EOF", err)
+			}
+			if !slices.EqualFunc(got, tt.want, equalRows) {
+				t.Fatalf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserPageWriter(t *testing.T) {
+	users := []models.User{
+		{ID: 1, Name: "Ann", Email: "ann@example.com", Role: "admin", Version: 2},
+		{ID: 2, Name: "=cmd()", Email: "-x@example.com", Role: "reader", Version: 1},
+	}
+
+	var csvOut strings.Builder
+	page := userPageWriter(&csvOut, render.CSV)
+	if err := page(users[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := page(users[1:]); err != nil {
+		t.Fatal(err)
+	}
+	want := "id,name,email,role,version\n1,Ann,ann@example.com,admin,2\n2,'=cmd(),'-x@example.com,reader,1\n"
+	if csvOut.String() != want {
+		t.Fatalf("CSV export\n%s\nwant a single header for all pages\n%s", csvOut.String(), want)
+	}
+
+	var ndjsonOut strings.Builder
+	if err := userPageWriter(&ndjsonOut, exportFormats.Formats()[0])(users); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readRows(ndjson(ndjsonOut.String()))
+	if !errors.Is(err, io.EOF) || len(got) != 2 || got[1].name != "=cmd()" {
+		t.Fatalf("NDJSON export read back as %+v, %v", got, err)
+	}
+}
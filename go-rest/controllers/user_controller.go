@@ -1,60 +1,302 @@
 package controllers
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/manish-npx/go-lang/go-rest/apierror"
+	"github.com/manish-npx/go-lang/go-rest/auth"
+	"github.com/manish-npx/go-lang/go-rest/events"
 	"github.com/manish-npx/go-lang/go-rest/models"
+	"github.com/manish-npx/go-lang/go-rest/policy"
+	"github.com/manish-npx/go-lang/go-rest/repository"
 )
 
 const CONTENT_TYPE = "Content-Type"
 const APPLICATION_JSON = "application/json"
 
-var users = []models.User{
-	{ID: 1, Name: "Alice", Email: "alice@example.com"},
-	{ID: 2, Name: "Bob", Email: "bob@example.com"},
+// UserController serves the /users routes.
+// It only talks to storage through the repository interface it is given.
+type UserController struct {
+	users repository.UserRepository
+	audit auditor
 }
 
-func GetUsers(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set(CONTENT_TYPE, APPLICATION_JSON)
-	// Encode (convert) the users slice into JSON and send
-	json.NewEncoder(w).Encode(users)
+// NewUserController returns a UserController backed by users that
+// records every change in audit and publishes it to broadcaster
+func NewUserController(users repository.UserRepository, audit repository.AuditRepository, broadcaster *events.Broadcaster) *UserController {
+	return &UserController{users: users, audit: auditor{records: audit, events: broadcaster}}
 }
 
-// GetUserByID handles GET /user?id=1
-func GetUserByID(w http.ResponseWriter, r *http.Request) {
-	// Get the "id" query parameter from URL
-	idStr := r.URL.Query().Get("id")
-	// Convert the string to an integer
-	id, _ := strconv.Atoi(idStr)
+// userListSpec lists what GET /users can sort and filter on
+var userListSpec = listSpec[models.User]{
+	defaultSort: "id",
+	fields: map[string]listField[models.User]{
+		"id": {
+			key:  func(u models.User) string { return intKey(u.ID) },
+			text: func(u models.User) string { return strconv.Itoa(u.ID) },
+		},
+		"name": {
+			key:  func(u models.User) string { return strings.ToLower(u.Name) },
+			text: func(u models.User) string { return u.Name },
+		},
+		"email": {
+			key:  func(u models.User) string { return strings.ToLower(u.Email) },
+			text: func(u models.User) string { return u.Email },
+		},
+	},
+}
+
+// GetUsers handles GET /users, e.g. /users?sort=name&limit=10&email[contains]=example
+func (c *UserController) GetUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := c.users.List(r.Context())
+	if err != nil {
+		storageError(w, r, err, "User not found")
+		return
+	}
+
+	// Hide emails before filtering and sorting, so ?email= cannot be
+	// used to find out the email of someone else
+	caller := auth.Caller(r.Context())
+	for i := range users {
+		users[i] = redactUser(caller, users[i])
+	}
+
+	page, ok := paginate(w, r, users, userListSpec)
+	if !ok {
+		return
+	}
+
+	// Encode the page of users in the requested format and send
+	writeList(w, r, page)
+}
+
+// GetUserByID handles GET /users/{id}
+func (c *UserController) GetUserByID(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
 
 	// Search for the user by ID
-	for _, user := range users {
-		if user.ID == id {
-			w.Header().Set(CONTENT_TYPE, APPLICATION_JSON)
-			json.NewEncoder(w).Encode(user)
-			return
-		}
+	user, err := c.users.Get(r.Context(), id)
+	if err != nil {
+		// If not found, return a 404 error
+		storageError(w, r, err, "User not found")
+		return
 	}
 
-	// If not found, return a 404 error
-	http.Error(w, "User not found", http.StatusNotFound)
+	user = redactUser(auth.Caller(r.Context()), user)
+	if notModified(w, r, userETag(r, user)) {
+		return
+	}
+
+	respond(w, r, http.StatusOK, user)
 }
 
-func CreateUser(w http.ResponseWriter, r *http.Request) {
+// CreateUser handles POST /users. Only admins may create users this way;
+// everyone else signs up with POST /auth/register.
+func (c *UserController) CreateUser(w http.ResponseWriter, r *http.Request) {
 	// Create a variable to hold the new user data from the request body
 	var newUser models.User
 
-	// Decode the JSON body into our newUser struct
-	json.NewDecoder(r.Body).Decode(&newUser)
+	// Decode the JSON body into our newUser struct and check its fields
+	if !decodeJSON(w, r, &newUser) {
+		return
+	}
+	if newUser.Role == "" {
+		newUser.Role = models.DefaultRole
+	}
+	// Users are only ever deleted through DELETE /users/{id}
+	newUser.DeletedAt = nil
+	if !authorize(w, r, policy.CanUser(auth.Caller(r.Context()), policy.Create, newUser)) {
+		return
+	}
+	if validationFailed(w, r, newUser.Validate()) {
+		return
+	}
+
+	// The repository assigns the new ID
+	if err := c.users.Create(r.Context(), &newUser); err != nil {
+		userStorageError(w, r, err)
+		return
+	}
+	c.audit.recordCaller(r, models.AuditCreate, models.ResourceUser, newUser.ID, nil, newUser)
+
+	// Return the newly created user, pointing Location at the new resource
+	w.Header().Set("Location", fmt.Sprintf("/users/%d", newUser.ID))
+	w.Header().Set("ETag", userETag(r, newUser))
+	respond(w, r, http.StatusCreated, newUser)
+}
+
+// UpdateUser handles PUT /users/{id} and replaces the whole user
+func (c *UserController) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	stored, ok := c.authorizedUser(w, r, policy.Update, id)
+	if !ok {
+		return
+	}
+
+	var updated models.User
+	if !decodeJSON(w, r, &updated) {
+		return
+	}
+	// A PUT that leaves out the role keeps the current one
+	if updated.Role == "" {
+		updated.Role = stored.Role
+	}
+
+	c.saveUser(w, r, stored, updated)
+}
+
+// PatchUser handles PATCH /users/{id} and only changes the fields sent
+func (c *UserController) PatchUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	stored, ok := c.authorizedUser(w, r, policy.Update, id)
+	if !ok {
+		return
+	}
+
+	// Decoding on top of the stored user keeps every field the body leaves
+	// out. Users have no slices or maps, and DeletedAt is nil on live ones,
+	// so nothing is shared with stored and decoded over in place.
+	patched := stored
+	if !decodeJSON(w, r, &patched) {
+		return
+	}
+
+	c.saveUser(w, r, stored, patched)
+}
+
+// DeleteUser handles DELETE /users/{id}. The user is only marked deleted
+// and can be brought back with POST /users/{id}/restore.
+func (c *UserController) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	stored, ok := c.authorizedUser(w, r, policy.Delete, id)
+	if !ok {
+		return
+	}
+
+	if err := c.users.Delete(r.Context(), id, stored.Version); err != nil {
+		storageError(w, r, err, "User not found")
+		return
+	}
+	deleted, err := c.users.GetDeleted(r.Context(), id)
+	if err != nil {
+		storageError(w, r, err, "User not found")
+		return
+	}
+	c.audit.recordCaller(r, models.AuditDelete, models.ResourceUser, id, stored, deleted)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RestoreUser handles POST /users/{id}/restore and undoes a delete. Admins only.
+func (c *UserController) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	deleted, err := c.users.GetDeleted(r.Context(), id)
+	if err != nil {
+		storageError(w, r, err, "No deleted user with this id")
+		return
+	}
+	if !authorize(w, r, policy.CanUser(auth.Caller(r.Context()), policy.Restore, deleted)) {
+		return
+	}
+
+	if err := c.users.Restore(r.Context(), id); err != nil {
+		storageError(w, r, err, "No deleted user with this id")
+		return
+	}
+	restored, err := c.users.Get(r.Context(), id)
+	if err != nil {
+		storageError(w, r, err, "User not found")
+		return
+	}
+	c.audit.recordCaller(r, models.AuditRestore, models.ResourceUser, id, deleted, restored)
+
+	w.Header().Set("ETag", userETag(r, restored))
+	respond(w, r, http.StatusOK, restored)
+}
+
+// saveUser validates and stores user in place of stored. The ID and
+// password always come from the stored copy, and only admins may change roles.
+func (c *UserController) saveUser(w http.ResponseWriter, r *http.Request, stored, user models.User) {
+	if user.Role != stored.Role && !authorize(w, r, policy.CanChangeRole(auth.Caller(r.Context()))) {
+		return
+	}
+	if validationFailed(w, r, user.Validate()) {
+		return
+	}
+
+	// The ID always comes from the path, never from the body,
+	// and a PUT must not wipe the password. The version is the one
+	// If-Match was checked against, so a concurrent update gives 412.
+	// Deleting goes through DELETE, with its own policy and audit entry.
+	user.ID = stored.ID
+	user.PasswordHash = stored.PasswordHash
+	user.Version = stored.Version
+	user.DeletedAt = stored.DeletedAt
+	if err := c.users.Update(r.Context(), &user); err != nil {
+		userStorageError(w, r, err)
+		return
+	}
+	c.audit.recordCaller(r, models.AuditUpdate, models.ResourceUser, user.ID, stored, user)
+
+	w.Header().Set("ETag", userETag(r, user))
+	respond(w, r, http.StatusOK, user)
+}
 
-	// Assign a new ID (auto-increment style)
-	newUser.ID = len(users) + 1
-	// Add to the in-memory slice
-	users = append(users, newUser)
+// authorizedUser loads the user with the given ID and checks that the
+// caller may perform action on it and that If-Match, if sent, names its
+// current ETag. Otherwise it writes a 404, 403 or 412.
+func (c *UserController) authorizedUser(w http.ResponseWriter, r *http.Request, action policy.Action, id int) (models.User, bool) {
+	user, err := c.users.Get(r.Context(), id)
+	if err != nil {
+		storageError(w, r, err, "User not found")
+		return models.User{}, false
+	}
+	caller := auth.Caller(r.Context())
+	if !authorize(w, r, policy.CanUser(caller, action, user)) {
+		return models.User{}, false
+	}
+	if preconditionFailed(w, r, userETag(r, redactUser(caller, user))) {
+		return models.User{}, false
+	}
+	return user, true
+}
 
-	// Return the newly created user as JSON
-	w.Header().Set(CONTENT_TYPE, APPLICATION_JSON)
-	json.NewEncoder(w).Encode(newUser)
+// redactUser hides the email of user unless caller may see it
+func redactUser(caller *models.User, user models.User) models.User {
+	if !policy.CanSeeEmail(caller, user) {
+		user.Email = ""
+	}
+	return user
+}
+
+// userStorageError is storageError plus the duplicate email case
+func userStorageError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, repository.ErrConflict) {
+		apierror.Write(w, r, apierror.Conflict("Email is already in use"))
+		return
+	}
+	storageError(w, r, err, "User not found")
 }
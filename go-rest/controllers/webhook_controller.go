@@ -0,0 +1,309 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/manish-npx/go-lang/go-rest/apierror"
+	"github.com/manish-npx/go-lang/go-rest/auth"
+	"github.com/manish-npx/go-lang/go-rest/events"
+	"github.com/manish-npx/go-lang/go-rest/models"
+	"github.com/manish-npx/go-lang/go-rest/policy"
+	"github.com/manish-npx/go-lang/go-rest/repository"
+	"github.com/manish-npx/go-lang/go-rest/webhooks"
+)
+
+// WebhookController serves the /webhooks routes. Admins only.
+type WebhookController struct {
+	hooks      repository.WebhookRepository
+	deliveries repository.WebhookDeliveryRepository
+	dispatcher *webhooks.Dispatcher
+	audit      auditor
+}
+
+// NewWebhookController returns a WebhookController managing hooks and
+// reading the deliveries dispatcher logs. Changes are recorded in audit.
+func NewWebhookController(hooks repository.WebhookRepository, deliveries repository.WebhookDeliveryRepository, dispatcher *webhooks.Dispatcher, audit repository.AuditRepository) *WebhookController {
+	return &WebhookController{
+		hooks:      hooks,
+		deliveries: deliveries,
+		dispatcher: dispatcher,
+		audit:      auditor{records: audit},
+	}
+}
+
+// WebhookRequest is the body of POST /webhooks and PUT /webhooks/{id}
+type WebhookRequest struct {
+	URL string `json:"url"`
+	// Events to send: names like "users.created", "blogs.*" or "*"
+	Events []string `json:"events"`
+	// Secret signs the payloads. A random one is made when it is left
+	// out of a create; left out of an update, it is kept.
+	Secret string `json:"secret,omitempty"`
+	// Active defaults to true
+	Active *bool `json:"active,omitempty"`
+}
+
+// webhookListSpec lists what GET /webhooks can sort and filter on
+var webhookListSpec = listSpec[models.Webhook]{
+	defaultSort: "id",
+	fields: map[string]listField[models.Webhook]{
+		"id": {
+			key:  func(h models.Webhook) string { return intKey(h.ID) },
+			text: func(h models.Webhook) string { return strconv.Itoa(h.ID) },
+		},
+		"url": {
+			key:  func(h models.Webhook) string { return strings.ToLower(h.URL) },
+			text: func(h models.Webhook) string { return h.URL },
+		},
+		"active": {
+			text: func(h models.Webhook) string { return strconv.FormatBool(h.Active) },
+		},
+	},
+}
+
+// deliveryListSpec lists what the delivery log and the dead-letter list
+// can sort and filter on
+var deliveryListSpec = listSpec[models.WebhookDelivery]{
+	defaultSort: "-id",
+	fields: map[string]listField[models.WebhookDelivery]{
+		"id": {
+			key:  func(d models.WebhookDelivery) string { return intKey(d.ID) },
+			text: func(d models.WebhookDelivery) string { return strconv.Itoa(d.ID) },
+		},
+		"webhook_id": {
+			key:  func(d models.WebhookDelivery) string { return intKey(d.WebhookID) },
+			text: func(d models.WebhookDelivery) string { return strconv.Itoa(d.WebhookID) },
+		},
+		"event": {
+			text: func(d models.WebhookDelivery) string { return d.Event },
+		},
+		"status": {
+			text: func(d models.WebhookDelivery) string { return d.Status },
+		},
+		"created_at": {
+			key: func(d models.WebhookDelivery) string { return timeKey(d.CreatedAt) },
+		},
+	},
+}
+
+// GetWebhooks handles GET /webhooks. Secrets are not shown.
+func (c *WebhookController) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, policy.CanManageWebhooks(auth.Caller(r.Context()))) {
+		return
+	}
+
+	hooks, err := c.hooks.List(r.Context())
+	if err != nil {
+		storageError(w, r, err, "Webhook not found")
+		return
+	}
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+
+	page, ok := paginate(w, r, hooks, webhookListSpec)
+	if !ok {
+		return
+	}
+	writeList(w, r, page)
+}
+
+// CreateWebhook handles POST /webhooks. The answer is the only one
+// showing the secret.
+func (c *WebhookController) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, policy.CanManageWebhooks(auth.Caller(r.Context()))) {
+		return
+	}
+
+	var req WebhookRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	hook := models.Webhook{
+		URL:       req.URL,
+		Events:    req.Events,
+		Secret:    req.Secret,
+		Active:    req.Active == nil || *req.Active,
+		CreatedAt: time.Now().UTC(),
+	}
+	if validationFailed(w, r, validateWebhook(hook)) {
+		return
+	}
+	if hook.Secret == "" {
+		hook.Secret = webhooks.NewSecret()
+	}
+
+	if err := c.hooks.Create(r.Context(), &hook); err != nil {
+		storageError(w, r, err, "Webhook not found")
+		return
+	}
+	c.audit.recordCaller(r, models.AuditCreate, models.ResourceWebhook, hook.ID, nil, withoutSecret(hook))
+
+	respond(w, r, http.StatusCreated, hook)
+}
+
+// GetWebhookByID handles GET /webhooks/{id}. The secret is not shown.
+func (c *WebhookController) GetWebhookByID(w http.ResponseWriter, r *http.Request) {
+	hook, ok := c.authorizedWebhook(w, r)
+	if !ok {
+		return
+	}
+	respond(w, r, http.StatusOK, withoutSecret(hook))
+}
+
+// UpdateWebhook handles PUT /webhooks/{id}. The secret is shown only when
+// the request changes it.
+func (c *WebhookController) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	stored, ok := c.authorizedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	var req WebhookRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	hook := stored
+	hook.URL = req.URL
+	hook.Events = req.Events
+	hook.Active = req.Active == nil || *req.Active
+	if req.Secret != "" {
+		hook.Secret = req.Secret
+	}
+	if validationFailed(w, r, validateWebhook(hook)) {
+		return
+	}
+
+	if err := c.hooks.Update(r.Context(), &hook); err != nil {
+		storageError(w, r, err, "Webhook not found")
+		return
+	}
+	c.audit.recordCaller(r, models.AuditUpdate, models.ResourceWebhook, hook.ID, withoutSecret(stored), withoutSecret(hook))
+
+	if req.Secret == "" {
+		hook = withoutSecret(hook)
+	}
+	respond(w, r, http.StatusOK, hook)
+}
+
+// DeleteWebhook handles DELETE /webhooks/{id}. Its deliveries stay in the
+// log; the pending ones go dead instead of being sent.
+func (c *WebhookController) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	stored, ok := c.authorizedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.hooks.Delete(r.Context(), stored.ID); err != nil {
+		storageError(w, r, err, "Webhook not found")
+		return
+	}
+	c.audit.recordCaller(r, models.AuditDelete, models.ResourceWebhook, stored.ID, withoutSecret(stored), nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetDeliveries handles GET /webhooks/{id}/deliveries, the delivery log
+// of a webhook, newest first, e.g. ?status=pending
+func (c *WebhookController) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	hook, ok := c.authorizedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	deliveries, err := c.deliveries.ListByWebhook(r.Context(), hook.ID)
+	if err != nil {
+		storageError(w, r, err, "Delivery not found")
+		return
+	}
+	page, ok := paginate(w, r, deliveries, deliveryListSpec)
+	if !ok {
+		return
+	}
+	writeList(w, r, page)
+}
+
+// GetDeadLetters handles GET /webhooks/dead-letters: the deliveries of
+// every webhook that ran out of attempts, newest first
+func (c *WebhookController) GetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, policy.CanManageWebhooks(auth.Caller(r.Context()))) {
+		return
+	}
+
+	deliveries, err := c.deliveries.ListByStatus(r.Context(), models.DeliveryDead)
+	if err != nil {
+		storageError(w, r, err, "Delivery not found")
+		return
+	}
+	page, ok := paginate(w, r, deliveries, deliveryListSpec)
+	if !ok {
+		return
+	}
+	writeList(w, r, page)
+}
+
+// RetryDelivery handles POST /webhooks/deliveries/{id}/retry. It moves a
+// dead delivery back to pending, with all its attempts ahead of it; the
+// answer does not wait for it to be sent.
+func (c *WebhookController) RetryDelivery(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, policy.CanManageWebhooks(auth.Caller(r.Context()))) {
+		return
+	}
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	delivery, err := c.dispatcher.Redeliver(r.Context(), id)
+	if errors.Is(err, webhooks.ErrNotDead) {
+		apierror.Write(w, r, apierror.Conflict("Only dead deliveries can be retried"))
+		return
+	}
+	if err != nil {
+		storageError(w, r, err, "Delivery not found")
+		return
+	}
+
+	respond(w, r, http.StatusAccepted, delivery)
+}
+
+// authorizedWebhook loads the webhook of the {id} wildcard for an admin.
+// On failure it writes a 400, 403 or 404 response and returns ok=false.
+func (c *WebhookController) authorizedWebhook(w http.ResponseWriter, r *http.Request) (models.Webhook, bool) {
+	if !authorize(w, r, policy.CanManageWebhooks(auth.Caller(r.Context()))) {
+		return models.Webhook{}, false
+	}
+	id, ok := pathID(w, r)
+	if !ok {
+		return models.Webhook{}, false
+	}
+	hook, err := c.hooks.Get(r.Context(), id)
+	if err != nil {
+		storageError(w, r, err, "Webhook not found")
+		return models.Webhook{}, false
+	}
+	return hook, true
+}
+
+// validateWebhook checks the fields of hook, event names included
+func validateWebhook(hook models.Webhook) []models.FieldError {
+	errs := hook.Validate()
+	for _, event := range hook.Events {
+		if !webhooks.ValidPattern(event) {
+			errs = append(errs, models.FieldError{Field: "events", Message: fmt.Sprintf(
+				"unknown event %q, use topic.type (topics: %s), topic.* or *", event, strings.Join(events.Topics, ", "))})
+		}
+	}
+	return errs
+}
+
+// withoutSecret hides the secret of hook, which is only shown when set
+func withoutSecret(hook models.Webhook) models.Webhook {
+	hook.Secret = ""
+	return hook
+}
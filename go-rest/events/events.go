@@ -0,0 +1,220 @@
+// Package events fans changes to users, blogs and comments out to live
+// subscribers, such as the Server-Sent Events stream at GET /events.
+//
+// Every event gets an ID one higher than the previous one. The last events
+// are kept in a bounded log, so a subscriber that lost its connection can
+// ask for everything after the last ID it saw.
+package events
+
+import (
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Topics, one per kind of resource
+const (
+	TopicUsers    = "users"
+	TopicBlogs    = "blogs"
+	TopicComments = "comments"
+)
+
+// Topics lists every topic
+var Topics = []string{TopicUsers, TopicBlogs, TopicComments}
+
+// Event types
+const (
+	Created  = "created"
+	Updated  = "updated"
+	Deleted  = "deleted"
+	Restored = "restored"
+)
+
+// Types lists every event type
+var Types = []string{Created, Updated, Deleted, Restored}
+
+// DEFAULT_LOG_SIZE is how many events are kept for resuming subscribers
+const DEFAULT_LOG_SIZE = 1000
+
+// SUBSCRIBER_BUFFER is how many events may wait for a subscriber before
+// it is considered too slow and dropped
+const SUBSCRIBER_BUFFER = 64
+
+// Event is one change
+type Event struct {
+	ID         int64     `json:"id"`
+	Topic      string    `json:"topic"`
+	Type       string    `json:"type"`
+	ResourceID int       `json:"resource_id"`
+	Time       time.Time `json:"time"`
+	// Data is the resource after the change (for a delete, as it was deleted)
+	Data any `json:"data"`
+}
+
+// Name is the event name, e.g. "blogs.created"
+func (e Event) Name() string {
+	return e.Topic + "." + e.Type
+}
+
+// Broadcaster publishes events to every subscriber of their topic.
+// It is safe for concurrent use.
+type Broadcaster struct {
+	mu     sync.Mutex
+	lastID int64
+	// log holds the last events, oldest first
+	log     []Event
+	logSize int
+	subs    map[*Subscription]struct{}
+	closed  bool
+}
+
+// NewBroadcaster returns a broadcaster keeping the last logSize events
+func NewBroadcaster(logSize int) *Broadcaster {
+	return &Broadcaster{logSize: max(logSize, 1), subs: map[*Subscription]struct{}{}}
+}
+
+// Publish sends an event to the subscribers of topic and returns it.
+// Subscribers whose buffer is full are dropped rather than waited for,
+// so a slow client never holds up the request that made the change; it
+// can reconnect and catch up from the log.
+func (b *Broadcaster) Publish(topic, typ string, resourceID int, data any) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	e := Event{ID: b.lastID, Topic: topic, Type: typ, ResourceID: resourceID, Time: time.Now().UTC(), Data: data}
+	if len(b.log) == b.logSize {
+		b.log = b.log[1:]
+	}
+	b.log = append(b.log, e)
+
+	for sub := range b.subs {
+		if !sub.topics[topic] {
+			continue
+		}
+		select {
+		case sub.c <- e:
+		default:
+			b.drop(sub)
+		}
+	}
+	return e
+}
+
+// Subscribe returns a subscription to topics (every topic when empty).
+//
+// With lastID >= 0 the events after lastID still in the log come first,
+// in backlog; complete is false when some of them were already dropped
+// from the log (or lastID is unknown, e.g. after a restart), meaning the
+// subscriber missed events and should reload what it shows. With
+// lastID < 0 only new events are sent.
+func (b *Broadcaster) Subscribe(topics []string, lastID int64) (sub *Subscription, backlog []Event, complete bool) {
+	if len(topics) == 0 {
+		topics = Topics
+	}
+	sub = &Subscription{c: make(chan Event, SUBSCRIBER_BUFFER), topics: map[string]bool{}, b: b}
+	for _, t := range topics {
+		sub.topics[t] = true
+	}
+	sub.C = sub.c
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(sub.c)
+		return sub, nil, true
+	}
+	// Registered under the same lock the backlog is read with,
+	// so no event is missed or sent twice
+	b.subs[sub] = struct{}{}
+
+	if lastID < 0 {
+		return sub, nil, true
+	}
+	backlog, complete = b.since(lastID, sub.topics)
+	return sub, backlog, complete
+}
+
+// Since returns the events after lastID still in the log, of every topic,
+// with complete as in Subscribe. Events keep being logged after Close, so
+// this is how they are read once the subscriptions have ended.
+func (b *Broadcaster) Since(lastID int64) (backlog []Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.since(lastID, nil)
+}
+
+// since returns the events of topics (every topic when nil) after lastID
+// in the log. The caller must hold b.mu.
+func (b *Broadcaster) since(lastID int64, topics map[string]bool) (backlog []Event, complete bool) {
+	complete = lastID <= b.lastID
+	if len(b.log) > 0 && lastID < b.log[0].ID-1 {
+		complete = false
+	}
+	for _, e := range b.log {
+		if e.ID > lastID && (topics == nil || topics[e.Topic]) {
+			backlog = append(backlog, e)
+		}
+	}
+	return backlog, complete
+}
+
+// Close ends every subscription, e.g. on shutdown. Later subscriptions
+// end right away.
+func (b *Broadcaster) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for sub := range b.subs {
+		b.drop(sub)
+	}
+}
+
+// Closed reports whether Close was called
+func (b *Broadcaster) Closed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed
+}
+
+// drop ends a subscription. The caller must hold b.mu.
+func (b *Broadcaster) drop(sub *Subscription) {
+	if _, ok := b.subs[sub]; !ok {
+		return
+	}
+	delete(b.subs, sub)
+	close(sub.c)
+}
+
+// Subscription receives events until it is closed or dropped
+type Subscription struct {
+	// C delivers the events. It is closed when the subscription ends:
+	// by Close, because the subscriber was too slow, or on shutdown.
+	C <-chan Event
+
+	c      chan Event
+	topics map[string]bool
+	b      *Broadcaster
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+	s.b.drop(s)
+}
+
+// ParseID reads a Last-Event-ID header: the ID of the last event seen,
+// or -1 when the header is empty
+func ParseID(header string) (int64, error) {
+	if header == "" {
+		return -1, nil
+	}
+	return strconv.ParseInt(header, 10, 64)
+}
+
+// ValidTopic reports whether topic is one of Topics
+func ValidTopic(topic string) bool {
+	return slices.Contains(Topics, topic)
+}
@@ -0,0 +1,202 @@
+package events
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// ids returns the IDs of events
+func ids(events []Event) []int64 {
+	var ids []int64
+	for _, e := range events {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
+// receive waits for the next event of sub; ok is false when it ended
+func receive(t *testing.T, sub *Subscription) (e Event, ok bool) {
+	t.Helper()
+	select {
+	case e, ok = <-sub.C:
+		return e, ok
+	case <-time.After(time.Second):
+		t.Fatal("no event within a second")
+	}
+	return Event{}, false
+}
+
+func TestSubscribeResumesAfterLastID(t *testing.T) {
+	tests := []struct {
+		name     string
+		topics   []string
+		lastID   int64
+		backlog  []int64
+		complete bool
+	}{
+		{"new events only", nil, -1, nil, true},
+		{"from the start", nil, 0, []int64{1, 2, 3, 4}, true},
+		{"after an ID", nil, 2, []int64{3, 4}, true},
+		{"up to date", nil, 4, nil, true},
+		{"of some topics", []string{TopicBlogs}, 0, []int64{2, 4}, true},
+		{"unknown ID, e.g. after a restart", nil, 9, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBroadcaster(DEFAULT_LOG_SIZE)
+			b.Publish(TopicUsers, Created, 1, nil)
+			b.Publish(TopicBlogs, Created, 1, nil)
+			b.Publish(TopicUsers, Updated, 1, nil)
+			b.Publish(TopicBlogs, Deleted, 1, nil)
+
+			sub, backlog, complete := b.Subscribe(tt.topics, tt.lastID)
+			defer sub.Close()
+			if !slices.Equal(ids(backlog), tt.backlog) || complete != tt.complete {
+				t.Fatalf("backlog %v, complete %v; want %v, %v", ids(backlog), complete, tt.backlog, tt.complete)
+			}
+
+			// New events follow the backlog, without a gap or a repeat
+			b.Publish(TopicBlogs, Updated, 1, nil)
+			if e, ok := receive(t, sub); !ok || e.ID != 5 || e.Name() != "blogs.updated" {
+				t.Fatalf("next event %+v, %v", e, ok)
+			}
+		})
+	}
+}
+
+func TestSubscribeFiltersTopics(t *testing.T) {
+	b := NewBroadcaster(DEFAULT_LOG_SIZE)
+	comments, _, _ := b.Subscribe([]string{TopicComments}, -1)
+	all, _, _ := b.Subscribe(nil, -1)
+	defer comments.Close()
+	defer all.Close()
+
+	b.Publish(TopicUsers, Created, 1, nil)
+	b.Publish(TopicBlogs, Created, 2, nil)
+	b.Publish(TopicComments, Created, 3, nil)
+
+	if e, _ := receive(t, comments); e.Topic != TopicComments {
+		t.Fatalf("comments subscriber got %+v", e)
+	}
+	for _, topic := range []string{TopicUsers, TopicBlogs, TopicComments} {
+		if e, _ := receive(t, all); e.Topic != topic {
+			t.Fatalf("subscriber of every topic got %+v, want %s", e, topic)
+		}
+	}
+	select {
+	case e := <-comments.C:
+		t.Fatalf("comments subscriber also got %+v", e)
+	default:
+	}
+}
+
+func TestResumeAfterTheLogRolledOver(t *testing.T) {
+	b := NewBroadcaster(3)
+	for i := range 5 {
+		b.Publish(TopicBlogs, Created, i, nil)
+	}
+
+	tests := []struct {
+		lastID   int64
+		backlog  []int64
+		complete bool
+	}{
+		// Events 1 and 2 are gone: the subscriber must reload
+		{0, []int64{3, 4, 5}, false},
+		{1, []int64{3, 4, 5}, false},
+		// Event 2 is gone, but it was already seen
+		{2, []int64{3, 4, 5}, true},
+		{4, []int64{5}, true},
+	}
+	for _, tt := range tests {
+		sub, backlog, complete := b.Subscribe(nil, tt.lastID)
+		sub.Close()
+		if !slices.Equal(ids(backlog), tt.backlog) || complete != tt.complete {
+			t.Errorf("after %d: backlog %v, complete %v; want %v, %v", tt.lastID, ids(backlog), complete, tt.backlog, tt.complete)
+		}
+		if since, complete := b.Since(tt.lastID); !slices.Equal(ids(since), tt.backlog) || complete != tt.complete {
+			t.Errorf("Since(%d) = %v, %v", tt.lastID, ids(since), complete)
+		}
+	}
+}
+
+func TestSlowSubscribersAreDropped(t *testing.T) {
+	b := NewBroadcaster(DEFAULT_LOG_SIZE)
+	slow, _, _ := b.Subscribe(nil, -1)
+	fast, _, _ := b.Subscribe(nil, -1)
+	defer fast.Close()
+
+	// fast keeps up; slow reads nothing until its buffer overflows
+	for i := range SUBSCRIBER_BUFFER + 1 {
+		e := b.Publish(TopicBlogs, Created, i, nil)
+		if got, ok := receive(t, fast); !ok || got.ID != e.ID {
+			t.Fatalf("fast subscriber got %+v, %v; want event %d", got, ok, e.ID)
+		}
+	}
+
+	// The buffered events are still delivered, then the channel is closed
+	var last int64
+	for e := range slow.C {
+		last = e.ID
+	}
+	if last != SUBSCRIBER_BUFFER {
+		t.Fatalf("slow subscriber read up to event %d, want %d", last, SUBSCRIBER_BUFFER)
+	}
+	// It catches up from the log when it comes back
+	_, backlog, complete := b.Subscribe(nil, last)
+	if !slices.Equal(ids(backlog), []int64{SUBSCRIBER_BUFFER + 1}) || !complete {
+		t.Fatalf("resumed with %v, complete %v", ids(backlog), complete)
+	}
+	slow.Close() // closing again is harmless
+}
+
+func TestCloseEndsEverySubscription(t *testing.T) {
+	b := NewBroadcaster(DEFAULT_LOG_SIZE)
+	subs := []*Subscription{}
+	for _, topics := range [][]string{nil, {TopicUsers}} {
+		sub, _, _ := b.Subscribe(topics, -1)
+		subs = append(subs, sub)
+	}
+
+	b.Close()
+	if !b.Closed() {
+		t.Fatal("Closed() = false after Close")
+	}
+	for i, sub := range subs {
+		if _, ok := receive(t, sub); ok {
+			t.Fatalf("subscription %d still open after Close", i)
+		}
+		sub.Close()
+	}
+
+	// Later subscriptions end right away, but events are still logged
+	late, backlog, _ := b.Subscribe(nil, 0)
+	if _, ok := receive(t, late); ok || backlog != nil {
+		t.Fatalf("subscription after Close: open %v, backlog %v", ok, backlog)
+	}
+	e := b.Publish(TopicUsers, Deleted, 1, nil)
+	if since, complete := b.Since(0); !slices.Equal(ids(since), []int64{e.ID}) || !complete {
+		t.Fatalf("Since after Close = %v, %v", ids(since), complete)
+	}
+}
+
+func TestParseID(t *testing.T) {
+	tests := []struct {
+		header string
+		id     int64
+		ok     bool
+	}{
+		{"", -1, true},
+		{"0", 0, true},
+		{"42", 42, true},
+		{"abc", 0, false},
+		{"4.2", 0, false},
+	}
+	for _, tt := range tests {
+		id, err := ParseID(tt.header)
+		if (err == nil) != tt.ok || (tt.ok && id != tt.id) {
+			t.Errorf("ParseID(%q) = %d, %v", tt.header, id, err)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+// Package live pushes messages to WebSocket clients grouped in rooms,
+// e.g. the readers of one blog.
+//
+// Each room is run by its own hub goroutine, started with the first
+// client and stopped with the last one. Clients are kept alive with
+// ping/pong, and a client that cannot keep up with its room is dropped
+// rather than allowed to slow the others down.
+package live
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Connection tuning
+const (
+	// WRITE_WAIT bounds the time to write one message
+	WRITE_WAIT = 10 * time.Second
+	// PONG_WAIT is how long a client may stay silent before it is
+	// considered gone; we ping it well before that
+	PONG_WAIT   = 60 * time.Second
+	PING_PERIOD = PONG_WAIT * 9 / 10
+	// MAX_MESSAGE_SIZE caps what clients may send: they only listen,
+	// so this only needs to fit control frames
+	MAX_MESSAGE_SIZE = 512
+	// SEND_BUFFER is how many messages may wait for a client before it
+	// is dropped as too slow
+	SEND_BUFFER = 16
+)
+
+// Hubs runs the hub of every room with clients. It is safe for concurrent use.
+type Hubs struct {
+	mu     sync.Mutex
+	hubs   map[int]*hub
+	closed bool
+	// serving counts the running Serve calls, so Close can wait for them
+	serving sync.WaitGroup
+}
+
+// NewHubs returns Hubs without any room
+func NewHubs() *Hubs {
+	return &Hubs{hubs: map[int]*hub{}}
+}
+
+// hub owns the clients of one room. Only its run goroutine touches clients.
+type hub struct {
+	join      chan *client
+	leave     chan *client
+	broadcast chan []byte
+	// quit is closed to stop run, when the last client left or on Close
+	quit chan struct{}
+	// refs counts the clients between Serve and their leave, guarded by Hubs.mu
+	refs    int
+	clients map[*client]struct{}
+}
+
+type client struct {
+	conn *websocket.Conn
+	send chan []byte
+	// closeCode is sent in the close frame once send is closed.
+	// It is set by the hub before closing send.
+	closeCode int
+}
+
+// Serve adds conn to room and sends it every message broadcast there,
+// until the client goes away, is dropped or Close is called. It blocks
+// for as long as the client stays, and closes conn.
+func (h *Hubs) Serve(room int, conn *websocket.Conn) {
+	hb := h.acquire(room)
+	if hb == nil {
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), time.Now().Add(WRITE_WAIT))
+		conn.Close()
+		return
+	}
+	defer h.serving.Done()
+	defer h.release(room, hb)
+
+	c := &client{conn: conn, send: make(chan []byte, SEND_BUFFER)}
+	select {
+	case hb.join <- c:
+	case <-hb.quit:
+		conn.Close()
+		return
+	}
+
+	go c.writePump()
+	c.readPump()
+
+	select {
+	case hb.leave <- c:
+	case <-hb.quit:
+	}
+}
+
+// Broadcast sends message to every client in room. It does not wait for
+// them to receive it.
+func (h *Hubs) Broadcast(room int, message []byte) {
+	h.mu.Lock()
+	hb := h.hubs[room]
+	h.mu.Unlock()
+	if hb == nil {
+		return
+	}
+	select {
+	case hb.broadcast <- message:
+	case <-hb.quit:
+	}
+}
+
+// Close disconnects every client, e.g. on shutdown, and returns once
+// they were all sent a close frame (or WRITE_WAIT passed trying). Later
+// clients are turned away.
+func (h *Hubs) Close() {
+	h.mu.Lock()
+	h.closed = true
+	for room, hb := range h.hubs {
+		close(hb.quit)
+		delete(h.hubs, room)
+	}
+	h.mu.Unlock()
+
+	h.serving.Wait()
+}
+
+// acquire returns the hub of room, starting it if needed, or nil after Close
+func (h *Hubs) acquire(room int) *hub {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil
+	}
+	hb := h.hubs[room]
+	if hb == nil {
+		hb = &hub{
+			join:      make(chan *client),
+			leave:     make(chan *client),
+			broadcast: make(chan []byte),
+			quit:      make(chan struct{}),
+			clients:   map[*client]struct{}{},
+		}
+		h.hubs[room] = hb
+		go hb.run()
+	}
+	hb.refs++
+	h.serving.Add(1)
+	return hb
+}
+
+// release stops the hub of room when its last client is gone
+func (h *Hubs) release(room int, hb *hub) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hb.refs--
+	if hb.refs == 0 && h.hubs[room] == hb {
+		close(hb.quit)
+		delete(h.hubs, room)
+	}
+}
+
+func (hb *hub) run() {
+	for {
+		select {
+		case c := <-hb.join:
+			hb.clients[c] = struct{}{}
+		case c := <-hb.leave:
+			hb.drop(c, websocket.CloseNormalClosure)
+		case message := <-hb.broadcast:
+			for c := range hb.clients {
+				select {
+				case c.send <- message:
+				default:
+					// Too slow: it can reconnect and reload the comments
+					hb.drop(c, websocket.CloseTryAgainLater)
+				}
+			}
+		case <-hb.quit:
+			for c := range hb.clients {
+				hb.drop(c, websocket.CloseGoingAway)
+			}
+			return
+		}
+	}
+}
+
+// drop removes c and makes its write pump close the connection with code
+func (hb *hub) drop(c *client, code int) {
+	if _, ok := hb.clients[c]; !ok {
+		return
+	}
+	delete(hb.clients, c)
+	c.closeCode = code
+	close(c.send)
+}
+
+// writePump sends the messages of c and the pings. It is the only writer
+// of the connection, as gorilla/websocket requires.
+func (c *client) writePump() {
+	ping := time.NewTicker(PING_PERIOD)
+	defer func() {
+		ping.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case message, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(WRITE_WAIT))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(c.closeCode, ""))
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		case <-ping.C:
+			c.conn.SetWriteDeadline(time.Now().Add(WRITE_WAIT))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readPump reads until the connection fails, so that pongs and close
+// frames are processed. Anything else the client sends is ignored.
+func (c *client) readPump() {
+	c.conn.SetReadLimit(MAX_MESSAGE_SIZE)
+	c.conn.SetReadDeadline(time.Now().Add(PONG_WAIT))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(PONG_WAIT))
+	})
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
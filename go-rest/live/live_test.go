@@ -0,0 +1,274 @@
+package live
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// testClient reads its connection in the background. Messages wait in
+// an unbuffered channel, so a client the test does not read from stops
+// reading its socket after the first message, like a stalled browser.
+type testClient struct {
+	conn     *websocket.Conn
+	messages chan []byte
+	// err is the error that ended the reads
+	err  chan error
+	done chan struct{}
+}
+
+// newTestServer serves hubs at /?room=N
+func newTestServer(t *testing.T, hubs *Hubs) *httptest.Server {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		room, _ := strconv.Atoi(r.URL.Query().Get("room"))
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		hubs.Serve(room, conn)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// join connects to room and returns once the hub has the client: the
+// server answers a ping only from its read loop, which starts after the
+// client joined
+func join(t *testing.T, srv *httptest.Server, room int) *testClient {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/?room=" + strconv.Itoa(room)
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &testClient{conn: conn, messages: make(chan []byte), err: make(chan error, 1), done: make(chan struct{})}
+	t.Cleanup(func() {
+		close(c.done)
+		conn.Close()
+	})
+
+	pong := make(chan struct{}, 1)
+	conn.SetPongHandler(func(string) error {
+		select {
+		case pong <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+	go func() {
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				c.err <- err
+				close(c.messages)
+				return
+			}
+			select {
+			case c.messages <- message:
+			case <-c.done:
+				return
+			}
+		}
+	}()
+
+	if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-pong:
+	case err := <-c.err:
+		t.Fatalf("connection ended: %v", err)
+	case <-time.After(time.Second):
+		t.Fatal("no pong within a second")
+	}
+	return c
+}
+
+// next returns the next message of c
+func (c *testClient) next(t *testing.T) string {
+	t.Helper()
+	select {
+	case message, ok := <-c.messages:
+		if !ok {
+			t.Fatalf("connection ended: %v", <-c.err)
+		}
+		return string(message)
+	case <-time.After(time.Second):
+		t.Fatal("no message within a second")
+	}
+	return ""
+}
+
+// closeCode reads c to the end and returns how many messages were left
+// and the code of the close frame
+func (c *testClient) closeCode(t *testing.T) (messages, code int) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-c.messages:
+			if ok {
+				messages++
+				continue
+			}
+			var closeErr *websocket.CloseError
+			if err := <-c.err; !errors.As(err, &closeErr) {
+				t.Fatalf("connection ended with %v, want a close frame", err)
+			}
+			return messages, closeErr.Code
+		case <-timeout:
+			t.Fatalf("connection still open after %d messages", messages)
+		}
+	}
+}
+
+// rooms returns how many hubs are running
+func (h *Hubs) rooms() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.hubs)
+}
+
+func TestBroadcastReachesTheRoomOnly(t *testing.T) {
+	hubs := NewHubs()
+	defer hubs.Close()
+	srv := newTestServer(t, hubs)
+	a1, a2, b := join(t, srv, 1), join(t, srv, 1), join(t, srv, 2)
+
+	hubs.Broadcast(1, []byte("one"))
+	hubs.Broadcast(2, []byte("two"))
+	hubs.Broadcast(1, []byte("again"))
+	hubs.Broadcast(3, []byte("nobody")) // a room without clients
+
+	for i, c := range []*testClient{a1, a2} {
+		if got := c.next(t) + "," + c.next(t); got != "one,again" {
+			t.Errorf("client %d of room 1 got %s", i+1, got)
+		}
+	}
+	if got := b.next(t); got != "two" {
+		t.Errorf("client of room 2 got %s", got)
+	}
+}
+
+func TestClientsJoinAndLeave(t *testing.T) {
+	hubs := NewHubs()
+	defer hubs.Close()
+	srv := newTestServer(t, hubs)
+
+	first, second := join(t, srv, 1), join(t, srv, 1)
+	if n := hubs.rooms(); n != 1 {
+		t.Fatalf("%d hubs for one room", n)
+	}
+
+	// The others stay when one leaves
+	first.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	if _, code := first.closeCode(t); code != websocket.CloseNormalClosure {
+		t.Fatalf("leaving client closed with %d", code)
+	}
+	hubs.Broadcast(1, []byte("still here"))
+	if got := second.next(t); got != "still here" {
+		t.Fatalf("remaining client got %s", got)
+	}
+
+	// The hub stops with the last client, and starts again with the next
+	second.conn.Close()
+	deadline := time.Now().Add(time.Second)
+	for hubs.rooms() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the hub of an empty room is still running")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	third := join(t, srv, 1)
+	hubs.Broadcast(1, []byte("back"))
+	if got := third.next(t); got != "back" {
+		t.Fatalf("client of the restarted room got %s", got)
+	}
+}
+
+// stall fills the send buffer of a client that stopped reading: it keeps
+// broadcasting large messages, read by reader, until the socket and then
+// the buffer of slow are full
+func stall(t *testing.T, hubs *Hubs, reader *testClient) (sent int) {
+	t.Helper()
+	message := []byte(strings.Repeat("x", 512<<10))
+	for range 64 {
+		hubs.Broadcast(1, message)
+		reader.next(t)
+		sent++
+	}
+	return sent
+}
+
+func TestSlowClientIsDropped(t *testing.T) {
+	hubs := NewHubs()
+	defer hubs.Close()
+	srv := newTestServer(t, hubs)
+	slow, reader := join(t, srv, 1), join(t, srv, 1)
+
+	sent := stall(t, hubs, reader)
+
+	// slow gets what was already on its way, then a close frame telling
+	// it to come back later
+	messages, code := slow.closeCode(t)
+	if code != websocket.CloseTryAgainLater || messages >= sent {
+		t.Fatalf("slow client read %d of %d messages then closed with %d", messages, sent, code)
+	}
+	// while the reader was never held up
+	hubs.Broadcast(1, []byte("last"))
+	if got := reader.next(t); got != "last" {
+		t.Fatalf("reader got %.20s", got)
+	}
+}
+
+func TestCloseWaitsForServe(t *testing.T) {
+	hubs := NewHubs()
+	srv := newTestServer(t, hubs)
+	slow, reader, other := join(t, srv, 1), join(t, srv, 1), join(t, srv, 2)
+	stall(t, hubs, reader)
+
+	// The connection of slow is still writing what it was sent, so its
+	// Serve cannot return yet, and neither can Close
+	closed := make(chan struct{})
+	go func() {
+		hubs.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close returned while a connection was still being written")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	slow.closeCode(t)
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close still waiting a second after the last client was done")
+	}
+	for i, c := range []*testClient{reader, other} {
+		if _, code := c.closeCode(t); code != websocket.CloseGoingAway {
+			t.Errorf("client %d closed with %d, want %d", i+1, code, websocket.CloseGoingAway)
+		}
+	}
+
+	// Later clients are turned away
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/?room=1"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var closeErr *websocket.CloseError
+	if _, _, err := conn.ReadMessage(); !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseGoingAway {
+		t.Fatalf("client after Close: %v", err)
+	}
+}
@@ -1,20 +1,215 @@
 package main
 
 import (
-	"log"
+	"context"
+	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
+	"github.com/manish-npx/go-lang/go-rest/auth"
+	"github.com/manish-npx/go-lang/go-rest/config"
+	"github.com/manish-npx/go-lang/go-rest/events"
+	"github.com/manish-npx/go-lang/go-rest/live"
+	"github.com/manish-npx/go-lang/go-rest/metrics"
+	"github.com/manish-npx/go-lang/go-rest/middleware"
+	"github.com/manish-npx/go-lang/go-rest/ratelimit"
+	"github.com/manish-npx/go-lang/go-rest/render"
+	"github.com/manish-npx/go-lang/go-rest/repository"
 	"github.com/manish-npx/go-lang/go-rest/routes"
+	"github.com/manish-npx/go-lang/go-rest/webhooks"
 )
 
 func main() {
-	// Register all routes defined in routes.go
-	routes.RegisterRoutes()
+	// Settings come from flags, env vars and an optional config file
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration:", err)
+		os.Exit(2)
+	}
 
-	// Start the server on port 8080
-	log.Println("✅ Server running on http://localhost:8080")
+	if cfg.PrintConfig {
+		cfg.Print(os.Stdout)
+		return
+	}
 
-	// ListenAndServe keeps the server running.
-	// If it fails, log.Fatal will print the error and stop the program.
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	// Structured logs, one JSON object per line
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: cfg.LogLevel}))
+	slog.SetDefault(logger)
+
+	if err := run(cfg, logger); err != nil {
+		logger.Error("server stopped", "error", err)
+		os.Exit(1)
+	}
+}
+
+// run starts the server and blocks until it has shut down.
+// Keeping this out of main lets the deferred cleanups actually run.
+func run(cfg config.Config, logger *slog.Logger) error {
+	// Secrets are redacted by Config.LogValue
+	logger.Info("⚙️ Loaded configuration", "config", cfg)
+
+	// Open the storage backend picked by the config
+	store, err := openStore(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("open storage: %w", err)
+	}
+	defer func() {
+		// Flush and close the backend once no request can use it anymore
+		if err := store.Close(); err != nil {
+			logger.Error("closing storage failed", "error", err)
+		}
+	}()
+
+	if cfg.AdminEmail != "" {
+		if err := auth.EnsureAdmin(context.Background(), store.Users, cfg.AdminEmail, cfg.AdminPassword); err != nil {
+			return fmt.Errorf("admin account: %w", err)
+		}
+		logger.Info("👑 Admin account ready", "email", cfg.AdminEmail)
+	}
+
+	// Tokens are signed with the configured secret. Without one we make up
+	// a random secret, which means tokens stop working after a restart.
+	secret := []byte(cfg.JWTSecret)
+	if len(secret) == 0 {
+		logger.Warn("🔑 No jwt-secret configured, using a random one: tokens will not survive a restart")
+		secret = make([]byte, config.MinSecretLength)
+		rand.Read(secret)
+	}
+	tokens := auth.NewTokens(secret)
+
+	// Build the router with all routes defined in routes.go. Responses can
+	// be JSON (the default), XML, CSV or MessagePack. Changes are published
+	// to the broadcaster, which feeds the /events stream and the webhooks.
+	m := metrics.New()
+	broadcaster := events.NewBroadcaster(events.DEFAULT_LOG_SIZE)
+	hubs := live.NewHubs()
+	dispatcher := webhooks.NewDispatcher(store.Webhooks, store.WebhookDeliveries, webhooks.Options{})
+	router := routes.NewRouter(store, tokens, m, render.Default(), broadcaster, hubs, dispatcher)
+
+	// Rate limit buckets live in memory; idle ones are dropped every minute
+	rules, err := ratelimit.NewRules(cfg.RateLimit, cfg.RateLimitRoutes)
+	if err != nil {
+		return err
+	}
+	limiter := ratelimit.NewLimiter(time.Minute)
+	defer limiter.Close()
+
+	// Every request goes through the middleware chain first, outermost first
+	cors := middleware.DefaultCORSOptions()
+	cors.AllowedOrigins = cfg.CORSOrigins
+	handler := middleware.Chain(router,
+		middleware.RequestID,
+		middleware.Metrics(m, router.Pattern),
+		middleware.Logging(logger),
+		middleware.Recovery(logger),
+		middleware.CORS(cors),
+		// Every client is limited per IP before its token is checked, so
+		// a flood of bad tokens cannot reach the storage unchecked
+		middleware.RateLimit(middleware.RateLimitOptions{Limiter: limiter, Rules: rules, Exempt: unlimitedPaths}),
+		auth.Authenticate(tokens, store.Users),
+		// Signed-in users are limited per account too
+		middleware.RateLimit(middleware.RateLimitOptions{Limiter: limiter, Rules: rules, Key: accountKey, Exempt: unlimitedPaths}),
+	)
+
+	srv := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelError),
+	}
+	// Event streams never end on their own: close them so Shutdown does
+	// not wait for them until the timeout
+	srv.RegisterOnShutdown(broadcaster.Close)
+
+	// ctx is cancelled on Ctrl+C (SIGINT) or when the process is asked to stop (SIGTERM)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Webhooks are sent in the background until the server is shut down,
+	// so the events of the requests it drains still get their deliveries;
+	// those pending then are sent after the next start. The storage is
+	// closed on return, so the workers are waited for first.
+	dispatchCtx, stopDispatch := context.WithCancel(context.Background())
+	dispatched := make(chan struct{})
+	go func() {
+		dispatcher.Run(dispatchCtx, broadcaster)
+		close(dispatched)
+	}()
+	defer func() {
+		stopDispatch()
+		<-dispatched
+	}()
+
+	// ListenAndServe blocks, so it runs in its own goroutine
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Info("✅ Server running", "addr", cfg.Addr)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		// The server could not start (e.g. the port is taken)
+		return err
+	case <-ctx.Done():
+	}
+
+	// Stop accepting new connections and wait for in-flight requests,
+	// but never longer than the shutdown timeout
+	logger.Info("🛑 Shutting down", "timeout", cfg.ShutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	// Shutdown does not track WebSockets: tell their clients we are going away
+	hubs.Close()
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	logger.Info("👋 Server stopped cleanly")
+	return nil
+}
+
+// openStore returns the repositories for cfg.Storage
+func openStore(cfg config.Config, logger *slog.Logger) (*repository.Store, error) {
+	switch cfg.Storage {
+	case config.StorageSQLite:
+		logger.Info("💾 Using SQLite storage", "dsn", cfg.DSN)
+		return repository.OpenSQLiteStore(cfg.DSN)
+	case config.StorageMemory:
+		logger.Info("🧠 Using in-memory storage")
+		return repository.NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Storage)
+	}
+}
+
+// unlimitedPaths are never rate limited: probes and metrics scrapes
+// must not be refused when the server is busy
+var unlimitedPaths = []string{"/healthz", "/readyz", "/metrics"}
+
+// accountKey limits signed-in users per account. Anonymous requests are
+// left alone: they are limited per IP already.
+func accountKey(r *http.Request) string {
+	if user, ok := auth.UserFromContext(r.Context()); ok {
+		return "user:" + strconv.Itoa(user.ID)
+	}
+	return ""
 }
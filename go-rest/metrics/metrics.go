@@ -0,0 +1,71 @@
+// Package metrics holds the Prometheus metrics of the server and serves
+// them in the Prometheus text format.
+//
+// Requests are labelled by route pattern ("GET /users/{id}"), never by raw
+// path, so the number of series stays bounded whatever clients request.
+// Streams (Server-Sent Events, WebSockets) last as long as the client
+// stays, so their durations are kept apart from those of requests.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Unmatched is the route label of requests that matched no route
+const Unmatched = "unmatched"
+
+// Metrics are the HTTP metrics of the server, kept in their own registry
+type Metrics struct {
+	registry *prometheus.Registry
+
+	// Requests counts finished requests by route and status code
+	Requests *prometheus.CounterVec
+	// Duration observes request latencies by route, streams excepted
+	Duration *prometheus.HistogramVec
+	// StreamDuration observes how long streams stayed open, by route
+	StreamDuration *prometheus.HistogramVec
+	// InFlight is the number of requests being served, by route
+	InFlight *prometheus.GaugeVec
+}
+
+// New registers the HTTP metrics, plus the usual Go runtime and process ones
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		Requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests served, by route pattern and status code.",
+		}, []string{"route", "code"}),
+		Duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Time taken to serve HTTP requests, by route pattern.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route"}),
+		StreamDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "http_stream_duration_seconds",
+			Help: "Time Server-Sent Events and WebSocket streams stayed open, by route pattern.",
+			// 1s to about 4.5h
+			Buckets: prometheus.ExponentialBuckets(1, 4, 8),
+		}, []string{"route"}),
+		InFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "HTTP requests being served right now, by route pattern.",
+		}, []string{"route"}),
+	}
+
+	m.registry.MustRegister(
+		m.Requests, m.Duration, m.StreamDuration, m.InFlight,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
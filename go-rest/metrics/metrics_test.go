@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandlerServesEveryMetric(t *testing.T) {
+	m := New()
+	// Vectors only show once they have a series
+	m.Requests.WithLabelValues("GET /users", "200").Inc()
+	m.Duration.WithLabelValues("GET /users").Observe(0.01)
+	m.StreamDuration.WithLabelValues("GET /events").Observe(90)
+	m.InFlight.WithLabelValues("GET /users").Set(0)
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("status %d, %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	for _, want := range []string{
+		`http_requests_total{code="200",route="GET /users"} 1`,
+		`http_request_duration_seconds_count{route="GET /users"} 1`,
+		`http_stream_duration_seconds_bucket{route="GET /events",le="64"} 0`,
+		`http_stream_duration_seconds_bucket{route="GET /events",le="256"} 1`,
+		`http_requests_in_flight{route="GET /users"} 0`,
+		"go_goroutines ",
+		"process_",
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("no %s in\n%s", want, w.Body)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/manish-npx/go-lang/go-rest/metrics"
+)
+
+// Metrics records the count, latency and concurrency of requests.
+// route returns the route pattern of a request, or "" when none matches;
+// it is asked up front so requests stopped by other middleware (401, 429)
+// are still counted under their route. Streams are timed apart, see
+// metrics.Metrics.StreamDuration.
+func Metrics(m *metrics.Metrics, route func(r *http.Request) string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			label := route(r)
+			if label == "" {
+				label = metrics.Unmatched
+			}
+
+			inFlight := m.InFlight.WithLabelValues(label)
+			inFlight.Inc()
+			defer inFlight.Dec()
+
+			start := time.Now()
+			sw := wrap(w)
+			next.ServeHTTP(sw, r)
+
+			status := sw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			duration := m.Duration
+			if streamed(sw) {
+				duration = m.StreamDuration
+			}
+			duration.WithLabelValues(label).Observe(time.Since(start).Seconds())
+			m.Requests.WithLabelValues(label, strconv.Itoa(status)).Inc()
+		})
+	}
+}
+
+// streamed reports whether the response was a stream: a WebSocket (the
+// connection was hijacked) or Server-Sent Events
+func streamed(sw *statusWriter) bool {
+	if sw.status == http.StatusSwitchingProtocols {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(sw.Header().Get("Content-Type"))
+	return mediaType == "text/event-stream"
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/manish-npx/go-lang/go-rest/metrics"
+)
+
+// scrape returns what m serves at /metrics
+func scrape(t *testing.T, m *metrics.Metrics) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	return w.Body.String()
+}
+
+func TestMetricsTimesStreamsApart(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("GET /events", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		w.Write([]byte("retry: 2000\n\n"))
+	})
+	mux.HandleFunc("GET /events/bad", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad topic", http.StatusBadRequest)
+	})
+	mux.HandleFunc("GET /live", func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+			return
+		}
+		conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n"))
+		conn.Close()
+	})
+	m := metrics.New()
+	route := func(r *http.Request) string {
+		_, pattern := mux.Handler(r)
+		return pattern
+	}
+	// A hijacked connection answers before the middleware is done, so
+	// each request is waited for until it was recorded
+	h := Metrics(m, route)(mux)
+	recorded := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r)
+		recorded <- struct{}{}
+	}))
+	defer srv.Close()
+
+	for _, path := range []string{"/users/1", "/users/2", "/events", "/events/bad", "/live"} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		if path == "/live" {
+			req.Header.Set("Connection", "Upgrade")
+			req.Header.Set("Upgrade", "test")
+		}
+		res, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		res.Body.Close()
+		<-recorded
+	}
+
+	out := scrape(t, m)
+	for _, want := range []string{
+		`http_request_duration_seconds_count{route="GET /users/{id}"} 2`,
+		// A stream that did not start is a request like any other
+		`http_request_duration_seconds_count{route="GET /events/bad"} 1`,
+		`http_stream_duration_seconds_count{route="GET /events"} 1`,
+		`http_stream_duration_seconds_count{route="GET /live"} 1`,
+		`http_requests_total{code="101",route="GET /live"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("no %s in\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{
+		`http_request_duration_seconds_count{route="GET /events"}`,
+		`http_request_duration_seconds_count{route="GET /live"}`,
+		`http_stream_duration_seconds_count{route="GET /users/{id}"}`,
+	} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("%s in\n%s", unwanted, out)
+		}
+	}
+}
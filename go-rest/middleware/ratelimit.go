@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/manish-npx/go-lang/go-rest/apierror"
+	"github.com/manish-npx/go-lang/go-rest/ratelimit"
+)
+
+// RateLimitOptions configures the RateLimit middleware
+type RateLimitOptions struct {
+	Limiter *ratelimit.Limiter
+	Rules   *ratelimit.Rules
+	// Key identifies the client, e.g. by signed-in user.
+	// ClientIP is used when Key is nil. Requests it returns "" for are
+	// not limited.
+	Key func(r *http.Request) string
+	// Exempt lists paths that are never limited, e.g. probes and metrics
+	// scrapes, which must keep working when the server is busy
+	Exempt []string
+}
+
+// RateLimit refuses requests over the limit of their route with 429.
+// Every limited response carries the RateLimit-* headers of the IETF
+// draft, so clients can slow down before they hit the limit, and a 429
+// also carries Retry-After.
+func RateLimit(opts RateLimitOptions) Middleware {
+	key := opts.Key
+	if key == nil {
+		key = ClientIP
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name, rule := opts.Rules.Match(r)
+			client := key(r)
+			if !rule.Enabled() || client == "" || slices.Contains(opts.Exempt, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Each rule has its own buckets, so a busy route does not eat
+			// into the budget of the others
+			res := opts.Limiter.Allow(name+" "+client, rule)
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+			h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", rule.Requests, ceilSeconds(rule.Per)))
+
+			if !res.Allowed {
+				retry := ceilSeconds(res.RetryAfter)
+				h.Set("Retry-After", strconv.Itoa(retry))
+				apierror.Write(w, r, apierror.TooManyRequests(fmt.Sprintf("Too many requests, retry in %d seconds", retry)))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClientIP returns the IP address the request came from. Proxy headers
+// such as X-Forwarded-For are ignored, since any client can set them.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ceilSeconds rounds d up to whole seconds, as the headers want
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
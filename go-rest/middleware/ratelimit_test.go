@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/manish-npx/go-lang/go-rest/ratelimit"
+)
+
+// limited returns a handler answering 200, behind RateLimit with opts.
+// opts.Limiter and opts.Rules default to a fresh limiter and 2 requests a minute.
+func limited(t *testing.T, opts RateLimitOptions) http.Handler {
+	t.Helper()
+	if opts.Limiter == nil {
+		opts.Limiter = ratelimit.NewLimiter(time.Hour)
+		t.Cleanup(opts.Limiter.Close)
+	}
+	if opts.Rules == nil {
+		rules, err := ratelimit.NewRules(ratelimit.Rule{Requests: 2, Per: time.Minute}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts.Rules = rules
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	return RateLimit(opts)(ok)
+}
+
+// get sends GET path from remoteAddr through h
+func get(h http.Handler, path, remoteAddr string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	r.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestRateLimitRefusesWithRetryAfter(t *testing.T) {
+	h := limited(t, RateLimitOptions{})
+
+	for i := range 2 {
+		w := get(h, "/users", "192.0.2.1:1234")
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i+1, w.Code)
+		}
+		if got, want := w.Header().Get("RateLimit-Remaining"), strconv.Itoa(1-i); got != want {
+			t.Errorf("request %d: RateLimit-Remaining = %q, want %q", i+1, got, want)
+		}
+	}
+
+	w := get(h, "/users", "192.0.2.1:1234")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("3rd request: status %d, want 429", w.Code)
+	}
+	// 2 a minute: the next token is 30s away
+	if got := w.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Retry-After = %q, want 30", got)
+	}
+	if got := w.Header().Get("RateLimit-Limit"); got != "2" {
+		t.Errorf("RateLimit-Limit = %q, want 2", got)
+	}
+	if got := w.Header().Get("RateLimit-Policy"); got != "2;w=60" {
+		t.Errorf("RateLimit-Policy = %q, want 2;w=60", got)
+	}
+
+	// Other clients have their own bucket
+	if w := get(h, "/users", "192.0.2.2:1234"); w.Code != http.StatusOK {
+		t.Fatalf("another IP: status %d", w.Code)
+	}
+}
+
+func TestRateLimitExemptPaths(t *testing.T) {
+	h := limited(t, RateLimitOptions{Exempt: []string{"/healthz", "/metrics"}})
+
+	for range 10 {
+		for _, path := range []string{"/healthz", "/metrics"} {
+			w := get(h, path, "192.0.2.1:1234")
+			if w.Code != http.StatusOK {
+				t.Fatalf("%s: status %d", path, w.Code)
+			}
+			if w.Header().Get("RateLimit-Limit") != "" {
+				t.Fatalf("%s: has rate limit headers", path)
+			}
+		}
+	}
+	// Exempt requests do not use up the budget of the others
+	if w := get(h, "/users", "192.0.2.1:1234"); w.Code != http.StatusOK {
+		t.Fatalf("/users: status %d", w.Code)
+	}
+}
+
+func TestRateLimitSkipsEmptyKey(t *testing.T) {
+	h := limited(t, RateLimitOptions{Key: func(r *http.Request) string {
+		return r.Header.Get("X-User")
+	}})
+
+	for range 5 {
+		if w := get(h, "/users", "192.0.2.1:1234"); w.Code != http.StatusOK {
+			t.Fatalf("request without a key: status %d", w.Code)
+		}
+	}
+}
+
+func TestRateLimitPerRouteRules(t *testing.T) {
+	rules, err := ratelimit.NewRules(ratelimit.Rule{Requests: 1, Per: time.Minute}, []ratelimit.RouteRule{
+		{Pattern: "GET /blogs", Rule: ratelimit.Rule{Requests: 3, Per: time.Minute}},
+		{Pattern: "GET /open", Rule: ratelimit.Rule{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := limited(t, RateLimitOptions{Rules: rules})
+
+	codes := func(path string, n int) []int {
+		var codes []int
+		for range n {
+			codes = append(codes, get(h, path, "192.0.2.1:1234").Code)
+		}
+		return codes
+	}
+	if got := codes("/blogs", 4); got[2] != http.StatusOK || got[3] != http.StatusTooManyRequests {
+		t.Errorf("/blogs: %v, want 3 allowed then 429", got)
+	}
+	if got := codes("/users", 2); got[0] != http.StatusOK || got[1] != http.StatusTooManyRequests {
+		t.Errorf("/users: %v, want 1 allowed then 429", got)
+	}
+	for _, code := range codes("/open", 5) {
+		if code != http.StatusOK {
+			t.Fatalf("/open is off but answered %d", code)
+		}
+	}
+}
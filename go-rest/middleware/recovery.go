@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/manish-npx/go-lang/go-rest/apierror"
+	"github.com/manish-npx/go-lang/go-rest/requestid"
+)
+
+// Recovery turns a panic in a handler into a logged stack trace and a
+// JSON 500 response, instead of a dropped connection
+func Recovery(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sw := wrap(w)
+
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				// ErrAbortHandler is net/http's way to abort a response on purpose
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+
+				logger.ErrorContext(r.Context(), "panic in handler",
+					slog.Any("panic", rec),
+					slog.String("stack", string(debug.Stack())),
+					slog.String("request_id", requestid.FromContext(r.Context())),
+				)
+
+				// Too late for a clean error once the handler started writing
+				if sw.status == 0 {
+					apierror.Write(sw, r, apierror.Internal())
+				}
+			}()
+
+			next.ServeHTTP(sw, r)
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Audit actions, one per kind of change
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+)
+
+// Audited resource types
+const (
+	ResourceUser    = "user"
+	ResourceBlog    = "blog"
+	ResourceComment = "comment"
+	ResourceWebhook = "webhook"
+)
+
+// AuditRecord says who changed what, and how. Records are only ever
+// appended, never changed or removed.
+type AuditRecord struct {
+	ID   int       `json:"id"`
+	Time time.Time `json:"time"`
+	// ActorID is the user who made the change, 0 when nobody was signed in
+	ActorID    int    `json:"actor_id"`
+	Action     string `json:"action"`
+	Resource   string `json:"resource"`
+	ResourceID int    `json:"resource_id"`
+	// Changes maps each JSON field that changed to its old and new value
+	Changes map[string]Change `json:"changes"`
+}
+
+// Change is the value of one field before and after a change;
+// nil when the field did not exist on that side
+type Change struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
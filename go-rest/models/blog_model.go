@@ -1,6 +1,21 @@
 package models
 
+import "time"
+
 type Blog struct {
 	ID    int    `json:"id"`
 	Title string `json:"title"`
+	Body  string `json:"body"`
+	// AuthorID is the ID of the User who wrote the blog
+	AuthorID  int       `json:"author_id"`
+	Tags      []string  `json:"tags"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Version starts at 1 and goes up by one on every update. It is owned
+	// by the server and backs the ETag of the blog.
+	Version int `json:"version"`
+	// DeletedAt is set when the blog is deleted. Deleted blogs are kept,
+	// hidden from every read, until they are restored. It is owned by the
+	// server: request bodies cannot set it.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
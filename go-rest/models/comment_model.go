@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// CommentBodyMaxLength is the longest comment allowed, in characters (runes)
+const CommentBodyMaxLength = 2000
+
+// Comment is a reader's reply to a blog
+type Comment struct {
+	ID     int `json:"id"`
+	BlogID int `json:"blog_id"`
+	// AuthorID is the ID of the User who wrote the comment
+	AuthorID  int       `json:"author_id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
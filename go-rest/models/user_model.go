@@ -1,7 +1,40 @@
 package models
 
+import "time"
+
+// Roles a user can have, from most to least powerful
+const (
+	// RoleAdmin can do everything, including managing other users
+	RoleAdmin = "admin"
+	// RoleEditor can publish blogs and edit their own
+	RoleEditor = "editor"
+	// RoleReader can only read and comment
+	RoleReader = "reader"
+)
+
+// DefaultRole is given to users created without one
+const DefaultRole = RoleEditor
+
 type User struct {
-	ID    int    `json:"id"`
+	ID int `json:"id"`
+	// Name is public; Email is only shown to the user themselves and to
+	// admins, and is left out of the JSON when hidden
 	Name  string `json:"name"`
-	Email string `json:"email"`
+	Email string `json:"email,omitempty"`
+	Role  string `json:"role"`
+	// PasswordHash is the bcrypt hash of the password. It is never sent
+	// to or accepted from clients; "-" keeps it out of JSON entirely.
+	PasswordHash string `json:"-"`
+	// Version starts at 1 and goes up by one on every update. It is owned
+	// by the server and backs the ETag of the user.
+	Version int `json:"version"`
+	// DeletedAt is set when the user is deleted. Deleted users are kept,
+	// hidden from every read, until an admin restores them. It is owned
+	// by the server: request bodies cannot set it.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// IsValidRole reports whether role is one of the known roles
+func IsValidRole(role string) bool {
+	return role == RoleAdmin || role == RoleEditor || role == RoleReader
 }
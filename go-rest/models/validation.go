@@ -0,0 +1,110 @@
+package models
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// Blog title limits, counted in characters (runes)
+const (
+	BlogTitleMinLength = 3
+	BlogTitleMaxLength = 200
+)
+
+// FieldError describes one invalid field of a request body
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Validate checks the user fields clients are allowed to send.
+// It returns nil when the user is valid.
+func (u User) Validate() []FieldError {
+	var errs []FieldError
+
+	if strings.TrimSpace(u.Name) == "" {
+		errs = append(errs, FieldError{Field: "name", Message: "must not be empty"})
+	}
+
+	if strings.TrimSpace(u.Email) == "" {
+		errs = append(errs, FieldError{Field: "email", Message: "must not be empty"})
+	} else if !isEmail(u.Email) {
+		errs = append(errs, FieldError{Field: "email", Message: "must be a valid email address"})
+	}
+
+	if !IsValidRole(u.Role) {
+		errs = append(errs, FieldError{Field: "role", Message: "must be admin, editor or reader"})
+	}
+
+	return errs
+}
+
+// Validate checks the blog fields clients are allowed to send.
+// It returns nil when the blog is valid.
+func (b Blog) Validate() []FieldError {
+	var errs []FieldError
+
+	title := strings.TrimSpace(b.Title)
+	if n := utf8.RuneCountInString(title); n < BlogTitleMinLength || n > BlogTitleMaxLength {
+		errs = append(errs, FieldError{Field: "title", Message: fmt.Sprintf("must be between %d and %d characters", BlogTitleMinLength, BlogTitleMaxLength)})
+	}
+
+	if b.AuthorID <= 0 {
+		errs = append(errs, FieldError{Field: "author_id", Message: "must be a positive user id"})
+	}
+
+	for _, tag := range b.Tags {
+		if strings.TrimSpace(tag) == "" {
+			errs = append(errs, FieldError{Field: "tags", Message: "must not contain empty tags"})
+			break
+		}
+	}
+
+	return errs
+}
+
+// Validate checks the comment fields clients are allowed to send.
+// It returns nil when the comment is valid.
+func (c Comment) Validate() []FieldError {
+	var errs []FieldError
+
+	body := strings.TrimSpace(c.Body)
+	if body == "" {
+		errs = append(errs, FieldError{Field: "body", Message: "must not be empty"})
+	} else if utf8.RuneCountInString(body) > CommentBodyMaxLength {
+		errs = append(errs, FieldError{Field: "body", Message: fmt.Sprintf("must be at most %d characters", CommentBodyMaxLength)})
+	}
+
+	return errs
+}
+
+// Validate checks the webhook fields clients are allowed to send.
+// It returns nil when the webhook is valid. Event names are checked by
+// the webhooks package, which knows the events.
+func (h Webhook) Validate() []FieldError {
+	var errs []FieldError
+
+	if u, err := url.Parse(h.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, FieldError{Field: "url", Message: "must be an absolute http or https URL"})
+	}
+
+	if len(h.Events) == 0 {
+		errs = append(errs, FieldError{Field: "events", Message: "must list at least one event"})
+	}
+
+	if h.Secret != "" && utf8.RuneCountInString(h.Secret) < WebhookSecretMinLength {
+		errs = append(errs, FieldError{Field: "secret", Message: fmt.Sprintf("must be at least %d characters", WebhookSecretMinLength)})
+	}
+
+	return errs
+}
+
+// isEmail reports whether s is a bare RFC 5322 address like "bob@example.com".
+// Display names ("Bob <bob@example.com>") are rejected.
+func isEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s && addr.Name == ""
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WebhookSecretMinLength is the shortest signing secret clients may choose
+const WebhookSecretMinLength = 16
+
+// Webhook asks for matching events to be POSTed to a partner's URL
+type Webhook struct {
+	ID  int    `json:"id"`
+	URL string `json:"url"`
+	// Events are the event names to send, e.g. "users.created"; "blogs.*"
+	// matches every blog event and "*" every event
+	Events []string `json:"events"`
+	// Secret signs the payloads. It is only shown when it is set, in the
+	// answer to the create or update that set it.
+	Secret    string    `json:"secret,omitempty"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Delivery statuses
+const (
+	// DeliveryPending is waiting for its next attempt
+	DeliveryPending = "pending"
+	// DeliverySucceeded was answered with a 2xx
+	DeliverySucceeded = "succeeded"
+	// DeliveryDead ran out of attempts and sits in the dead-letter list
+	// until it is retried by hand
+	DeliveryDead = "dead"
+)
+
+// WebhookDelivery is one event sent, or to be sent, to one webhook
+type WebhookDelivery struct {
+	ID        int   `json:"id"`
+	WebhookID int   `json:"webhook_id"`
+	EventID   int64 `json:"event_id"`
+	// Event is the event name, e.g. "blogs.created"
+	Event string `json:"event"`
+	// Payload is the JSON body POSTed to the webhook
+	Payload  json.RawMessage `json:"payload"`
+	Status   string          `json:"status"`
+	Attempts int             `json:"attempts"`
+	// ResponseStatus is the HTTP status of the last attempt, 0 when
+	// there was no answer
+	ResponseStatus int `json:"response_status,omitempty"`
+	// Error says why the last attempt failed
+	Error         string     `json:"error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	LastAttemptAt *time.Time `json:"last_attempt_at,omitempty"`
+	// NextAttemptAt is set while the delivery is pending
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+}
@@ -0,0 +1,50 @@
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+//go:embed docs.html
+var docsPage string
+
+// redocScript is Redoc 2.0.0-rc.59 (MIT licence, https://github.com/Redocly/redoc).
+// It is served by the API itself, so the docs page works offline and
+// loads nothing from third parties.
+//
+//go:embed redoc.standalone.js
+var redocScript []byte
+
+// JSONHandler serves doc as JSON. The document is encoded once, up front.
+func JSONHandler(doc *Document) (http.HandlerFunc, error) {
+	body, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}, nil
+}
+
+// UIHandler serves an HTML page that renders the document at specURL with
+// the Redoc script at scriptURL, see ScriptHandler
+func UIHandler(title, specURL, scriptURL string) http.HandlerFunc {
+	page := strings.NewReplacer("{{TITLE}}", title, "{{SPEC_URL}}", specURL, "{{SCRIPT_URL}}", scriptURL).Replace(docsPage)
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(page))
+	}
+}
+
+// ScriptHandler serves the bundled Redoc script used by the UIHandler page
+func ScriptHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+		// The script only changes with the binary
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		w.Write(redocScript)
+	}
+}
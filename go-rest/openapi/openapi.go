@@ -0,0 +1,261 @@
+// Package openapi builds the OpenAPI 3.1 document of the API from the
+// route table in package routes and the Go types of the request and
+// response bodies, so the docs cannot drift away from the code.
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Route documents one "METHOD /path" of the API
+type Route struct {
+	Method string
+	// Path uses the OpenAPI form, e.g. "/users/{id}". Wildcards are
+	// documented as integer path parameters (they are all IDs).
+	Path    string
+	Summary string
+	Tags    []string
+	// Auth marks routes that need a bearer access token
+	Auth bool
+	// Conditional marks routes that honour ETags: GETs take If-None-Match
+	// and may answer 304, writes take If-Match and may answer 412
+	Conditional bool
+	// Request is a value of the request body type, nil when there is no body
+	Request any
+	// RequestTypes lists the media types the body can be sent as,
+	// "application/json" when empty
+	RequestTypes []string
+	// Response is a value of the success body type, nil for no body
+	Response any
+	// Status is the success status code, 200 when zero
+	Status int
+	// ContentType of the success body, "application/json" when empty
+	ContentType string
+	// Formats lists the media types the success body can be negotiated
+	// into with Accept, when there are several; they answer 406 otherwise
+	Formats []string
+	// Query lists the query string parameters
+	Query []Param
+	// Errors lists the error statuses the route can answer with,
+	// on top of the ones every route can return
+	Errors []int
+}
+
+// Param is one query string parameter
+type Param struct {
+	Name        string
+	Description string
+	// Type is the JSON Schema type: "string" (default) or "integer"
+	Type string
+}
+
+// Validate reports the first missing piece of documentation
+func (r Route) Validate() error {
+	switch {
+	case r.Method == "" || r.Path == "":
+		return fmt.Errorf("route %q %q: method and path are required", r.Method, r.Path)
+	case r.Summary == "":
+		return fmt.Errorf("route %s %s: missing summary", r.Method, r.Path)
+	case len(r.Tags) == 0:
+		return fmt.Errorf("route %s %s: missing tags", r.Method, r.Path)
+	}
+	return nil
+}
+
+// Document is an OpenAPI 3.1 document. Only the parts we use are modelled.
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+type Operation struct {
+	Summary     string                `json:"summary"`
+	OperationID string                `json:"operationId"`
+	Tags        []string              `json:"tags"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+const bearerAuth = "bearerAuth"
+
+var wildcard = regexp.MustCompile(`\{([^}]+)\}`)
+
+// Build returns the document for routes, or an error naming the first
+// route that is not fully documented
+func Build(title, version string, routes []Route) (*Document, error) {
+	doc := &Document{
+		OpenAPI: "3.1.0",
+		Info:    Info{Title: title, Version: version},
+		Paths:   map[string]map[string]*Operation{},
+		Components: Components{
+			Schemas: map[string]*Schema{},
+			SecuritySchemes: map[string]SecurityScheme{
+				bearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+	schemas := newSchemaSet(doc.Components.Schemas)
+	errorSchema := schemas.of(ErrorResponse{})
+
+	for _, rt := range routes {
+		if err := rt.Validate(); err != nil {
+			return nil, err
+		}
+
+		op := &Operation{
+			Summary:     rt.Summary,
+			OperationID: operationID(rt.Method, rt.Path),
+			Tags:        rt.Tags,
+			Responses:   map[string]Response{},
+		}
+
+		for _, m := range wildcard.FindAllStringSubmatch(rt.Path, -1) {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name: m[1], In: "path", Required: true, Schema: &Schema{Type: "integer"},
+			})
+		}
+		for _, q := range rt.Query {
+			typ := q.Type
+			if typ == "" {
+				typ = "string"
+			}
+			op.Parameters = append(op.Parameters, Parameter{
+				Name: q.Name, In: "query", Description: q.Description, Schema: &Schema{Type: typ},
+			})
+		}
+		if rt.Conditional {
+			header, description := "If-Match", "Only change the resource if its ETag is still this one"
+			if rt.Method == http.MethodGet {
+				header, description = "If-None-Match", "Answer 304 if the ETag is still this one"
+			}
+			op.Parameters = append(op.Parameters, Parameter{
+				Name: header, In: "header", Description: description, Schema: &Schema{Type: "string"},
+			})
+		}
+
+		if rt.Request != nil {
+			requestTypes := rt.RequestTypes
+			if len(requestTypes) == 0 {
+				requestTypes = []string{"application/json"}
+			}
+			schema := schemas.of(rt.Request)
+			op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{}}
+			for _, contentType := range requestTypes {
+				op.RequestBody.Content[contentType] = MediaType{Schema: schema}
+			}
+		}
+
+		status := rt.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := Response{Description: http.StatusText(status)}
+		if rt.Response != nil {
+			contentTypes := rt.Formats
+			if len(contentTypes) == 0 && rt.ContentType != "" {
+				contentTypes = []string{rt.ContentType}
+			} else if len(contentTypes) == 0 {
+				contentTypes = []string{"application/json"}
+			}
+			schema := schemas.of(rt.Response)
+			success.Content = map[string]MediaType{}
+			for _, contentType := range contentTypes {
+				success.Content[contentType] = MediaType{Schema: schema}
+			}
+		}
+		op.Responses[strconv.Itoa(status)] = success
+
+		errs := append([]int{http.StatusInternalServerError, http.StatusTooManyRequests}, rt.Errors...)
+		if rt.Conditional && rt.Method == http.MethodGet {
+			op.Responses[strconv.Itoa(http.StatusNotModified)] = Response{Description: http.StatusText(http.StatusNotModified)}
+		} else if rt.Conditional {
+			errs = append(errs, http.StatusPreconditionFailed)
+		}
+		if len(rt.Formats) > 0 {
+			errs = append(errs, http.StatusNotAcceptable)
+		}
+		if rt.Request != nil {
+			errs = append(errs, http.StatusBadRequest, http.StatusUnsupportedMediaType, http.StatusRequestEntityTooLarge)
+		}
+		if rt.Auth {
+			op.Security = []map[string][]string{{bearerAuth: {}}}
+			errs = append(errs, http.StatusUnauthorized, http.StatusForbidden)
+		}
+		for _, code := range errs {
+			op.Responses[strconv.Itoa(code)] = Response{
+				Description: http.StatusText(code),
+				Content:     map[string]MediaType{"application/json": {Schema: errorSchema}},
+			}
+		}
+
+		if doc.Paths[rt.Path] == nil {
+			doc.Paths[rt.Path] = map[string]*Operation{}
+		}
+		method := strings.ToLower(rt.Method)
+		if _, dup := doc.Paths[rt.Path][method]; dup {
+			return nil, fmt.Errorf("route %s %s is documented twice", rt.Method, rt.Path)
+		}
+		doc.Paths[rt.Path][method] = op
+	}
+
+	return doc, nil
+}
+
+// operationID turns "GET /users/{id}/blogs" into "getUsersIdBlogs"
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	words := strings.FieldsFunc(path, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	})
+	for _, w := range words {
+		b.WriteString(strings.ToUpper(w[:1]) + w[1:])
+	}
+	return b.String()
+}
@@ -0,0 +1,132 @@
+package openapi
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/manish-npx/go-lang/go-rest/apierror"
+)
+
+// Schema is a JSON Schema (2020-12, as used by OpenAPI 3.1)
+type Schema struct {
+	Ref         string             `json:"$ref,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	// AdditionalProperties describes the values of a map
+	AdditionalProperties *Schema `json:"additionalProperties,omitempty"`
+}
+
+// ErrorResponse is the body of every error response, see package apierror
+type ErrorResponse struct {
+	Error apierror.Error `json:"error"`
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// schemaSet turns Go types into schemas. Named struct types become
+// components, referenced with $ref, so each model is described once.
+type schemaSet struct {
+	components map[string]*Schema
+}
+
+func newSchemaSet(components map[string]*Schema) *schemaSet {
+	return &schemaSet{components: components}
+}
+
+// of returns the schema of the type of v
+func (s *schemaSet) of(v any) *Schema {
+	return s.forType(reflect.TypeOf(v))
+}
+
+func (s *schemaSet) forType(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.String:
+		return &Schema{Type: "string"}
+	case t.Kind() == reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return &Schema{Type: "number"}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return &Schema{Type: "array", Items: s.forType(t.Elem())}
+	case t.Kind() == reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.forType(t.Elem())}
+	case t.Kind() == reflect.Struct:
+		return s.structSchema(t)
+	default:
+		// interface{} and friends: any JSON value
+		return &Schema{}
+	}
+}
+
+// structSchema describes a struct from its json tags. Named structs are
+// stored once in the components and referenced from everywhere else.
+func (s *schemaSet) structSchema(t reflect.Type) *Schema {
+	name := componentName(t)
+	if name != "" {
+		if _, done := s.components[name]; done {
+			return &Schema{Ref: "#/components/schemas/" + name}
+		}
+		// Reserve the name first, in case the struct refers to itself
+		s.components[name] = &Schema{}
+	}
+
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	s.addFields(schema, t)
+
+	if name == "" {
+		return schema
+	}
+	s.components[name] = schema
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// addFields adds the JSON fields of t to schema, flattening embedded structs
+func (s *schemaSet) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			s.addFields(schema, f.Type)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		schema.Properties[name] = s.forType(f.Type)
+	}
+}
+
+// componentName names a struct type for the components section.
+// Generic types are named after their type argument, so List[models.User]
+// becomes "UserList". Anonymous structs get no name.
+func componentName(t reflect.Type) string {
+	name := t.Name()
+	base, args, generic := strings.Cut(name, "[")
+	if !generic {
+		return name
+	}
+	args = strings.TrimSuffix(args, "]")
+	if i := strings.LastIndex(args, "."); i >= 0 {
+		args = args[i+1:]
+	}
+	return args + base
+}
@@ -0,0 +1,107 @@
+// Package policy decides who may do what. Handlers ask it before every
+// read or write and answer 403 when it says no, so the rules live in
+// one place instead of being spread over the controllers.
+//
+// The caller is the signed-in user, or nil for anonymous requests.
+package policy
+
+import "github.com/manish-npx/go-lang/go-rest/models"
+
+// Action is something a caller wants to do with a resource
+type Action string
+
+const (
+	List   Action = "list"
+	Read   Action = "read"
+	Create Action = "create"
+	Update Action = "update"
+	Delete Action = "delete"
+	// Restore brings back something that was deleted
+	Restore Action = "restore"
+)
+
+// CanUser reports whether caller may perform action on target.
+// Anyone may list and read users (emails are hidden, see CanSeeEmail);
+// only admins create and restore users; users may update and delete themselves.
+func CanUser(caller *models.User, action Action, target models.User) bool {
+	switch action {
+	case List, Read:
+		return true
+	case Create, Restore:
+		return isAdmin(caller)
+	case Update, Delete:
+		return isAdmin(caller) || isSelf(caller, target)
+	default:
+		return false
+	}
+}
+
+// CanBulkUsers reports whether caller may import and export users in
+// bulk: admins only, as exports include every email
+func CanBulkUsers(caller *models.User) bool {
+	return isAdmin(caller)
+}
+
+// CanSeeEmail reports whether caller may see the email of target:
+// users see their own, admins see everyone's
+func CanSeeEmail(caller *models.User, target models.User) bool {
+	return isAdmin(caller) || isSelf(caller, target)
+}
+
+// CanChangeRole reports whether caller may change anyone's role
+func CanChangeRole(caller *models.User) bool {
+	return isAdmin(caller)
+}
+
+// CanBlog reports whether caller may perform action on blog.
+// Anyone may list and read blogs; editors and admins publish; authors
+// update, delete and restore their own blogs, and admins any blog.
+func CanBlog(caller *models.User, action Action, blog models.Blog) bool {
+	switch action {
+	case List, Read:
+		return true
+	case Create:
+		return isAdmin(caller) || isEditor(caller)
+	case Update, Delete, Restore:
+		return isAdmin(caller) || (isEditor(caller) && caller.ID == blog.AuthorID)
+	default:
+		return false
+	}
+}
+
+// CanComment reports whether caller may perform action on comment.
+// Anyone may read comments; every signed-in user, readers included, may
+// comment. Comments cannot be changed once posted.
+func CanComment(caller *models.User, action Action, comment models.Comment) bool {
+	switch action {
+	case List, Read:
+		return true
+	case Create:
+		return caller != nil
+	default:
+		return false
+	}
+}
+
+// CanAudit reports whether caller may read the audit trail: admins only
+func CanAudit(caller *models.User) bool {
+	return isAdmin(caller)
+}
+
+// CanManageWebhooks reports whether caller may manage webhooks and read
+// their deliveries: admins only
+func CanManageWebhooks(caller *models.User) bool {
+	return isAdmin(caller)
+}
+
+func isAdmin(caller *models.User) bool {
+	return caller != nil && caller.Role == models.RoleAdmin
+}
+
+func isEditor(caller *models.User) bool {
+	return caller != nil && caller.Role == models.RoleEditor
+}
+
+func isSelf(caller *models.User, target models.User) bool {
+	return caller != nil && caller.ID == target.ID
+}
@@ -0,0 +1,135 @@
+package policy
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/manish-npx/go-lang/go-rest/models"
+)
+
+var (
+	admin  = &models.User{ID: 1, Role: models.RoleAdmin}
+	editor = &models.User{ID: 2, Role: models.RoleEditor}
+	reader = &models.User{ID: 3, Role: models.RoleReader}
+	// someone is neither of the above: an unknown role gets no more than a reader
+	someone = &models.User{ID: 4, Role: "owner"}
+)
+
+// actions are every action, and one that does not exist
+var actions = []Action{List, Read, Create, Update, Delete, Restore, "publish"}
+
+// allowed returns the actions for which can says yes
+func allowed(can func(Action) bool) []Action {
+	var ok []Action
+	for _, action := range actions {
+		if can(action) {
+			ok = append(ok, action)
+		}
+	}
+	return ok
+}
+
+func TestCanUser(t *testing.T) {
+	tests := []struct {
+		name   string
+		caller *models.User
+		target *models.User
+		want   []Action
+	}{
+		{"anonymous", nil, editor, []Action{List, Read}},
+		{"reader on self", reader, reader, []Action{List, Read, Update, Delete}},
+		{"reader on other", reader, editor, []Action{List, Read}},
+		{"editor on self", editor, editor, []Action{List, Read, Update, Delete}},
+		{"editor on other", editor, reader, []Action{List, Read}},
+		{"unknown role on other", someone, reader, []Action{List, Read}},
+		{"admin on self", admin, admin, []Action{List, Read, Create, Update, Delete, Restore}},
+		{"admin on other", admin, reader, []Action{List, Read, Create, Update, Delete, Restore}},
+	}
+	for _, tt := range tests {
+		got := allowed(func(a Action) bool { return CanUser(tt.caller, a, *tt.target) })
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: allowed %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCanBlog(t *testing.T) {
+	own := models.Blog{ID: 1, AuthorID: editor.ID}
+	readers := models.Blog{ID: 2, AuthorID: reader.ID}
+	tests := []struct {
+		name   string
+		caller *models.User
+		blog   models.Blog
+		want   []Action
+	}{
+		{"anonymous", nil, own, []Action{List, Read}},
+		{"editor on own blog", editor, own, []Action{List, Read, Create, Update, Delete, Restore}},
+		{"editor on other blog", editor, readers, []Action{List, Read, Create}},
+		// A reader may have written a blog before being demoted: it stays read-only
+		{"reader on own blog", reader, readers, []Action{List, Read}},
+		{"reader on other blog", reader, own, []Action{List, Read}},
+		{"unknown role", someone, own, []Action{List, Read}},
+		{"admin on any blog", admin, own, []Action{List, Read, Create, Update, Delete, Restore}},
+	}
+	for _, tt := range tests {
+		got := allowed(func(a Action) bool { return CanBlog(tt.caller, a, tt.blog) })
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: allowed %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCanComment(t *testing.T) {
+	comment := models.Comment{ID: 1, BlogID: 1, AuthorID: reader.ID}
+	tests := []struct {
+		name   string
+		caller *models.User
+		want   []Action
+	}{
+		{"anonymous", nil, []Action{List, Read}},
+		{"author", reader, []Action{List, Read, Create}},
+		{"admin", admin, []Action{List, Read, Create}},
+	}
+	for _, tt := range tests {
+		got := allowed(func(a Action) bool { return CanComment(tt.caller, a, comment) })
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: allowed %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAdminOnly(t *testing.T) {
+	target := *reader
+	tests := []struct {
+		name   string
+		caller *models.User
+		// self is whether caller is the target of CanSeeEmail
+		self bool
+		// admin is what the admin-only rules answer
+		admin bool
+	}{
+		{"anonymous", nil, false, false},
+		{"reader on self", reader, true, false},
+		{"reader on other", &models.User{ID: 5, Role: models.RoleReader}, false, false},
+		{"editor", editor, false, false},
+		{"unknown role", someone, false, false},
+		{"admin", admin, false, true},
+	}
+	for _, tt := range tests {
+		if got := CanChangeRole(tt.caller); got != tt.admin {
+			t.Errorf("%s: CanChangeRole = %v", tt.name, got)
+		}
+		if got := CanManageWebhooks(tt.caller); got != tt.admin {
+			t.Errorf("%s: CanManageWebhooks = %v", tt.name, got)
+		}
+		if got := CanBulkUsers(tt.caller); got != tt.admin {
+			t.Errorf("%s: CanBulkUsers = %v", tt.name, got)
+		}
+		if got := CanAudit(tt.caller); got != tt.admin {
+			t.Errorf("%s: CanAudit = %v", tt.name, got)
+		}
+		if got, want := CanSeeEmail(tt.caller, target), tt.admin || tt.self; got != want {
+			t.Errorf("%s: CanSeeEmail = %v, want %v", tt.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// Package ratelimit implements in-memory token buckets.
+//
+// Every client gets one bucket per rule. A bucket holds up to Rule.Requests
+// tokens and refills at Rule.Requests per Rule.Per; each request takes one
+// token and is refused when the bucket is empty. So a client can burst up
+// to the limit and then keeps going at the refill rate.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rule allows Requests requests per Per. The zero Rule means "no limit".
+type Rule struct {
+	Requests int
+	Per      time.Duration
+}
+
+// ParseRule parses "10/1m" (10 requests a minute), "5/s" or "off"
+func ParseRule(s string) (Rule, error) {
+	s = strings.TrimSpace(s)
+	if s == "off" || s == "0" {
+		return Rule{}, nil
+	}
+	n, per, ok := strings.Cut(s, "/")
+	if !ok {
+		return Rule{}, fmt.Errorf("rate limit %q must look like 10/1m", s)
+	}
+	requests, err := strconv.Atoi(n)
+	if err != nil || requests < 1 {
+		return Rule{}, fmt.Errorf("rate limit %q: request count must be a positive number", s)
+	}
+	// "5/s" reads better than "5/1s"
+	if per != "" && (per[0] < '0' || per[0] > '9') {
+		per = "1" + per
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return Rule{}, fmt.Errorf("rate limit %q: period must be a positive duration like 1m", s)
+	}
+	return Rule{Requests: requests, Per: d}, nil
+}
+
+// Enabled reports whether the rule limits anything
+func (r Rule) Enabled() bool {
+	return r.Requests > 0
+}
+
+func (r Rule) String() string {
+	if !r.Enabled() {
+		return "off"
+	}
+	// "1m" rather than Duration's "1m0s"
+	per := r.Per.String()
+	if strings.HasSuffix(per, "m0s") {
+		per = strings.TrimSuffix(per, "0s")
+	}
+	if strings.HasSuffix(per, "h0m") {
+		per = strings.TrimSuffix(per, "0m")
+	}
+	return fmt.Sprintf("%d/%s", r.Requests, per)
+}
+
+// rate is how many tokens come back per second
+func (r Rule) rate() float64 {
+	return float64(r.Requests) / r.Per.Seconds()
+}
+
+// Result tells the caller what happened to one request
+type Result struct {
+	Allowed bool
+	// Limit is the size of the bucket
+	Limit int
+	// Remaining is the number of requests that can be made right away
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is how long until the next request is allowed, zero if allowed
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	// full is when the bucket is full again, after which it can be
+	// forgotten: a new bucket would behave the same
+	full time.Time
+}
+
+// Limiter keeps the buckets of every client in memory. It is safe for
+// concurrent use. Idle buckets are dropped in the background; call Close
+// to stop that.
+type Limiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	done    chan struct{}
+	once    sync.Once
+	now     func() time.Time
+}
+
+// NewLimiter returns a Limiter that drops idle buckets every sweepEvery
+func NewLimiter(sweepEvery time.Duration) *Limiter {
+	l := &Limiter{
+		buckets: map[string]*bucket{},
+		done:    make(chan struct{}),
+		now:     time.Now,
+	}
+	go l.sweepLoop(sweepEvery)
+	return l
+}
+
+// Allow takes a token from the bucket of key under rule
+func (l *Limiter) Allow(key string, rule Rule) Result {
+	if !rule.Enabled() {
+		return Result{Allowed: true}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	capacity := float64(rule.Requests)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		l.buckets[key] = b
+	}
+
+	// Refill for the time since the last request, up to the capacity
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.last).Seconds()*rule.rate())
+	b.last = now
+
+	res := Result{Limit: rule.Requests}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.tokens) / rule.rate())
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = seconds((capacity - b.tokens) / rule.rate())
+	b.full = now.Add(res.Reset)
+	return res
+}
+
+// Sweep drops the buckets that have refilled completely
+func (l *Limiter) Sweep() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for key, b := range l.buckets {
+		if !now.Before(b.full) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Close stops the background sweeping
+func (l *Limiter) Close() {
+	l.once.Do(func() { close(l.done) })
+}
+
+func (l *Limiter) sweepLoop(every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.Sweep()
+		case <-l.done:
+			return
+		}
+	}
+}
+
+// seconds turns a number of seconds into a Duration
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
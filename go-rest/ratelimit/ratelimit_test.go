@@ -0,0 +1,133 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when told to
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter(t *testing.T) (*Limiter, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := NewLimiter(time.Hour)
+	l.now = clock.now
+	t.Cleanup(l.Close)
+	return l, clock
+}
+
+func TestBucketRefillsOverTime(t *testing.T) {
+	l, clock := newTestLimiter(t)
+	rule := Rule{Requests: 3, Per: 3 * time.Second}
+
+	// The bucket starts full: a burst of 3, then nothing
+	for i := range 3 {
+		if res := l.Allow("a", rule); !res.Allowed || res.Remaining != 2-i {
+			t.Fatalf("request %d: %+v, want allowed with %d remaining", i+1, res, 2-i)
+		}
+	}
+	res := l.Allow("a", rule)
+	if res.Allowed {
+		t.Fatal("4th request of the burst was allowed")
+	}
+	if res.RetryAfter != time.Second {
+		t.Fatalf("RetryAfter = %v, want 1s", res.RetryAfter)
+	}
+
+	// One token comes back per second
+	clock.advance(999 * time.Millisecond)
+	if l.Allow("a", rule).Allowed {
+		t.Fatal("allowed before a token came back")
+	}
+	clock.advance(time.Millisecond)
+	if !l.Allow("a", rule).Allowed {
+		t.Fatal("refused after a token came back")
+	}
+	if l.Allow("a", rule).Allowed {
+		t.Fatal("allowed twice on one token")
+	}
+
+	// Waiting long refills the bucket to its size, not beyond
+	clock.advance(time.Hour)
+	for i := range 3 {
+		if !l.Allow("a", rule).Allowed {
+			t.Fatalf("request %d after a full refill was refused", i+1)
+		}
+	}
+	if l.Allow("a", rule).Allowed {
+		t.Fatal("the bucket refilled beyond its size")
+	}
+}
+
+func TestBucketsAreSeparatePerKey(t *testing.T) {
+	l, _ := newTestLimiter(t)
+	rule := Rule{Requests: 1, Per: time.Minute}
+
+	if !l.Allow("a", rule).Allowed || !l.Allow("b", rule).Allowed {
+		t.Fatal("first request of a key was refused")
+	}
+	if l.Allow("a", rule).Allowed {
+		t.Fatal("a was allowed over its limit")
+	}
+}
+
+func TestDisabledRuleAllowsEverything(t *testing.T) {
+	l, _ := newTestLimiter(t)
+	for range 100 {
+		if !l.Allow("a", Rule{}).Allowed {
+			t.Fatal("the zero Rule refused a request")
+		}
+	}
+}
+
+func TestSweepDropsFullBuckets(t *testing.T) {
+	l, clock := newTestLimiter(t)
+	rule := Rule{Requests: 2, Per: 2 * time.Second}
+	l.Allow("a", rule)
+
+	l.Sweep()
+	if len(l.buckets) != 1 {
+		t.Fatal("a bucket that is not full was dropped")
+	}
+	clock.advance(time.Second)
+	l.Sweep()
+	if len(l.buckets) != 0 {
+		t.Fatal("a full bucket was kept")
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Rule
+		wantErr bool
+	}{
+		{in: "10/1m", want: Rule{Requests: 10, Per: time.Minute}},
+		{in: "5/s", want: Rule{Requests: 5, Per: time.Second}},
+		{in: " 2/30s ", want: Rule{Requests: 2, Per: 30 * time.Second}},
+		{in: "off", want: Rule{}},
+		{in: "0", want: Rule{}},
+		{in: "10", wantErr: true},
+		{in: "0/1m", wantErr: true},
+		{in: "x/1m", wantErr: true},
+		{in: "10/forever", wantErr: true},
+		{in: "10/-1m", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseRule(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
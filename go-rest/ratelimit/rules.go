@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// RouteRule limits the requests matching Pattern, an http.ServeMux pattern
+// such as "POST /users", "GET /blogs/{id}" or "/auth/"
+type RouteRule struct {
+	Pattern string
+	Rule    Rule
+}
+
+// ParseRouteRule parses "POST /users=5/1m"
+func ParseRouteRule(s string) (RouteRule, error) {
+	pattern, rule, ok := strings.Cut(s, "=")
+	if !ok {
+		return RouteRule{}, fmt.Errorf("route rate limit %q must look like \"POST /users=5/1m\"", s)
+	}
+	r, err := ParseRule(rule)
+	if err != nil {
+		return RouteRule{}, err
+	}
+	return RouteRule{Pattern: strings.TrimSpace(pattern), Rule: r}, nil
+}
+
+func (rr RouteRule) String() string {
+	return rr.Pattern + "=" + rr.Rule.String()
+}
+
+// DefaultName is the name Rules.Match gives the default rule
+const DefaultName = "default"
+
+// Rules picks the rule of a request: the most specific matching route
+// rule, chosen exactly like http.ServeMux picks a handler, or else the
+// default rule
+type Rules struct {
+	Default Rule
+	mux     *http.ServeMux
+	routes  map[string]Rule
+}
+
+// NewRules checks the patterns of routes and returns the rule set
+func NewRules(def Rule, routes []RouteRule) (rules *Rules, err error) {
+	rules = &Rules{Default: def, mux: http.NewServeMux(), routes: map[string]Rule{}}
+
+	// ServeMux panics on bad or conflicting patterns; report those as errors
+	defer func() {
+		if p := recover(); p != nil {
+			rules, err = nil, fmt.Errorf("route rate limit: %v", p)
+		}
+	}()
+	for _, rr := range routes {
+		rules.mux.Handle(rr.Pattern, http.NotFoundHandler())
+		rules.routes[rr.Pattern] = rr.Rule
+	}
+	return rules, nil
+}
+
+// Match returns the name (pattern or DefaultName) and rule for r
+func (rs *Rules) Match(r *http.Request) (string, Rule) {
+	if _, pattern := rs.mux.Handler(r); pattern != "" {
+		return pattern, rs.routes[pattern]
+	}
+	return DefaultName, rs.Default
+}
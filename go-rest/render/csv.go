@@ -0,0 +1,112 @@
+package render
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Tabular is implemented by values whose CSV form is a list of rows
+// rather than the value itself, e.g. a page of a list endpoint whose
+// rows are its items
+type Tabular interface {
+	Rows() any
+}
+
+// encodeCSV writes v, a list of objects or a single object, as CSV with
+// a header row of the JSON keys. Nested lists and objects are written as
+// JSON in their cell, null as an empty cell.
+func encodeCSV(w io.Writer, v any) error {
+	if t, ok := v.(Tabular); ok {
+		v = t.Rows()
+	}
+	tree, err := toTree(v)
+	if err != nil {
+		return err
+	}
+
+	var rows []object
+	switch tree := tree.(type) {
+	case object:
+		rows = []object{tree}
+	case []any:
+		for _, item := range tree {
+			row, ok := item.(object)
+			if !ok {
+				return fmt.Errorf("render: CSV rows must be objects, got %T", item)
+			}
+			rows = append(rows, row)
+		}
+	default:
+		return fmt.Errorf("render: cannot write %T as CSV", v)
+	}
+
+	// Columns in order of first appearance: a field some rows leave out
+	// (omitempty) still gets a column
+	var columns []string
+	index := map[string]int{}
+	for _, row := range rows {
+		for _, m := range row {
+			if _, ok := index[m.key]; !ok {
+				index[m.key] = len(columns)
+				columns = append(columns, m.key)
+			}
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := make([]string, len(columns))
+		for _, m := range row {
+			cell, err := csvCell(m.value)
+			if err != nil {
+				return err
+			}
+			record[index[m.key]] = cell
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvCell(v any) (string, error) {
+	switch v := v.(type) {
+	case object, []any:
+		data, err := json.Marshal(v)
+		return string(data), err
+	case string:
+		return CSVText(v), nil
+	}
+	return scalar(v), nil
+}
+
+// CSVText returns s as it should go in a CSV cell. Spreadsheets run
+// cells starting with =, +, -, @, tab or CR as formulas; a leading quote
+// makes them plain text. Text that already looks escaped, e.g. "'=x",
+// gets one more quote so that ParseCSVText gives it back unchanged.
+func CSVText(s string) string {
+	if isFormula(strings.TrimLeft(s, "'")) {
+		return "'" + s
+	}
+	return s
+}
+
+// ParseCSVText undoes CSVText, for CSV files written by this package
+func ParseCSVText(cell string) string {
+	if s, ok := strings.CutPrefix(cell, "'"); ok && isFormula(strings.TrimLeft(s, "'")) {
+		return s
+	}
+	return cell
+}
+
+func isFormula(s string) bool {
+	return s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0]))
+}
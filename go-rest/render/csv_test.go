@@ -0,0 +1,95 @@
+package render
+
+import (
+	"bytes"
+	"encoding/csv"
+	"slices"
+	"testing"
+)
+
+func encodeCSVRecords(t *testing.T, v any) [][]string {
+	t.Helper()
+	var b bytes.Buffer
+	if err := encodeCSV(&b, v); err != nil {
+		t.Fatalf("encodeCSV: %v", err)
+	}
+	records, err := csv.NewReader(&b).ReadAll()
+	if err != nil {
+		t.Fatalf("reading back: %v\n%s", err, b.String())
+	}
+	return records
+}
+
+type page struct {
+	Items []any
+}
+
+func (p page) Rows() any {
+	return p.Items
+}
+
+func TestCSVText(t *testing.T) {
+	tests := []struct {
+		text, cell string
+	}{
+		{"", ""},
+		{"plain", "plain"},
+		{"a=b", "a=b"},
+		{"=SUM(A1:A2)", "'=SUM(A1:A2)"},
+		{"+1", "'+1"},
+		{"-1", "'-1"},
+		{"@cmd", "'@cmd"},
+		{"\tx", "'\tx"},
+		{"\rx", "'\rx"},
+		{"'quoted", "'quoted"},
+		{"'=already", "''=already"},
+		{"''+1", "'''+1"},
+	}
+	for _, tt := range tests {
+		cell := CSVText(tt.text)
+		if cell != tt.cell {
+			t.Errorf("CSVText(%q) = %q, want %q", tt.text, cell, tt.cell)
+		}
+		if back := ParseCSVText(cell); back != tt.text {
+			t.Errorf("ParseCSVText(%q) = %q, want %q", cell, back, tt.text)
+		}
+	}
+}
+
+func TestCSVRows(t *testing.T) {
+	type row struct {
+		ID    int      `json:"id"`
+		Name  string   `json:"name"`
+		Note  string   `json:"note,omitempty"`
+		Tags  []string `json:"tags"`
+		Owner *int     `json:"owner"`
+	}
+	records := encodeCSVRecords(t, page{Items: []any{
+		row{ID: 1, Name: "=HYPERLINK(\"x\")", Tags: []string{"a", "b"}},
+		row{ID: 2, Name: "Bob, \"Jr\"\nline", Note: "-note"},
+	}})
+	want := [][]string{
+		{"id", "name", "tags", "owner", "note"},
+		{"1", `'=HYPERLINK("x")`, `["a","b"]`, "", ""},
+		{"2", "Bob, \"Jr\"\nline", "", "", "'-note"},
+	}
+	if !slices.EqualFunc(records, want, slices.Equal) {
+		t.Fatalf("got  %q\nwant %q", records, want)
+	}
+}
+
+func TestCSVSingleObject(t *testing.T) {
+	records := encodeCSVRecords(t, map[string]any{"id": 7, "ok": true})
+	want := [][]string{{"id", "ok"}, {"7", "true"}}
+	if !slices.EqualFunc(records, want, slices.Equal) {
+		t.Fatalf("got %q, want %q", records, want)
+	}
+}
+
+func TestCSVRejectsOtherShapes(t *testing.T) {
+	for _, v := range []any{1, "text", []int{1, 2}, []any{map[string]int{"a": 1}, "b"}} {
+		if err := encodeCSV(&bytes.Buffer{}, v); err == nil {
+			t.Errorf("encodeCSV(%v) did not fail", v)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// encodeMessagePack writes v as MessagePack, following its JSON form:
+// objects become maps, whole numbers integers, other numbers float64.
+// Times stay RFC 3339 strings, as in JSON.
+func encodeMessagePack(w io.Writer, v any) error {
+	tree, err := toTree(v)
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	if err := writeMessagePack(msgpack.NewEncoder(&b), tree); err != nil {
+		return err
+	}
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+// writeMessagePack writes a tree of toTree. Objects are written member by
+// member so they keep the order of the JSON.
+func writeMessagePack(enc *msgpack.Encoder, v any) error {
+	switch v := v.(type) {
+	case nil:
+		return enc.EncodeNil()
+	case bool:
+		return enc.EncodeBool(v)
+	case string:
+		return enc.EncodeString(v)
+	case json.Number:
+		// The encoder picks the smallest integer encoding
+		if n, err := v.Int64(); err == nil {
+			return enc.EncodeInt(n)
+		}
+		if n, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+			return enc.EncodeUint(n)
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		return enc.EncodeFloat64(f)
+	case []any:
+		if err := enc.EncodeArrayLen(len(v)); err != nil {
+			return err
+		}
+		for _, item := range v {
+			if err := writeMessagePack(enc, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case object:
+		if err := enc.EncodeMapLen(len(v)); err != nil {
+			return err
+		}
+		for _, m := range v {
+			if err := enc.EncodeString(m.key); err != nil {
+				return err
+			}
+			if err := writeMessagePack(enc, m.value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("render: cannot write %T as MessagePack", v)
+}
@@ -0,0 +1,201 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// sameAsJSON decodes b with the reference MessagePack decoder and checks
+// that it holds the same value as the JSON form of v
+func sameAsJSON(t *testing.T, b []byte, v any) {
+	t.Helper()
+	var decoded any
+	if err := msgpack.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("reference decoder: %v", err)
+	}
+	got, err := json.Marshal(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gotTree, wantTree any
+	json.Unmarshal(got, &gotTree)
+	json.Unmarshal(want, &wantTree)
+	if !reflect.DeepEqual(gotTree, wantTree) {
+		t.Fatalf("decoded %.200s, want %.200s", got, want)
+	}
+}
+
+func encodeMsgpack(t *testing.T, v any) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	if err := encodeMessagePack(&b, v); err != nil {
+		t.Fatalf("encodeMessagePack: %v", err)
+	}
+	return b.Bytes()
+}
+
+func TestMessagePackIntegers(t *testing.T) {
+	tests := []struct {
+		n int64
+		// first is the type byte of the smallest encoding; positive
+		// numbers use the unsigned ones
+		first byte
+	}{
+		{0, 0x00},
+		{127, 0x7f},
+		{128, 0xcc},
+		{-1, 0xff},
+		{-32, 0xe0},
+		{-33, 0xd0},
+		{math.MinInt8, 0xd0},
+		{math.MinInt8 - 1, 0xd1},
+		{255, 0xcc},
+		{math.MaxInt16, 0xcd},
+		{math.MaxInt16 + 1, 0xcd},
+		{math.MinInt16, 0xd1},
+		{math.MinInt16 - 1, 0xd2},
+		{math.MaxInt32, 0xce},
+		{math.MaxInt32 + 1, 0xce},
+		{math.MinInt32, 0xd2},
+		{math.MinInt32 - 1, 0xd3},
+		{math.MaxInt64, 0xcf},
+		{math.MinInt64, 0xd3},
+	}
+	for _, tt := range tests {
+		b := encodeMsgpack(t, tt.n)
+		if b[0] != tt.first {
+			t.Errorf("%d: type byte %#x, want %#x", tt.n, b[0], tt.first)
+		}
+		var got int64
+		if err := msgpack.Unmarshal(b, &got); err != nil || got != tt.n {
+			t.Errorf("%d: decoded %d, %v", tt.n, got, err)
+		}
+	}
+}
+
+func TestMessagePackFloats(t *testing.T) {
+	for _, f := range []float64{0.5, -1.25, math.Pi, 1e300, -1e-300} {
+		b := encodeMsgpack(t, f)
+		if b[0] != 0xcb {
+			t.Errorf("%v: type byte %#x, want float64", f, b[0])
+		}
+		var got float64
+		if err := msgpack.Unmarshal(b, &got); err != nil || got != f {
+			t.Errorf("%v: decoded %v, %v", f, got, err)
+		}
+	}
+}
+
+func TestMessagePackSizes(t *testing.T) {
+	tests := []struct {
+		n int
+		// first bytes of a string, an array and a map of n items
+		str, arr, obj byte
+	}{
+		{0, 0xa0, 0x90, 0x80},
+		{15, 0xaf, 0x9f, 0x8f},
+		{16, 0xb0, 0xdc, 0xde},
+		{31, 0xbf, 0xdc, 0xde},
+		{32, 0xd9, 0xdc, 0xde},
+		{255, 0xd9, 0xdc, 0xde},
+		{256, 0xda, 0xdc, 0xde},
+		{math.MaxUint16, 0xda, 0xdc, 0xde},
+		{math.MaxUint16 + 1, 0xdb, 0xdd, 0xdf},
+	}
+	for _, tt := range tests {
+		str := strings.Repeat("é", tt.n/2) + strings.Repeat("x", tt.n%2)
+		arr := make([]int, tt.n)
+		obj := make(map[string]int, tt.n)
+		for i := range tt.n {
+			arr[i] = i
+			obj["k"+strconv.Itoa(i)] = i
+		}
+
+		for _, c := range []struct {
+			kind  string
+			v     any
+			first byte
+		}{{"string", str, tt.str}, {"array", arr, tt.arr}, {"map", obj, tt.obj}} {
+			b := encodeMsgpack(t, c.v)
+			if b[0] != c.first {
+				t.Errorf("%s of %d: type byte %#x, want %#x", c.kind, tt.n, b[0], c.first)
+			}
+			sameAsJSON(t, b, c.v)
+		}
+	}
+}
+
+func TestMessagePackValues(t *testing.T) {
+	type inner struct {
+		Name string `json:"name"`
+	}
+	when := time.Date(2026, 10, 18, 9, 30, 0, 123456789, time.UTC)
+	tests := map[string]any{
+		"nil":         nil,
+		"bools":       []bool{true, false},
+		"nil pointer": struct{ P *inner }{},
+		"nil time":    struct{ T *time.Time }{},
+		"time":        struct{ T time.Time }{when},
+		"nested":      map[string]any{"a": []any{1, "two", 3.5, nil, map[string]any{"b": true}}},
+		"omitempty": struct {
+			A string `json:"a,omitempty"`
+			B int    `json:"b"`
+		}{B: 2},
+		"unicode":         "héllo, 世界 🌍",
+		"raw json":        json.RawMessage(`{"x":[1,2,{"y":null}]}`),
+		"big uint":        uint64(math.MaxUint64),
+		"empty structs":   []inner{},
+		"list of structs": []inner{{"a"}, {"b"}},
+	}
+	for name, v := range tests {
+		t.Run(name, func(t *testing.T) {
+			sameAsJSON(t, encodeMsgpack(t, v), v)
+		})
+	}
+}
+
+func TestMessagePackKeepsFieldOrder(t *testing.T) {
+	v := struct {
+		Z int `json:"z"`
+		A int `json:"a"`
+		M int `json:"m"`
+	}{1, 2, 3}
+	dec := msgpack.NewDecoder(bytes.NewReader(encodeMsgpack(t, v)))
+	n, err := dec.DecodeMapLen()
+	if err != nil || n != 3 {
+		t.Fatalf("map of %d, %v", n, err)
+	}
+	var keys []string
+	for range n {
+		key, _ := dec.DecodeString()
+		dec.Skip()
+		keys = append(keys, key)
+	}
+	if strings.Join(keys, ",") != "z,a,m" {
+		t.Fatalf("keys in order %v, want z,a,m", keys)
+	}
+}
+
+func TestMessagePackTimesAreRFC3339(t *testing.T) {
+	when := time.Date(2026, 10, 18, 9, 30, 0, 0, time.FixedZone("", 2*3600))
+	var got string
+	if err := msgpack.Unmarshal(encodeMsgpack(t, when), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != "2026-10-18T09:30:00+02:00" {
+		t.Fatalf("time encoded as %q", got)
+	}
+}
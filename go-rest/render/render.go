@@ -0,0 +1,211 @@
+// Package render encodes response bodies in the format the client asks
+// for. The format is picked from ?format= when present, otherwise from the
+// Accept header; clients that send neither get JSON.
+//
+// Every format is derived from the JSON form of the value, so field names,
+// omitted fields and time layouts are the same whatever the format.
+// Error responses are not rendered here: they are always JSON, see package
+// apierror.
+package render
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/manish-npx/go-lang/go-rest/apierror"
+)
+
+// Format is one way of encoding a response body
+type Format struct {
+	// Name is what clients pass in ?format=, e.g. "csv"
+	Name string
+	// MediaType is sent as Content-Type and matched against Accept
+	MediaType string
+	// Encode writes v to w
+	Encode func(w io.Writer, v any) error
+}
+
+// Formats supported out of the box
+var (
+	JSON = Format{Name: "json", MediaType: "application/json", Encode: encodeJSON}
+	XML  = Format{Name: "xml", MediaType: "application/xml", Encode: encodeXML}
+	CSV  = Format{Name: "csv", MediaType: "text/csv", Encode: encodeCSV}
+	// MessagePack has no registered media type; this is the usual one
+	MessagePack = Format{Name: "msgpack", MediaType: "application/msgpack", Encode: encodeMessagePack}
+)
+
+// ErrNotAcceptable is returned by Negotiate when no format can satisfy
+// the request
+var ErrNotAcceptable = errors.New("no acceptable format")
+
+// Registry is the set of formats a server offers. The first one is the
+// default, used when the client accepts anything.
+type Registry struct {
+	formats []Format
+}
+
+// NewRegistry returns a registry of formats, the first being the default
+func NewRegistry(formats ...Format) *Registry {
+	return &Registry{formats: formats}
+}
+
+// Default returns a registry of JSON (the default), XML, CSV and MessagePack
+func Default() *Registry {
+	return NewRegistry(JSON, XML, CSV, MessagePack)
+}
+
+// Register adds a format, replacing any format with the same name
+func (reg *Registry) Register(f Format) {
+	if i := slices.IndexFunc(reg.formats, func(g Format) bool { return g.Name == f.Name }); i >= 0 {
+		reg.formats[i] = f
+		return
+	}
+	reg.formats = append(reg.formats, f)
+}
+
+// Formats returns the registered formats, the default first
+func (reg *Registry) Formats() []Format {
+	return slices.Clone(reg.formats)
+}
+
+// Negotiate picks the format of the response to r.
+//
+//	?format=csv                   this format, whatever Accept says
+//	Accept: text/csv              the listed type with the highest q
+//	Accept: application/*;q=0.5   wildcards match any registered type
+//	no Accept at all              the default format
+//
+// When the client prefers a type no format offers, as browsers do with
+// text/html, it gets the default format if it accepts it at all, rather
+// than whichever of its fallbacks happens to be registered.
+//
+// It returns ErrNotAcceptable when the request names only unknown types.
+func (reg *Registry) Negotiate(r *http.Request) (Format, error) {
+	if name := r.URL.Query().Get("format"); name != "" {
+		for _, f := range reg.formats {
+			if strings.EqualFold(f.Name, name) {
+				return f, nil
+			}
+		}
+		return Format{}, ErrNotAcceptable
+	}
+
+	accept := r.Header.Values("Accept")
+	if len(accept) == 0 {
+		return reg.formats[0], nil
+	}
+
+	best, bestQ := Format{}, 0.0
+	for _, f := range reg.formats {
+		// On equal q the earlier format wins, so the default is preferred
+		if q := quality(accept, f.MediaType); q > bestQ {
+			best, bestQ = f, q
+		}
+	}
+	if bestQ == 0 {
+		return Format{}, ErrNotAcceptable
+	}
+	if bestQ < preferred(accept) && quality(accept, reg.formats[0].MediaType) > 0 {
+		return reg.formats[0], nil
+	}
+	return best, nil
+}
+
+// preferred returns the highest q any range in the Accept headers has
+func preferred(accept []string) float64 {
+	top := 0.0
+	for _, header := range accept {
+		for _, part := range strings.Split(header, ",") {
+			if _, params, err := mime.ParseMediaType(strings.TrimSpace(part)); err == nil {
+				top = max(top, qValue(params))
+			}
+		}
+	}
+	return top
+}
+
+// quality returns the q value the Accept headers give mediaType: the one
+// of the most specific matching range, 0 when no range matches
+func quality(accept []string, mediaType string) float64 {
+	typ, _, _ := strings.Cut(mediaType, "/")
+	q, specificity := 0.0, -1
+	for _, header := range accept {
+		for _, part := range strings.Split(header, ",") {
+			rangeType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+
+			s := -1
+			switch {
+			case rangeType == mediaType:
+				s = 2
+			case rangeType == typ+"/*":
+				s = 1
+			case rangeType == "*/*":
+				s = 0
+			}
+			if s <= specificity {
+				continue
+			}
+
+			specificity, q = s, qValue(params)
+		}
+	}
+	return q
+}
+
+// qValue returns the q parameter of a media range, 1 when it has none
+func qValue(params map[string]string) float64 {
+	if v, ok := params["q"]; ok {
+		if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+			return parsed
+		}
+	}
+	return 1
+}
+
+type contextKey struct{}
+
+// WithFormat returns a copy of ctx carrying f, see FromContext
+func WithFormat(ctx context.Context, f Format) context.Context {
+	return context.WithValue(ctx, contextKey{}, f)
+}
+
+// FromContext returns the format chosen by Negotiated, or JSON when the
+// handler was not wrapped
+func FromContext(ctx context.Context) Format {
+	if f, ok := ctx.Value(contextKey{}).(Format); ok {
+		return f
+	}
+	return JSON
+}
+
+// Negotiated wraps next so that the format is picked before it runs:
+// requests nobody can answer get 406 Not Acceptable, so a POST is never
+// carried out only to fail when writing the response. The chosen format
+// is put in the request context.
+func Negotiated(reg *Registry, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f, err := reg.Negotiate(r)
+		if err != nil {
+			supported := make([]string, 0, len(reg.formats))
+			for _, f := range reg.formats {
+				supported = append(supported, f.MediaType)
+			}
+			apierror.Write(w, r, apierror.NotAcceptable(
+				"None of the requested formats is supported; use ?format= or Accept with one of "+strings.Join(supported, ", ")).
+				WithDetails(supported))
+			return
+		}
+		// Caches must keep one copy per format
+		w.Header().Add("Vary", "Accept")
+		next(w, r.WithContext(WithFormat(r.Context(), f)))
+	}
+}
@@ -0,0 +1,53 @@
+package render
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		accept []string
+		want   string // format name, "" for ErrNotAcceptable
+	}{
+		{"no accept", "", nil, "json"},
+		{"anything", "", []string{"*/*"}, "json"},
+		{"exact type", "", []string{"text/csv"}, "csv"},
+		{"highest q", "", []string{"application/xml;q=0.5, text/csv;q=0.7"}, "csv"},
+		{"tie goes to the default", "", []string{"application/xml, application/json"}, "json"},
+		{"tie with a wildcard", "", []string{"application/xml, */*"}, "json"},
+		{"type wildcard", "", []string{"application/*;q=0.5, text/csv;q=0.1"}, "json"},
+		{"most specific range wins", "", []string{"application/*, application/json;q=0"}, "xml"},
+		{"favoured over a wildcard", "", []string{"application/xml, */*;q=0.1"}, "xml"},
+		{"browser", "", []string{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"}, "json"},
+		{"fallback without the default", "", []string{"text/html, application/xml;q=0.9"}, "xml"},
+		{"several headers", "", []string{"text/html", "application/msgpack;q=0.9"}, "msgpack"},
+		{"query over accept", "format=CSV", []string{"application/xml"}, "csv"},
+		{"unknown type", "", []string{"text/html"}, ""},
+		{"refused default", "", []string{"application/json;q=0"}, ""},
+		{"unknown query", "format=yaml", nil, ""},
+	}
+	reg := Default()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/users?"+tt.query, nil)
+			for _, v := range tt.accept {
+				r.Header.Add("Accept", v)
+			}
+			f, err := reg.Negotiate(r)
+			if tt.want == "" {
+				if !errors.Is(err, ErrNotAcceptable) {
+					t.Fatalf("got %q, %v, want ErrNotAcceptable", f.Name, err)
+				}
+				return
+			}
+			if err != nil || f.Name != tt.want {
+				t.Fatalf("got %q, %v, want %q", f.Name, err, tt.want)
+			}
+		})
+	}
+}
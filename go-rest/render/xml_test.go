@@ -0,0 +1,112 @@
+package render
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func encodeXMLString(t *testing.T, v any) string {
+	t.Helper()
+	var b bytes.Buffer
+	if err := encodeXML(&b, v); err != nil {
+		t.Fatalf("encodeXML: %v", err)
+	}
+	return b.String()
+}
+
+func TestXMLShape(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+		want string
+	}{
+		{"scalar", 1, `<response>1</response>`},
+		{"null", nil, `<response></response>`},
+		{"object in field order", struct {
+			ID   int      `json:"id"`
+			Tags []string `json:"tags"`
+			Gone *int     `json:"gone"`
+		}{1, []string{"go", "xml"}, nil},
+			`<response><id>1</id><tags><item>go</item><item>xml</item></tags><gone></gone></response>`},
+		{"bools", []bool{true, false}, `<response><item>true</item><item>false</item></response>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := `<?xml version="1.0" encoding="UTF-8"?>` + tt.want + "\n"
+			if got := encodeXMLString(t, tt.v); got != want {
+				t.Fatalf("got  %s\nwant %s", got, want)
+			}
+		})
+	}
+}
+
+func TestXMLEscapesText(t *testing.T) {
+	for _, text := range []string{
+		`<b>bold</b>`,
+		`Tom & "Jerry"`,
+		`]]> end of CDATA`,
+		"tab\tand\nnewline",
+		"héllo, 世界",
+	} {
+		body := encodeXMLString(t, map[string]string{"title": text})
+		var got struct {
+			Title string `xml:"title"`
+		}
+		if err := xml.Unmarshal([]byte(body), &got); err != nil {
+			t.Fatalf("%q: %v\n%s", text, err, body)
+		}
+		if got.Title != text {
+			t.Errorf("title read back as %q, want %q", got.Title, text)
+		}
+	}
+}
+
+func TestXMLKeysThatAreNotNames(t *testing.T) {
+	tests := []struct {
+		key   string
+		entry bool
+	}{
+		{"name", false},
+		{"created_at", false},
+		{"a.b-c", false},
+		{"1st", true},
+		{"with space", true},
+		{"<tag>", true},
+		{`"quoted" & more`, true},
+		{"xmlns", true},
+		{"XMLThing", true},
+		{"", true},
+	}
+	for _, tt := range tests {
+		body := encodeXMLString(t, map[string]int{tt.key: 1})
+
+		dec := xml.NewDecoder(strings.NewReader(body))
+		var elements []xml.StartElement
+		for {
+			tok, err := dec.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%q: %v\n%s", tt.key, err, body)
+			}
+			if start, ok := tok.(xml.StartElement); ok {
+				elements = append(elements, start.Copy())
+			}
+		}
+		if len(elements) != 2 {
+			t.Fatalf("%q: %d elements\n%s", tt.key, len(elements), body)
+		}
+
+		el := elements[1]
+		switch {
+		case tt.entry && (el.Name.Local != "entry" || len(el.Attr) != 1 || el.Attr[0].Value != tt.key):
+			t.Errorf("%q: got <%s %v>, want <entry key=%q>", tt.key, el.Name.Local, el.Attr, tt.key)
+		case !tt.entry && el.Name.Local != tt.key:
+			t.Errorf("%q: got <%s>", tt.key, el.Name.Local)
+		}
+	}
+}
@@ -0,0 +1,570 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/manish-npx/go-lang/go-rest/models"
+)
+
+// MemoryUserRepository keeps users in a slice guarded by a RWMutex.
+// It is the default storage and is safe for concurrent use.
+type MemoryUserRepository struct {
+	mu    sync.RWMutex
+	users []models.User
+	ids   sequence
+}
+
+// NewMemoryUserRepository returns a repository pre-filled with users
+func NewMemoryUserRepository(users ...models.User) *MemoryUserRepository {
+	r := &MemoryUserRepository{users: slices.Clone(users)}
+	for _, user := range users {
+		r.ids.observe(user.ID)
+	}
+	return r
+}
+
+func (r *MemoryUserRepository) List(ctx context.Context) ([]models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Hand out a copy so callers never share the backing array with us
+	users := []models.User{}
+	for _, user := range r.users {
+		if user.DeletedAt == nil {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (r *MemoryUserRepository) Get(ctx context.Context, id int) (models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i := r.index(id)
+	if i < 0 {
+		return models.User{}, ErrNotFound
+	}
+	return r.users[i], nil
+}
+
+func (r *MemoryUserRepository) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i := r.emailIndex(email)
+	if i < 0 || r.users[i].DeletedAt != nil {
+		return models.User{}, ErrNotFound
+	}
+	return r.users[i], nil
+}
+
+func (r *MemoryUserRepository) Create(ctx context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Deleted users keep their email, so it is checked against them too
+	if r.emailIndex(user.Email) >= 0 {
+		return ErrConflict
+	}
+
+	// Assign a new ID (auto-increment style). The sequence never goes
+	// backwards, so IDs of deleted users are not handed out again.
+	user.ID = r.ids.next()
+	user.Version = 1
+	// Only Delete marks a user deleted, like in the SQLite backend
+	user.DeletedAt = nil
+	r.users = append(r.users, *user)
+	return nil
+}
+
+func (r *MemoryUserRepository) ListAfter(ctx context.Context, afterID, limit int) ([]models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := []models.User{}
+	for _, user := range r.users {
+		if len(users) == limit {
+			break
+		}
+		if user.ID > afterID && user.DeletedAt == nil {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (r *MemoryUserRepository) CreateMany(ctx context.Context, users []models.User, atomic bool) ([]error, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Holding the lock for the whole batch makes it a transaction
+	stored := len(r.users)
+	errs := make([]error, len(users))
+	failed := false
+	for i := range users {
+		if r.emailIndex(users[i].Email) >= 0 {
+			errs[i], failed = ErrConflict, true
+			continue
+		}
+		users[i].ID = r.ids.next()
+		users[i].Version = 1
+		users[i].DeletedAt = nil
+		r.users = append(r.users, users[i])
+	}
+
+	if atomic && failed {
+		r.users = r.users[:stored]
+		for i := range users {
+			users[i].ID, users[i].Version = 0, 0
+		}
+	}
+	return errs, nil
+}
+
+func (r *MemoryUserRepository) Update(ctx context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.index(user.ID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	if r.users[i].Version != user.Version {
+		return ErrVersionMismatch
+	}
+	if j := r.emailIndex(user.Email); j >= 0 && j != i {
+		return ErrConflict
+	}
+	user.Version++
+	user.DeletedAt = r.users[i].DeletedAt
+	r.users[i] = *user
+	return nil
+}
+
+func (r *MemoryUserRepository) Delete(ctx context.Context, id, version int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.index(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	if r.users[i].Version != version {
+		return ErrVersionMismatch
+	}
+	now := time.Now().UTC()
+	r.users[i].DeletedAt = &now
+	r.users[i].Version++
+	return nil
+}
+
+func (r *MemoryUserRepository) GetDeleted(ctx context.Context, id int) (models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i := r.deletedIndex(id)
+	if i < 0 {
+		return models.User{}, ErrNotFound
+	}
+	return r.users[i], nil
+}
+
+func (r *MemoryUserRepository) GetDeletedByEmail(ctx context.Context, email string) (models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i := r.emailIndex(email)
+	if i < 0 || r.users[i].DeletedAt == nil {
+		return models.User{}, ErrNotFound
+	}
+	return r.users[i], nil
+}
+
+func (r *MemoryUserRepository) Restore(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.deletedIndex(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	r.users[i].DeletedAt = nil
+	r.users[i].Version++
+	return nil
+}
+
+// index returns the position of the live (not deleted) user with the
+// given ID, or -1. The caller must hold r.mu.
+func (r *MemoryUserRepository) index(id int) int {
+	return slices.IndexFunc(r.users, func(u models.User) bool { return u.ID == id && u.DeletedAt == nil })
+}
+
+// deletedIndex returns the position of the deleted user with the given
+// ID, or -1. The caller must hold r.mu.
+func (r *MemoryUserRepository) deletedIndex(id int) int {
+	return slices.IndexFunc(r.users, func(u models.User) bool { return u.ID == id && u.DeletedAt != nil })
+}
+
+// emailIndex returns the position of the user with the given email
+// (ignoring case), or -1. The caller must hold r.mu.
+func (r *MemoryUserRepository) emailIndex(email string) int {
+	return slices.IndexFunc(r.users, func(u models.User) bool { return strings.EqualFold(u.Email, email) })
+}
+
+// MemoryBlogRepository keeps blogs in a slice guarded by a RWMutex.
+// It is the default storage and is safe for concurrent use.
+type MemoryBlogRepository struct {
+	mu    sync.RWMutex
+	blogs []models.Blog
+	ids   sequence
+}
+
+// NewMemoryBlogRepository returns a repository pre-filled with blogs
+func NewMemoryBlogRepository(blogs ...models.Blog) *MemoryBlogRepository {
+	r := &MemoryBlogRepository{}
+	for _, blog := range blogs {
+		r.blogs = append(r.blogs, cloneBlog(blog))
+		r.ids.observe(blog.ID)
+	}
+	return r
+}
+
+func (r *MemoryBlogRepository) List(ctx context.Context) ([]models.Blog, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]models.Blog, 0, len(r.blogs))
+	for _, blog := range r.blogs {
+		if blog.DeletedAt == nil {
+			list = append(list, cloneBlog(blog))
+		}
+	}
+	return list, nil
+}
+
+func (r *MemoryBlogRepository) ListByAuthor(ctx context.Context, authorID int) ([]models.Blog, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := []models.Blog{}
+	for _, blog := range r.blogs {
+		if blog.AuthorID == authorID && blog.DeletedAt == nil {
+			list = append(list, cloneBlog(blog))
+		}
+	}
+	return list, nil
+}
+
+func (r *MemoryBlogRepository) Get(ctx context.Context, id int) (models.Blog, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i := r.index(id)
+	if i < 0 {
+		return models.Blog{}, ErrNotFound
+	}
+	return cloneBlog(r.blogs[i]), nil
+}
+
+func (r *MemoryBlogRepository) Create(ctx context.Context, blog *models.Blog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	blog.ID = r.ids.next()
+	blog.Version = 1
+	// Only Delete marks a blog deleted, like in the SQLite backend
+	blog.DeletedAt = nil
+	r.blogs = append(r.blogs, cloneBlog(*blog))
+	return nil
+}
+
+func (r *MemoryBlogRepository) Update(ctx context.Context, blog *models.Blog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.index(blog.ID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	if r.blogs[i].Version != blog.Version {
+		return ErrVersionMismatch
+	}
+	blog.Version++
+	blog.DeletedAt = r.blogs[i].DeletedAt
+	r.blogs[i] = cloneBlog(*blog)
+	return nil
+}
+
+func (r *MemoryBlogRepository) Delete(ctx context.Context, id, version int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.index(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	if r.blogs[i].Version != version {
+		return ErrVersionMismatch
+	}
+	now := time.Now().UTC()
+	r.blogs[i].DeletedAt = &now
+	r.blogs[i].Version++
+	return nil
+}
+
+func (r *MemoryBlogRepository) GetDeleted(ctx context.Context, id int) (models.Blog, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i := r.deletedIndex(id)
+	if i < 0 {
+		return models.Blog{}, ErrNotFound
+	}
+	return cloneBlog(r.blogs[i]), nil
+}
+
+func (r *MemoryBlogRepository) Restore(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.deletedIndex(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	r.blogs[i].DeletedAt = nil
+	r.blogs[i].Version++
+	return nil
+}
+
+// index returns the position of the live (not deleted) blog with the
+// given ID, or -1. The caller must hold r.mu.
+func (r *MemoryBlogRepository) index(id int) int {
+	return slices.IndexFunc(r.blogs, func(b models.Blog) bool { return b.ID == id && b.DeletedAt == nil })
+}
+
+// deletedIndex returns the position of the deleted blog with the given
+// ID, or -1. The caller must hold r.mu.
+func (r *MemoryBlogRepository) deletedIndex(id int) int {
+	return slices.IndexFunc(r.blogs, func(b models.Blog) bool { return b.ID == id && b.DeletedAt != nil })
+}
+
+// cloneBlog copies the Tags slice too, so stored blogs never share memory
+// with the blogs handed in or out of the repository
+func cloneBlog(blog models.Blog) models.Blog {
+	blog.Tags = slices.Clone(blog.Tags)
+	if blog.Tags == nil {
+		blog.Tags = []string{}
+	}
+	return blog
+}
+
+// MemoryCommentRepository keeps comments in a slice guarded by a RWMutex
+type MemoryCommentRepository struct {
+	mu       sync.RWMutex
+	comments []models.Comment
+	ids      sequence
+}
+
+// NewMemoryCommentRepository returns an empty comment repository
+func NewMemoryCommentRepository() *MemoryCommentRepository {
+	return &MemoryCommentRepository{}
+}
+
+func (r *MemoryCommentRepository) ListByBlog(ctx context.Context, blogID int) ([]models.Comment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	comments := []models.Comment{}
+	for _, c := range r.comments {
+		if c.BlogID == blogID {
+			comments = append(comments, c)
+		}
+	}
+	return comments, nil
+}
+
+func (r *MemoryCommentRepository) Create(ctx context.Context, comment *models.Comment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	comment.ID = r.ids.next()
+	r.comments = append(r.comments, *comment)
+	return nil
+}
+
+// MemoryAuditRepository keeps the audit trail in a slice guarded by a RWMutex
+type MemoryAuditRepository struct {
+	mu      sync.RWMutex
+	records []models.AuditRecord
+	ids     sequence
+}
+
+// NewMemoryAuditRepository returns an empty audit trail
+func NewMemoryAuditRepository() *MemoryAuditRepository {
+	return &MemoryAuditRepository{}
+}
+
+func (r *MemoryAuditRepository) Append(ctx context.Context, record *models.AuditRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record.ID = r.ids.next()
+	r.records = append(r.records, *record)
+	return nil
+}
+
+func (r *MemoryAuditRepository) List(ctx context.Context) ([]models.AuditRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]models.AuditRecord{}, r.records...), nil
+}
+
+// MemoryWebhookRepository keeps webhooks in a slice guarded by a RWMutex
+type MemoryWebhookRepository struct {
+	mu    sync.RWMutex
+	hooks []models.Webhook
+	ids   sequence
+}
+
+// NewMemoryWebhookRepository returns an empty webhook repository
+func NewMemoryWebhookRepository() *MemoryWebhookRepository {
+	return &MemoryWebhookRepository{}
+}
+
+func (r *MemoryWebhookRepository) List(ctx context.Context) ([]models.Webhook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	hooks := make([]models.Webhook, 0, len(r.hooks))
+	for _, h := range r.hooks {
+		hooks = append(hooks, cloneWebhook(h))
+	}
+	return hooks, nil
+}
+
+func (r *MemoryWebhookRepository) Get(ctx context.Context, id int) (models.Webhook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, h := range r.hooks {
+		if h.ID == id {
+			return cloneWebhook(h), nil
+		}
+	}
+	return models.Webhook{}, ErrNotFound
+}
+
+func (r *MemoryWebhookRepository) Create(ctx context.Context, hook *models.Webhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hook.ID = r.ids.next()
+	r.hooks = append(r.hooks, cloneWebhook(*hook))
+	return nil
+}
+
+func (r *MemoryWebhookRepository) Update(ctx context.Context, hook *models.Webhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, h := range r.hooks {
+		if h.ID == hook.ID {
+			r.hooks[i] = cloneWebhook(*hook)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *MemoryWebhookRepository) Delete(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, h := range r.hooks {
+		if h.ID == id {
+			r.hooks = slices.Delete(r.hooks, i, i+1)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// cloneWebhook copies the events, so callers never share them with the repository
+func cloneWebhook(hook models.Webhook) models.Webhook {
+	hook.Events = slices.Clone(hook.Events)
+	return hook
+}
+
+// MemoryWebhookDeliveryRepository keeps deliveries in a slice guarded by a RWMutex
+type MemoryWebhookDeliveryRepository struct {
+	mu         sync.RWMutex
+	deliveries []models.WebhookDelivery
+	ids        sequence
+}
+
+// NewMemoryWebhookDeliveryRepository returns an empty delivery log
+func NewMemoryWebhookDeliveryRepository() *MemoryWebhookDeliveryRepository {
+	return &MemoryWebhookDeliveryRepository{}
+}
+
+func (r *MemoryWebhookDeliveryRepository) Get(ctx context.Context, id int) (models.WebhookDelivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, d := range r.deliveries {
+		if d.ID == id {
+			return d, nil
+		}
+	}
+	return models.WebhookDelivery{}, ErrNotFound
+}
+
+func (r *MemoryWebhookDeliveryRepository) ListByWebhook(ctx context.Context, webhookID int) ([]models.WebhookDelivery, error) {
+	return r.listWhere(func(d models.WebhookDelivery) bool { return d.WebhookID == webhookID }), nil
+}
+
+func (r *MemoryWebhookDeliveryRepository) ListByStatus(ctx context.Context, status string) ([]models.WebhookDelivery, error) {
+	return r.listWhere(func(d models.WebhookDelivery) bool { return d.Status == status }), nil
+}
+
+// listWhere returns the deliveries matching keep, oldest first
+func (r *MemoryWebhookDeliveryRepository) listWhere(keep func(models.WebhookDelivery) bool) []models.WebhookDelivery {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	deliveries := []models.WebhookDelivery{}
+	for _, d := range r.deliveries {
+		if keep(d) {
+			deliveries = append(deliveries, d)
+		}
+	}
+	return deliveries
+}
+
+func (r *MemoryWebhookDeliveryRepository) Create(ctx context.Context, delivery *models.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delivery.ID = r.ids.next()
+	r.deliveries = append(r.deliveries, *delivery)
+	return nil
+}
+
+func (r *MemoryWebhookDeliveryRepository) Update(ctx context.Context, delivery *models.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, d := range r.deliveries {
+		if d.ID == delivery.ID {
+			r.deliveries[i] = *delivery
+			return nil
+		}
+	}
+	return ErrNotFound
+}
@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/manish-npx/go-lang/go-rest/models"
+)
+
+// storeBackends returns a fresh, seeded Store of every backend
+func storeBackends(t *testing.T) map[string]*Store {
+	t.Helper()
+	sqlite, err := OpenSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlite.Close() })
+	return map[string]*Store{
+		"memory": NewMemoryStore(),
+		"sqlite": sqlite,
+	}
+}
+
+// userBackends returns a fresh, seeded UserRepository of every backend
+func userBackends(t *testing.T) map[string]UserRepository {
+	t.Helper()
+	users := map[string]UserRepository{}
+	for name, store := range storeBackends(t) {
+		users[name] = store.Users
+	}
+	return users
+}
+
+func TestConcurrentCreatesNeverReuseIDs(t *testing.T) {
+	const (
+		workers = 16
+		creates = 50
+	)
+	for name, users := range userBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			seeded, err := users.List(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ids := make(chan int, workers*creates)
+			var wg sync.WaitGroup
+			for w := range workers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := range creates {
+						user := models.User{
+							Name:  fmt.Sprintf("Worker %d", w),
+							Email: fmt.Sprintf("w%d-%d@example.com", w, i),
+							Role:  models.RoleReader,
+						}
+						if err := users.Create(ctx, &user); err != nil {
+							t.Errorf("create %s: %v", user.Email, err)
+							return
+						}
+						ids <- user.ID
+						// Deleting as we go must not free the ID for someone else
+						if i%5 == 0 {
+							if err := users.Delete(ctx, user.ID, user.Version); err != nil {
+								t.Errorf("delete %d: %v", user.ID, err)
+							}
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			close(ids)
+
+			seen := map[int]bool{}
+			for _, user := range seeded {
+				seen[user.ID] = true
+			}
+			for id := range ids {
+				if seen[id] {
+					t.Fatalf("ID %d was handed out twice", id)
+				}
+				seen[id] = true
+			}
+			if got, want := len(seen), len(seeded)+workers*creates; got != want {
+				t.Fatalf("got %d distinct IDs, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestStaleDeleteKeepsTheRecord(t *testing.T) {
+	for name, store := range storeBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			// A user is read, then updated by someone else before the delete
+			user := models.User{Name: "Carol", Email: "carol@example.com", Role: models.RoleReader}
+			if err := store.Users.Create(ctx, &user); err != nil {
+				t.Fatal(err)
+			}
+			read := user.Version
+			user.Name = "Renamed"
+			if err := store.Users.Update(ctx, &user); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Users.Delete(ctx, user.ID, read); !errors.Is(err, ErrVersionMismatch) {
+				t.Fatalf("stale user delete: %v, want ErrVersionMismatch", err)
+			}
+			if got, err := store.Users.Get(ctx, user.ID); err != nil || got.Name != "Renamed" {
+				t.Fatalf("user after a stale delete: %+v, %v", got, err)
+			}
+			if err := store.Users.Delete(ctx, user.ID, user.Version); err != nil {
+				t.Fatalf("user delete: %v", err)
+			}
+			if err := store.Users.Delete(ctx, user.ID, user.Version+1); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("second user delete: %v, want ErrNotFound", err)
+			}
+
+			blog := models.Blog{Title: "Title", Body: "Body", AuthorID: user.ID, Tags: []string{}}
+			if err := store.Blogs.Create(ctx, &blog); err != nil {
+				t.Fatal(err)
+			}
+			read = blog.Version
+			blog.Title = "Renamed"
+			if err := store.Blogs.Update(ctx, &blog); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Blogs.Delete(ctx, blog.ID, read); !errors.Is(err, ErrVersionMismatch) {
+				t.Fatalf("stale blog delete: %v, want ErrVersionMismatch", err)
+			}
+			if got, err := store.Blogs.Get(ctx, blog.ID); err != nil || got.Title != "Renamed" {
+				t.Fatalf("blog after a stale delete: %+v, %v", got, err)
+			}
+			if err := store.Blogs.Delete(ctx, blog.ID, blog.Version); err != nil {
+				t.Fatalf("blog delete: %v", err)
+			}
+			if hits, _ := store.BlogSearch.Search(ctx, "Renamed"); len(hits) != 0 {
+				t.Fatalf("deleted blog still found: %v", hits)
+			}
+		})
+	}
+}
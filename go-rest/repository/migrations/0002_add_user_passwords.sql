@@ -0,0 +1,4 @@
+ALTER TABLE users ADD COLUMN password_hash TEXT NOT NULL DEFAULT '';
+
+-- Emails identify users at login, so two users cannot share one
+CREATE UNIQUE INDEX users_email ON users (email COLLATE NOCASE);
@@ -0,0 +1,3 @@
+-- Users that existed before roles could already publish blogs,
+-- so they start as editors
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'editor';
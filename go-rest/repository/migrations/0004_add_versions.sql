@@ -0,0 +1,3 @@
+-- Every update bumps version; it backs ETags and optimistic concurrency
+ALTER TABLE users ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE blogs ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,18 @@
+-- Deleting only sets deleted_at, so records can be restored
+ALTER TABLE users ADD COLUMN deleted_at TEXT;
+ALTER TABLE blogs ADD COLUMN deleted_at TEXT;
+
+-- Append-only trail of every change made through the API.
+-- changes is a JSON object of field => {"before": ..., "after": ...}.
+CREATE TABLE audit_log (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    time        TEXT NOT NULL,
+    actor_id    INTEGER NOT NULL,
+    action      TEXT NOT NULL,
+    resource    TEXT NOT NULL,
+    resource_id INTEGER NOT NULL,
+    changes     TEXT NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX audit_log_resource ON audit_log (resource, resource_id);
+CREATE INDEX audit_log_actor_id ON audit_log (actor_id);
@@ -0,0 +1,11 @@
+-- Comments are only ever added. They stay when their blog is deleted,
+-- hidden along with it, and come back when it is restored.
+CREATE TABLE comments (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    blog_id    INTEGER NOT NULL REFERENCES blogs (id),
+    author_id  INTEGER NOT NULL REFERENCES users (id),
+    body       TEXT NOT NULL,
+    created_at TEXT NOT NULL
+);
+
+CREATE INDEX comments_blog_id ON comments (blog_id);
@@ -0,0 +1,119 @@
+// Package repository hides where users and blogs are stored from the
+// controllers. Controllers only see the interfaces below, so the storage can
+// be swapped (in-memory, database, ...) without touching any handler.
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/manish-npx/go-lang/go-rest/models"
+)
+
+// ErrNotFound is returned when no record has the requested ID
+var ErrNotFound = errors.New("record not found")
+
+// ErrConflict is returned when a write would break a uniqueness rule,
+// e.g. two users with the same email
+var ErrConflict = errors.New("record already exists")
+
+// ErrVersionMismatch is returned by Update when the record was changed
+// since the caller read it, i.e. its version is no longer the one sent
+var ErrVersionMismatch = errors.New("record version mismatch")
+
+// Deleting is soft: Delete only marks a record deleted. Deleted records
+// are left out of List, Get and the like, and can be brought back with
+// Restore. GetDeleted finds them in the meantime.
+
+// UserRepository stores models.User records
+type UserRepository interface {
+	List(ctx context.Context) ([]models.User, error)
+	Get(ctx context.Context, id int) (models.User, error)
+	// GetByEmail finds a user by email, ignoring case
+	GetByEmail(ctx context.Context, email string) (models.User, error)
+	// ListAfter returns up to limit users with an ID above afterID, by ID.
+	// Going through every user a page at a time this way never holds
+	// them all in memory, nor the storage for long.
+	ListAfter(ctx context.Context, afterID, limit int) ([]models.User, error)
+	// Create assigns the new ID to user.ID and sets user.Version to 1.
+	// Emails are unique (ignoring case): a duplicate gives ErrConflict.
+	Create(ctx context.Context, user *models.User) error
+	// CreateMany creates users like Create, in one transaction. errs[i]
+	// is the error of users[i], nil when it was created. With atomic, a
+	// single failure rolls every user back, and their IDs are reset to 0.
+	// err is for failures of the whole batch.
+	CreateMany(ctx context.Context, users []models.User, atomic bool) (errs []error, err error)
+	// Update replaces the stored user if its version is still user.Version
+	// (ErrVersionMismatch otherwise) and then bumps user.Version.
+	Update(ctx context.Context, user *models.User) error
+	// Delete marks the user deleted if its version is still version
+	// (ErrVersionMismatch otherwise)
+	Delete(ctx context.Context, id, version int) error
+	// GetDeleted returns a deleted user; ErrNotFound if there is no
+	// user with that ID or it is not deleted
+	GetDeleted(ctx context.Context, id int) (models.User, error)
+	// GetDeletedByEmail is GetByEmail for deleted users
+	GetDeletedByEmail(ctx context.Context, email string) (models.User, error)
+	// Restore undoes Delete. Deleted users keep their email, so restoring
+	// never conflicts.
+	Restore(ctx context.Context, id int) error
+}
+
+// BlogRepository stores models.Blog records
+type BlogRepository interface {
+	List(ctx context.Context) ([]models.Blog, error)
+	ListByAuthor(ctx context.Context, authorID int) ([]models.Blog, error)
+	Get(ctx context.Context, id int) (models.Blog, error)
+	// Create assigns the new ID to blog.ID and sets blog.Version to 1
+	Create(ctx context.Context, blog *models.Blog) error
+	// Update replaces the stored blog if its version is still blog.Version
+	// (ErrVersionMismatch otherwise) and then bumps blog.Version.
+	Update(ctx context.Context, blog *models.Blog) error
+	// Delete marks the blog deleted if its version is still version
+	// (ErrVersionMismatch otherwise)
+	Delete(ctx context.Context, id, version int) error
+	// GetDeleted returns a deleted blog; ErrNotFound if there is no
+	// blog with that ID or it is not deleted
+	GetDeleted(ctx context.Context, id int) (models.Blog, error)
+	// Restore undoes Delete
+	Restore(ctx context.Context, id int) error
+}
+
+// CommentRepository stores models.Comment records
+type CommentRepository interface {
+	// ListByBlog returns the comments of a blog, oldest first
+	ListByBlog(ctx context.Context, blogID int) ([]models.Comment, error)
+	// Create assigns the new ID to comment.ID
+	Create(ctx context.Context, comment *models.Comment) error
+}
+
+// WebhookRepository stores models.Webhook records. Deleting a webhook
+// removes it for good; its deliveries stay in the log.
+type WebhookRepository interface {
+	List(ctx context.Context) ([]models.Webhook, error)
+	Get(ctx context.Context, id int) (models.Webhook, error)
+	// Create assigns the new ID to hook.ID
+	Create(ctx context.Context, hook *models.Webhook) error
+	Update(ctx context.Context, hook *models.Webhook) error
+	Delete(ctx context.Context, id int) error
+}
+
+// WebhookDeliveryRepository stores models.WebhookDelivery records
+type WebhookDeliveryRepository interface {
+	Get(ctx context.Context, id int) (models.WebhookDelivery, error)
+	// ListByWebhook returns the deliveries of a webhook, oldest first
+	ListByWebhook(ctx context.Context, webhookID int) ([]models.WebhookDelivery, error)
+	// ListByStatus returns the deliveries with status, oldest first
+	ListByStatus(ctx context.Context, status string) ([]models.WebhookDelivery, error)
+	// Create assigns the new ID to delivery.ID
+	Create(ctx context.Context, delivery *models.WebhookDelivery) error
+	Update(ctx context.Context, delivery *models.WebhookDelivery) error
+}
+
+// AuditRepository stores the audit trail. It is append-only.
+type AuditRepository interface {
+	// Append assigns the new ID to record.ID
+	Append(ctx context.Context, record *models.AuditRecord) error
+	// List returns every record, oldest first
+	List(ctx context.Context) ([]models.AuditRecord, error)
+}
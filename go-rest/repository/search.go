@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/manish-npx/go-lang/go-rest/models"
+	"github.com/manish-npx/go-lang/go-rest/search"
+)
+
+// BlogSearcher finds blogs by the words in their title, body and tags
+type BlogSearcher interface {
+	// Search returns the hits for query, best first. See package search
+	// for the query syntax; a query without words gives search.ErrEmptyQuery.
+	Search(ctx context.Context, query string) ([]search.Hit, error)
+}
+
+// Field weights: a word in the title says more about a blog than one in the body
+const (
+	titleWeight = 3
+	tagsWeight  = 2
+	bodyWeight  = 1
+)
+
+// IndexedBlogRepository wraps a BlogRepository and keeps an in-memory
+// full-text index of its blogs up to date on every create, update, delete
+// and restore
+type IndexedBlogRepository struct {
+	BlogRepository
+	index *search.Index
+	// writes is held across a write and the index update that follows it,
+	// so concurrent writes reach the index in the order they were stored
+	writes sync.Mutex
+}
+
+// NewIndexedBlogRepository indexes the blogs already in blogs and returns
+// the wrapped repository
+func NewIndexedBlogRepository(ctx context.Context, blogs BlogRepository) (*IndexedBlogRepository, error) {
+	r := &IndexedBlogRepository{BlogRepository: blogs, index: search.NewIndex()}
+
+	existing, err := blogs.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, blog := range existing {
+		r.put(blog)
+	}
+	return r, nil
+}
+
+func (r *IndexedBlogRepository) Create(ctx context.Context, blog *models.Blog) error {
+	r.writes.Lock()
+	defer r.writes.Unlock()
+
+	if err := r.BlogRepository.Create(ctx, blog); err != nil {
+		return err
+	}
+	r.put(*blog)
+	return nil
+}
+
+func (r *IndexedBlogRepository) Update(ctx context.Context, blog *models.Blog) error {
+	r.writes.Lock()
+	defer r.writes.Unlock()
+
+	if err := r.BlogRepository.Update(ctx, blog); err != nil {
+		return err
+	}
+	r.put(*blog)
+	return nil
+}
+
+func (r *IndexedBlogRepository) Delete(ctx context.Context, id, version int) error {
+	r.writes.Lock()
+	defer r.writes.Unlock()
+
+	if err := r.BlogRepository.Delete(ctx, id, version); err != nil {
+		return err
+	}
+	r.index.Remove(id)
+	return nil
+}
+
+func (r *IndexedBlogRepository) Restore(ctx context.Context, id int) error {
+	r.writes.Lock()
+	defer r.writes.Unlock()
+
+	if err := r.BlogRepository.Restore(ctx, id); err != nil {
+		return err
+	}
+	blog, err := r.BlogRepository.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	r.put(blog)
+	return nil
+}
+
+func (r *IndexedBlogRepository) Search(ctx context.Context, query string) ([]search.Hit, error) {
+	return r.index.Search(query)
+}
+
+func (r *IndexedBlogRepository) put(blog models.Blog) {
+	r.index.Put(blog.ID,
+		search.Field{Name: "title", Text: blog.Title, Weight: titleWeight},
+		search.Field{Name: "tags", Text: strings.Join(blog.Tags, ", "), Weight: tagsWeight},
+		search.Field{Name: "body", Text: blog.Body, Weight: bodyWeight},
+	)
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/manish-npx/go-lang/go-rest/models"
+	"github.com/manish-npx/go-lang/go-rest/search"
+)
+
+// pausingBlogs stops right after storing a blog titled pauseTitle, until
+// release is closed, to let another write slip in before the index update
+type pausingBlogs struct {
+	BlogRepository
+	pauseTitle string
+	stored     chan struct{}
+	release    chan struct{}
+}
+
+func (p *pausingBlogs) Update(ctx context.Context, blog *models.Blog) error {
+	if err := p.BlogRepository.Update(ctx, blog); err != nil {
+		return err
+	}
+	if blog.Title == p.pauseTitle {
+		close(p.stored)
+		<-p.release
+	}
+	return nil
+}
+
+func hitIDs(hits []search.Hit) []int {
+	var ids []int
+	for _, h := range hits {
+		ids = append(ids, h.ID)
+	}
+	return ids
+}
+
+func TestIndexFollowsTheOrderOfWrites(t *testing.T) {
+	ctx := context.Background()
+	inner := &pausingBlogs{
+		BlogRepository: NewMemoryBlogRepository(SeedBlogs()...),
+		pauseTitle:     "first",
+		stored:         make(chan struct{}),
+		release:        make(chan struct{}),
+	}
+	blogs, err := NewIndexedBlogRepository(ctx, inner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	update := func(title string, version int) {
+		defer wg.Done()
+		blog, err := inner.Get(ctx, 1)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		blog.Title, blog.Version = title, version
+		if err := blogs.Update(ctx, &blog); err != nil {
+			t.Errorf("update to %q: %v", title, err)
+		}
+	}
+
+	// "first" is stored, then held before it is indexed
+	wg.Add(1)
+	go update("first", 1)
+	select {
+	case <-inner.stored:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the first update was not stored")
+	}
+
+	// "second" is written on top of it in the meantime
+	wg.Add(1)
+	go update("second", 2)
+	time.Sleep(20 * time.Millisecond)
+	close(inner.release)
+	wg.Wait()
+
+	stored, err := blogs.Get(ctx, 1)
+	if err != nil || stored.Title != "second" {
+		t.Fatalf("stored title = %q, %v; want second", stored.Title, err)
+	}
+	for query, want := range map[string][]int{"second": {1}, "first": nil} {
+		hits, err := blogs.Search(ctx, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hitIDs(hits); !slices.Equal(got, want) {
+			t.Errorf("Search(%q) = %v, want %v: the index does not match the stored blog", query, got, want)
+		}
+	}
+}
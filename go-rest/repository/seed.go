@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/manish-npx/go-lang/go-rest/models"
+)
+
+// SeedUsers returns the demo users the API starts with
+func SeedUsers() []models.User {
+	return []models.User{
+		{ID: 1, Name: "Alice", Email: "alice@example.com", Role: models.RoleAdmin, Version: 1},
+		{ID: 2, Name: "Bob", Email: "bob@example.com", Role: models.RoleEditor, Version: 1},
+	}
+}
+
+// SeedBlogs returns the demo blogs the API starts with, one per seed user
+func SeedBlogs() []models.Blog {
+	now := time.Now().UTC()
+	return []models.Blog{
+		{ID: 1, Title: "New Blog Title-1", AuthorID: 1, Tags: []string{}, CreatedAt: now, UpdatedAt: now, Version: 1},
+		{ID: 2, Title: "New Blog Title-2", AuthorID: 2, Tags: []string{}, CreatedAt: now, UpdatedAt: now, Version: 1},
+	}
+}
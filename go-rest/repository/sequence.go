@@ -0,0 +1,21 @@
+package repository
+
+// sequence hands out increasing IDs and never gives the same ID twice,
+// even after records are deleted. It is not safe on its own: the
+// repository that owns it must hold its mutex while calling it.
+type sequence struct {
+	last int
+}
+
+// next returns a fresh ID
+func (s *sequence) next() int {
+	s.last++
+	return s.last
+}
+
+// observe makes sure future IDs are above id (used for seed data)
+func (s *sequence) observe(id int) {
+	if id > s.last {
+		s.last = id
+	}
+}
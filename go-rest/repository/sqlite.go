@@ -0,0 +1,791 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"time"
+
+	"github.com/manish-npx/go-lang/go-rest/models"
+
+	// Importing the package also registers the pure-Go "sqlite" driver
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// pingTimeout bounds how long Store.Ping waits for the database
+const pingTimeout = time.Second
+
+// OpenSQLiteStore opens (or creates) the SQLite database at dsn,
+// applies any pending migrations and returns a Store backed by it.
+func OpenSQLiteStore(dsn string) (*Store, error) {
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite %q: %w", dsn, err)
+	}
+
+	// SQLite allows a single writer anyway; one connection also keeps
+	// ":memory:" databases alive for the whole life of the store.
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(context.Background(), db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// The search index lives in memory and is rebuilt from the table at startup
+	blogs, err := NewIndexedBlogRepository(context.Background(), &SQLiteBlogRepository{db: db})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("index blogs: %w", err)
+	}
+
+	return &Store{
+		Users:             &SQLiteUserRepository{db: db},
+		Blogs:             blogs,
+		BlogSearch:        blogs,
+		Comments:          &SQLiteCommentRepository{db: db},
+		Audit:             &SQLiteAuditRepository{db: db},
+		Webhooks:          &SQLiteWebhookRepository{db: db},
+		WebhookDeliveries: &SQLiteWebhookDeliveryRepository{db: db},
+		close:             db.Close,
+		ping: func(ctx context.Context) error {
+			// The probe waits for the one connection like any request, but
+			// not for long: a store that busy is not ready
+			ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+			defer cancel()
+			return db.PingContext(ctx)
+		},
+	}, nil
+}
+
+// migrate applies every file in migrations/ that is not yet recorded in
+// schema_migrations, in file name order, each in its own transaction.
+func migrate(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    TEXT PRIMARY KEY,
+		applied_at TEXT NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var applied int
+		err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations WHERE version = ?`, name).Scan(&applied)
+		if err != nil {
+			return fmt.Errorf("check migration %s: %w", name, err)
+		}
+		if applied > 0 {
+			continue
+		}
+
+		script, err := migrations.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, string(script)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("apply migration %s: %w", name, err)
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`,
+			name, time.Now().UTC().Format(time.RFC3339))
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("record migration %s: %w", name, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit migration %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// SQLiteUserRepository stores users in the users table.
+// Deleted users keep their row, with deleted_at set.
+type SQLiteUserRepository struct {
+	db *sql.DB
+}
+
+const userColumns = `id, name, email, role, password_hash, version, deleted_at`
+
+func (r *SQLiteUserRepository) List(ctx context.Context) ([]models.User, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+userColumns+` FROM users WHERE deleted_at IS NULL ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (r *SQLiteUserRepository) Get(ctx context.Context, id int) (models.User, error) {
+	return r.getWhere(ctx, `id = ? AND deleted_at IS NULL`, id)
+}
+
+func (r *SQLiteUserRepository) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	return r.getWhere(ctx, `email = ? COLLATE NOCASE AND deleted_at IS NULL`, email)
+}
+
+func (r *SQLiteUserRepository) GetDeleted(ctx context.Context, id int) (models.User, error) {
+	return r.getWhere(ctx, `id = ? AND deleted_at IS NOT NULL`, id)
+}
+
+func (r *SQLiteUserRepository) GetDeletedByEmail(ctx context.Context, email string) (models.User, error) {
+	return r.getWhere(ctx, `email = ? COLLATE NOCASE AND deleted_at IS NOT NULL`, email)
+}
+
+// getWhere returns the single user matching the WHERE clause
+func (r *SQLiteUserRepository) getWhere(ctx context.Context, where string, args ...any) (models.User, error) {
+	u, err := scanUser(r.db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE `+where, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, ErrNotFound
+	}
+	return u, err
+}
+
+func (r *SQLiteUserRepository) Create(ctx context.Context, user *models.User) error {
+	res, err := r.db.ExecContext(ctx, `INSERT INTO users (name, email, role, password_hash) VALUES (?, ?, ?, ?)`,
+		user.Name, user.Email, user.Role, user.PasswordHash)
+	if err != nil {
+		return uniqueError(err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	user.ID = int(id)
+	user.Version = 1
+	return nil
+}
+
+func (r *SQLiteUserRepository) ListAfter(ctx context.Context, afterID, limit int) ([]models.User, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+userColumns+` FROM users WHERE id > ? AND deleted_at IS NULL ORDER BY id LIMIT ?`, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (r *SQLiteUserRepository) CreateMany(ctx context.Context, users []models.User, atomic bool) ([]error, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	insert, err := tx.PrepareContext(ctx, `INSERT INTO users (name, email, role, password_hash) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		return nil, err
+	}
+	defer insert.Close()
+
+	// A failed INSERT only undoes itself, so the others can go on
+	errs := make([]error, len(users))
+	failed := false
+	for i, user := range users {
+		res, err := insert.ExecContext(ctx, user.Name, user.Email, user.Role, user.PasswordHash)
+		if err != nil {
+			if err = uniqueError(err); !errors.Is(err, ErrConflict) {
+				return nil, err
+			}
+			errs[i], failed = err, true
+			continue
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		users[i].ID = int(id)
+		users[i].Version = 1
+	}
+
+	if atomic && failed {
+		for i := range users {
+			users[i].ID, users[i].Version = 0, 0
+		}
+		return errs, nil
+	}
+	return errs, tx.Commit()
+}
+
+func (r *SQLiteUserRepository) Update(ctx context.Context, user *models.User) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE users SET name = ?, email = ?, role = ?, password_hash = ?, version = version + 1
+		WHERE id = ? AND version = ? AND deleted_at IS NULL`,
+		user.Name, user.Email, user.Role, user.PasswordHash, user.ID, user.Version)
+	if err != nil {
+		return uniqueError(err)
+	}
+	if err := updatedRow(ctx, r.db, res, "users", user.ID); err != nil {
+		return err
+	}
+	user.Version++
+	return nil
+}
+
+func (r *SQLiteUserRepository) Delete(ctx context.Context, id, version int) error {
+	return softDelete(ctx, r.db, "users", id, version)
+}
+
+func (r *SQLiteUserRepository) Restore(ctx context.Context, id int) error {
+	return restore(ctx, r.db, "users", id)
+}
+
+// scanUser reads one row of userColumns into a models.User
+func scanUser(row scanner) (models.User, error) {
+	var (
+		u         models.User
+		deletedAt sql.NullString
+	)
+	if err := row.Scan(&u.ID, &u.Name, &u.Email, &u.Role, &u.PasswordHash, &u.Version, &deletedAt); err != nil {
+		return models.User{}, err
+	}
+	var err error
+	u.DeletedAt, err = parseNullTime(deletedAt)
+	return u, err
+}
+
+// SQLiteBlogRepository stores blogs in the blogs table.
+// Tags are kept as a JSON array and timestamps as RFC 3339 text.
+// Deleted blogs keep their row, with deleted_at set.
+type SQLiteBlogRepository struct {
+	db *sql.DB
+}
+
+const blogColumns = `id, title, body, author_id, tags, created_at, updated_at, version, deleted_at`
+
+func (r *SQLiteBlogRepository) List(ctx context.Context) ([]models.Blog, error) {
+	return r.query(ctx, `SELECT `+blogColumns+` FROM blogs WHERE deleted_at IS NULL ORDER BY id`)
+}
+
+func (r *SQLiteBlogRepository) ListByAuthor(ctx context.Context, authorID int) ([]models.Blog, error) {
+	return r.query(ctx, `SELECT `+blogColumns+` FROM blogs WHERE author_id = ? AND deleted_at IS NULL ORDER BY id`, authorID)
+}
+
+func (r *SQLiteBlogRepository) Get(ctx context.Context, id int) (models.Blog, error) {
+	return r.getWhere(ctx, `id = ? AND deleted_at IS NULL`, id)
+}
+
+func (r *SQLiteBlogRepository) GetDeleted(ctx context.Context, id int) (models.Blog, error) {
+	return r.getWhere(ctx, `id = ? AND deleted_at IS NOT NULL`, id)
+}
+
+// getWhere returns the single blog matching the WHERE clause
+func (r *SQLiteBlogRepository) getWhere(ctx context.Context, where string, args ...any) (models.Blog, error) {
+	blog, err := scanBlog(r.db.QueryRowContext(ctx, `SELECT `+blogColumns+` FROM blogs WHERE `+where, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Blog{}, ErrNotFound
+	}
+	return blog, err
+}
+
+func (r *SQLiteBlogRepository) Create(ctx context.Context, blog *models.Blog) error {
+	tags, err := json.Marshal(cloneBlog(*blog).Tags)
+	if err != nil {
+		return err
+	}
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO blogs (title, body, author_id, tags, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		blog.Title, blog.Body, blog.AuthorID, string(tags),
+		blog.CreatedAt.UTC().Format(time.RFC3339Nano), blog.UpdatedAt.UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	blog.ID = int(id)
+	blog.Version = 1
+	return nil
+}
+
+func (r *SQLiteBlogRepository) Update(ctx context.Context, blog *models.Blog) error {
+	tags, err := json.Marshal(cloneBlog(*blog).Tags)
+	if err != nil {
+		return err
+	}
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE blogs SET title = ?, body = ?, author_id = ?, tags = ?, created_at = ?, updated_at = ?, version = version + 1
+		WHERE id = ? AND version = ? AND deleted_at IS NULL`,
+		blog.Title, blog.Body, blog.AuthorID, string(tags),
+		blog.CreatedAt.UTC().Format(time.RFC3339Nano), blog.UpdatedAt.UTC().Format(time.RFC3339Nano),
+		blog.ID, blog.Version)
+	if err != nil {
+		return err
+	}
+	if err := updatedRow(ctx, r.db, res, "blogs", blog.ID); err != nil {
+		return err
+	}
+	blog.Version++
+	return nil
+}
+
+func (r *SQLiteBlogRepository) Delete(ctx context.Context, id, version int) error {
+	return softDelete(ctx, r.db, "blogs", id, version)
+}
+
+func (r *SQLiteBlogRepository) Restore(ctx context.Context, id int) error {
+	return restore(ctx, r.db, "blogs", id)
+}
+
+// query runs a SELECT of blogColumns and scans every row
+func (r *SQLiteBlogRepository) query(ctx context.Context, query string, args ...any) ([]models.Blog, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blogs := []models.Blog{}
+	for rows.Next() {
+		blog, err := scanBlog(rows)
+		if err != nil {
+			return nil, err
+		}
+		blogs = append(blogs, blog)
+	}
+	return blogs, rows.Err()
+}
+
+// scanner is the Scan method shared by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanBlog reads one row of blogColumns into a models.Blog
+func scanBlog(row scanner) (models.Blog, error) {
+	var (
+		blog                 models.Blog
+		tags                 string
+		createdAt, updatedAt string
+		deletedAt            sql.NullString
+	)
+	if err := row.Scan(&blog.ID, &blog.Title, &blog.Body, &blog.AuthorID, &tags, &createdAt, &updatedAt, &blog.Version, &deletedAt); err != nil {
+		return models.Blog{}, err
+	}
+	if err := json.Unmarshal([]byte(tags), &blog.Tags); err != nil {
+		return models.Blog{}, fmt.Errorf("blog %d tags: %w", blog.ID, err)
+	}
+	var err error
+	if blog.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return models.Blog{}, err
+	}
+	if blog.UpdatedAt, err = time.Parse(time.RFC3339Nano, updatedAt); err != nil {
+		return models.Blog{}, err
+	}
+	if blog.DeletedAt, err = parseNullTime(deletedAt); err != nil {
+		return models.Blog{}, err
+	}
+	return blog, nil
+}
+
+// softDelete marks the live row with the given ID and version deleted
+func softDelete(ctx context.Context, db *sql.DB, table string, id, version int) error {
+	res, err := db.ExecContext(ctx,
+		`UPDATE `+table+` SET deleted_at = ?, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL`,
+		time.Now().UTC().Format(time.RFC3339Nano), id, version)
+	if err != nil {
+		return err
+	}
+	return updatedRow(ctx, db, res, table, id)
+}
+
+// restore brings back the deleted row with the given ID
+func restore(ctx context.Context, db *sql.DB, table string, id int) error {
+	res, err := db.ExecContext(ctx,
+		`UPDATE `+table+` SET deleted_at = NULL, version = version + 1 WHERE id = ? AND deleted_at IS NOT NULL`, id)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res)
+}
+
+// parseNullTime reads an optional RFC 3339 column
+func parseNullTime(s sql.NullString) (*time.Time, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// formatNullTime writes an optional RFC 3339 column
+func formatNullTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// uniqueError turns a UNIQUE constraint failure into ErrConflict
+func uniqueError(err error) error {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+		return ErrConflict
+	}
+	return err
+}
+
+// expectOneRow turns "no row changed" into ErrNotFound
+func expectOneRow(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// updatedRow checks the result of an "UPDATE ... WHERE id = ? AND version = ?".
+// When no row changed it tells a missing record (ErrNotFound) from a
+// record with another version (ErrVersionMismatch).
+func updatedRow(ctx context.Context, db *sql.DB, res sql.Result, table string, id int) error {
+	n, err := res.RowsAffected()
+	if err != nil || n > 0 {
+		return err
+	}
+	var exists int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+table+` WHERE id = ? AND deleted_at IS NULL`, id).Scan(&exists); err != nil {
+		return err
+	}
+	if exists == 0 {
+		return ErrNotFound
+	}
+	return ErrVersionMismatch
+}
+
+// SQLiteCommentRepository stores comments in the comments table
+type SQLiteCommentRepository struct {
+	db *sql.DB
+}
+
+func (r *SQLiteCommentRepository) ListByBlog(ctx context.Context, blogID int) ([]models.Comment, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, blog_id, author_id, body, created_at FROM comments WHERE blog_id = ? ORDER BY id`, blogID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []models.Comment{}
+	for rows.Next() {
+		var (
+			c  models.Comment
+			at string
+		)
+		if err := rows.Scan(&c.ID, &c.BlogID, &c.AuthorID, &c.Body, &at); err != nil {
+			return nil, err
+		}
+		if c.CreatedAt, err = time.Parse(time.RFC3339Nano, at); err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
+
+func (r *SQLiteCommentRepository) Create(ctx context.Context, comment *models.Comment) error {
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO comments (blog_id, author_id, body, created_at) VALUES (?, ?, ?, ?)`,
+		comment.BlogID, comment.AuthorID, comment.Body, comment.CreatedAt.UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	comment.ID = int(id)
+	return nil
+}
+
+// SQLiteAuditRepository stores the audit trail in the audit_log table
+type SQLiteAuditRepository struct {
+	db *sql.DB
+}
+
+func (r *SQLiteAuditRepository) Append(ctx context.Context, record *models.AuditRecord) error {
+	changes, err := json.Marshal(record.Changes)
+	if err != nil {
+		return err
+	}
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO audit_log (time, actor_id, action, resource, resource_id, changes) VALUES (?, ?, ?, ?, ?, ?)`,
+		record.Time.UTC().Format(time.RFC3339Nano), record.ActorID, record.Action, record.Resource, record.ResourceID, string(changes))
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	record.ID = int(id)
+	return nil
+}
+
+func (r *SQLiteAuditRepository) List(ctx context.Context) ([]models.AuditRecord, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, time, actor_id, action, resource, resource_id, changes FROM audit_log ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []models.AuditRecord{}
+	for rows.Next() {
+		var (
+			record      models.AuditRecord
+			at, changes string
+		)
+		if err := rows.Scan(&record.ID, &at, &record.ActorID, &record.Action, &record.Resource, &record.ResourceID, &changes); err != nil {
+			return nil, err
+		}
+		if record.Time, err = time.Parse(time.RFC3339Nano, at); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(changes), &record.Changes); err != nil {
+			return nil, fmt.Errorf("audit record %d changes: %w", record.ID, err)
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// SQLiteWebhookRepository stores webhooks in the webhooks table, with
+// their events as a JSON array
+type SQLiteWebhookRepository struct {
+	db *sql.DB
+}
+
+const webhookColumns = `id, url, events, secret, active, created_at`
+
+func (r *SQLiteWebhookRepository) List(ctx context.Context) ([]models.Webhook, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+webhookColumns+` FROM webhooks ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks := []models.Webhook{}
+	for rows.Next() {
+		hook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, rows.Err()
+}
+
+func (r *SQLiteWebhookRepository) Get(ctx context.Context, id int) (models.Webhook, error) {
+	hook, err := scanWebhook(r.db.QueryRowContext(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Webhook{}, ErrNotFound
+	}
+	return hook, err
+}
+
+func (r *SQLiteWebhookRepository) Create(ctx context.Context, hook *models.Webhook) error {
+	events, err := json.Marshal(hook.Events)
+	if err != nil {
+		return err
+	}
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO webhooks (url, events, secret, active, created_at) VALUES (?, ?, ?, ?, ?)`,
+		hook.URL, string(events), hook.Secret, hook.Active, hook.CreatedAt.UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	hook.ID = int(id)
+	return nil
+}
+
+func (r *SQLiteWebhookRepository) Update(ctx context.Context, hook *models.Webhook) error {
+	events, err := json.Marshal(hook.Events)
+	if err != nil {
+		return err
+	}
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE webhooks SET url = ?, events = ?, secret = ?, active = ? WHERE id = ?`,
+		hook.URL, string(events), hook.Secret, hook.Active, hook.ID)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res)
+}
+
+func (r *SQLiteWebhookRepository) Delete(ctx context.Context, id int) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM webhooks WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res)
+}
+
+// scanWebhook reads one row of webhookColumns into a models.Webhook
+func scanWebhook(row scanner) (models.Webhook, error) {
+	var (
+		hook              models.Webhook
+		events, createdAt string
+	)
+	if err := row.Scan(&hook.ID, &hook.URL, &events, &hook.Secret, &hook.Active, &createdAt); err != nil {
+		return models.Webhook{}, err
+	}
+	if err := json.Unmarshal([]byte(events), &hook.Events); err != nil {
+		return models.Webhook{}, fmt.Errorf("webhook %d events: %w", hook.ID, err)
+	}
+	var err error
+	if hook.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return models.Webhook{}, err
+	}
+	return hook, nil
+}
+
+// SQLiteWebhookDeliveryRepository stores the delivery log in the
+// webhook_deliveries table
+type SQLiteWebhookDeliveryRepository struct {
+	db *sql.DB
+}
+
+const deliveryColumns = `id, webhook_id, event_id, event, payload, status, attempts, response_status, error,
+	created_at, last_attempt_at, next_attempt_at`
+
+func (r *SQLiteWebhookDeliveryRepository) Get(ctx context.Context, id int) (models.WebhookDelivery, error) {
+	d, err := scanDelivery(r.db.QueryRowContext(ctx, `SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.WebhookDelivery{}, ErrNotFound
+	}
+	return d, err
+}
+
+func (r *SQLiteWebhookDeliveryRepository) ListByWebhook(ctx context.Context, webhookID int) ([]models.WebhookDelivery, error) {
+	return r.query(ctx, `SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE webhook_id = ? ORDER BY id`, webhookID)
+}
+
+func (r *SQLiteWebhookDeliveryRepository) ListByStatus(ctx context.Context, status string) ([]models.WebhookDelivery, error) {
+	return r.query(ctx, `SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE status = ? ORDER BY id`, status)
+}
+
+func (r *SQLiteWebhookDeliveryRepository) Create(ctx context.Context, d *models.WebhookDelivery) error {
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload, status, attempts, response_status, error,
+			created_at, last_attempt_at, next_attempt_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		d.WebhookID, d.EventID, d.Event, string(d.Payload), d.Status, d.Attempts, d.ResponseStatus, d.Error,
+		d.CreatedAt.UTC().Format(time.RFC3339Nano), formatNullTime(d.LastAttemptAt), formatNullTime(d.NextAttemptAt))
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	d.ID = int(id)
+	return nil
+}
+
+func (r *SQLiteWebhookDeliveryRepository) Update(ctx context.Context, d *models.WebhookDelivery) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE webhook_deliveries SET status = ?, attempts = ?, response_status = ?, error = ?,
+			last_attempt_at = ?, next_attempt_at = ?
+		WHERE id = ?`,
+		d.Status, d.Attempts, d.ResponseStatus, d.Error,
+		formatNullTime(d.LastAttemptAt), formatNullTime(d.NextAttemptAt), d.ID)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(res)
+}
+
+// query runs a SELECT of deliveryColumns and scans every row
+func (r *SQLiteWebhookDeliveryRepository) query(ctx context.Context, query string, args ...any) ([]models.WebhookDelivery, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// scanDelivery reads one row of deliveryColumns into a models.WebhookDelivery
+func scanDelivery(row scanner) (models.WebhookDelivery, error) {
+	var (
+		d                        models.WebhookDelivery
+		payload, createdAt       string
+		lastAttempt, nextAttempt sql.NullString
+	)
+	err := row.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.Event, &payload, &d.Status, &d.Attempts, &d.ResponseStatus, &d.Error,
+		&createdAt, &lastAttempt, &nextAttempt)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	d.Payload = json.RawMessage(payload)
+	if d.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	if d.LastAttemptAt, err = parseNullTime(lastAttempt); err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	if d.NextAttemptAt, err = parseNullTime(nextAttempt); err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	return d, nil
+}
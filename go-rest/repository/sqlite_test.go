@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	"github.com/manish-npx/go-lang/go-rest/models"
+)
+
+// appliedMigrations returns how many rows schema_migrations holds in store
+func appliedMigrations(t *testing.T, store *Store) int {
+	t.Helper()
+	var n int
+	db := store.Users.(*SQLiteUserRepository).db
+	if err := db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestReopeningTheSameFile(t *testing.T) {
+	ctx := context.Background()
+	files, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("migrations %v, %v", files, err)
+	}
+	dsn := filepath.Join(t.TempDir(), "test.db")
+
+	first, err := OpenSQLiteStore(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Name: "Carol", Email: "carol@example.com", Role: models.RoleReader}
+	if err := first.Users.Create(ctx, &user); err != nil {
+		t.Fatal(err)
+	}
+
+	// Once while the file is still open elsewhere, once after it was closed
+	second, err := OpenSQLiteStore(dsn)
+	if err != nil {
+		t.Fatalf("open while open: %v", err)
+	}
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Close(); err != nil {
+		t.Fatal(err)
+	}
+	third, err := OpenSQLiteStore(dsn)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer third.Close()
+
+	if n := appliedMigrations(t, third); n != len(files) {
+		t.Fatalf("%d migrations recorded, want %d", n, len(files))
+	}
+	if got, err := third.Users.Get(ctx, user.ID); err != nil || got.Email != user.Email {
+		t.Fatalf("user after reopening: %+v, %v", got, err)
+	}
+}
+
+func TestPingUsesTheStoreDatabase(t *testing.T) {
+	for _, dsn := range []string{":memory:", filepath.Join(t.TempDir(), "test.db")} {
+		store, err := OpenSQLiteStore(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Ping(context.Background()); err != nil {
+			t.Errorf("%s: ping: %v", dsn, err)
+		}
+		store.Close()
+		if err := store.Ping(context.Background()); err == nil {
+			t.Errorf("%s: ping after close succeeded", dsn)
+		}
+	}
+}
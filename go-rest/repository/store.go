@@ -0,0 +1,51 @@
+package repository
+
+import "context"
+
+// Store bundles the repositories of one storage backend,
+// so main can open, hand out and close them together.
+type Store struct {
+	Users UserRepository
+	Blogs BlogRepository
+	// BlogSearch searches the blogs of Blogs, which keeps it up to date
+	BlogSearch BlogSearcher
+	Comments   CommentRepository
+	Audit      AuditRepository
+	Webhooks   WebhookRepository
+	// WebhookDeliveries logs what was sent to Webhooks
+	WebhookDeliveries WebhookDeliveryRepository
+
+	close func() error
+	ping  func(ctx context.Context) error
+}
+
+// NewMemoryStore returns an in-memory Store pre-filled with the seed data
+func NewMemoryStore() *Store {
+	// Listing in-memory blogs cannot fail
+	blogs, _ := NewIndexedBlogRepository(context.Background(), NewMemoryBlogRepository(SeedBlogs()...))
+	return &Store{
+		Users:             NewMemoryUserRepository(SeedUsers()...),
+		Blogs:             blogs,
+		BlogSearch:        blogs,
+		Comments:          NewMemoryCommentRepository(),
+		Audit:             NewMemoryAuditRepository(),
+		Webhooks:          NewMemoryWebhookRepository(),
+		WebhookDeliveries: NewMemoryWebhookDeliveryRepository(),
+	}
+}
+
+// Ping checks that the backend can serve requests
+func (s *Store) Ping(ctx context.Context) error {
+	if s.ping == nil {
+		return nil
+	}
+	return s.ping(ctx)
+}
+
+// Close releases the resources held by the backend, if any
+func (s *Store) Close() error {
+	if s.close == nil {
+		return nil
+	}
+	return s.close()
+}
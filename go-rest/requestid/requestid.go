@@ -0,0 +1,21 @@
+// Package requestid carries the ID of the current request through its
+// context, so logs and error bodies can refer to the same request.
+package requestid
+
+import "context"
+
+// Header is the HTTP header the ID is read from and echoed back in
+const Header = "X-Request-ID"
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx that carries id
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID stored in ctx, or "" if there is none
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
@@ -0,0 +1,106 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/manish-npx/go-lang/go-rest/auth"
+)
+
+// post sends a JSON body through h without signing in
+func post(h http.Handler, path, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", path, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestLoginFailures(t *testing.T) {
+	router, store, tokens := newTestAPI(t)
+	h := auth.Authenticate(tokens, store.Users)(router)
+	ctx := context.Background()
+
+	for _, email := range []string{"carol@example.com", "dan@example.com"} {
+		if w := post(h, "/auth/register", `{"name": "Someone", "email": "`+email+`", "password": "correct horse"}`); w.Code != http.StatusCreated {
+			t.Fatalf("register %s: status %d: %s", email, w.Code, w.Body)
+		}
+	}
+	dan, _ := store.Users.GetByEmail(ctx, "dan@example.com")
+	if err := store.Users.Delete(ctx, dan.ID, dan.Version); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"right password", `{"email": "carol@example.com", "password": "correct horse"}`, http.StatusOK},
+		{"email in another case", `{"email": "CAROL@example.com", "password": "correct horse"}`, http.StatusOK},
+		{"wrong password", `{"email": "carol@example.com", "password": "correct horsE"}`, http.StatusUnauthorized},
+		{"no password", `{"email": "carol@example.com"}`, http.StatusUnauthorized},
+		{"unknown email", `{"email": "nobody@example.com", "password": "correct horse"}`, http.StatusUnauthorized},
+		{"user without a password", `{"email": "alice@example.com", "password": ""}`, http.StatusUnauthorized},
+		{"deleted user", `{"email": "dan@example.com", "password": "correct horse"}`, http.StatusUnauthorized},
+		{"malformed body", `{"email": `, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := post(h, "/auth/login", tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			// Every failure reads the same, so accounts cannot be found out
+			if tt.status == http.StatusUnauthorized && !strings.Contains(w.Body.String(), "Invalid email or password") {
+				t.Fatalf("body %s", w.Body)
+			}
+		})
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	router, store, tokens := newTestAPI(t)
+	h := auth.Authenticate(tokens, store.Users)(router)
+	ctx := context.Background()
+
+	pair, err := tokens.Issue(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := post(h, "/auth/refresh", `{"refresh_token": "`+pair.RefreshToken+`"}`)
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("refresh: status %d, Cache-Control %q: %s", w.Code, w.Header().Get("Cache-Control"), w.Body)
+	}
+	var renewed auth.TokenPair
+	json.Unmarshal(w.Body.Bytes(), &renewed)
+	if id, err := tokens.Verify(renewed.AccessToken, auth.TypeAccess); err != nil || id != 2 {
+		t.Fatalf("renewed access token: %d, %v", id, err)
+	}
+
+	// An access token is not a refresh token
+	if w := post(h, "/auth/refresh", `{"refresh_token": "`+pair.AccessToken+`"}`); w.Code != http.StatusUnauthorized {
+		t.Fatalf("refresh with an access token: status %d", w.Code)
+	}
+	// Nor is a refresh token an access token
+	r := httptest.NewRequest("GET", "/users/2", nil)
+	r.Header.Set("Authorization", "Bearer "+pair.RefreshToken)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("refresh token used as access token: status %d", w.Code)
+	}
+
+	// The user may have been deleted since
+	bob, _ := store.Users.Get(ctx, 2)
+	if err := store.Users.Delete(ctx, bob.ID, bob.Version); err != nil {
+		t.Fatal(err)
+	}
+	if w := post(h, "/auth/refresh", `{"refresh_token": "`+pair.RefreshToken+`"}`); w.Code != http.StatusUnauthorized {
+		t.Fatalf("refresh of a deleted user: status %d", w.Code)
+	}
+}
@@ -0,0 +1,65 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/manish-npx/go-lang/go-rest/auth"
+	"github.com/manish-npx/go-lang/go-rest/models"
+)
+
+func TestPatchBlogAuditsWhatWasStored(t *testing.T) {
+	router, store, tokens := newTestAPI(t)
+	h := auth.Authenticate(tokens, store.Users)(router)
+
+	tests := []struct {
+		name   string
+		before string
+		patch  string
+		// want is the change of tags in the audit entry of the patch,
+		// nil when tags must not be in it
+		want *models.Change
+	}{
+		{"shorter", `["a", "b"]`, `{"tags": ["c"]}`, &models.Change{Before: []any{"a", "b"}, After: []any{"c"}}},
+		{"longer", `["a"]`, `{"tags": ["b", "c"]}`, &models.Change{Before: []any{"a"}, After: []any{"b", "c"}}},
+		{"emptied", `["a", "b"]`, `{"tags": []}`, &models.Change{Before: []any{"a", "b"}, After: []any{}}},
+		{"left out", `["a", "b"]`, `{"title": "Renamed"}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			w := asUser(t, h, tokens, 1, "PUT", "/blogs/1", `{"title": "Title", "body": "Body", "author_id": 1, "tags": `+tt.before+`}`)
+			if w.Code != http.StatusOK {
+				t.Fatalf("PUT: status %d: %s", w.Code, w.Body)
+			}
+			w = asUser(t, h, tokens, 1, "PATCH", "/blogs/1", tt.patch)
+			if w.Code != http.StatusOK {
+				t.Fatalf("PATCH: status %d: %s", w.Code, w.Body)
+			}
+
+			records, err := store.Audit.List(ctx)
+			if err != nil || len(records) == 0 {
+				t.Fatalf("audit %v, %v", records, err)
+			}
+			last := records[len(records)-1]
+			if last.Action != models.AuditUpdate || last.ResourceID != 1 {
+				t.Fatalf("last audit entry %+v", last)
+			}
+			// Compare as JSON, the way the trail is read
+			var changes map[string]models.Change
+			raw, _ := json.Marshal(last.Changes)
+			json.Unmarshal(raw, &changes)
+
+			got, ok := changes["tags"]
+			switch {
+			case tt.want == nil && ok:
+				t.Fatalf("tags were not sent but the audit shows %+v", got)
+			case tt.want != nil && !reflect.DeepEqual(got, *tt.want):
+				t.Fatalf("audit shows tags %+v, want %+v", got, *tt.want)
+			}
+		})
+	}
+}
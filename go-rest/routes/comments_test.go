@@ -0,0 +1,107 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/manish-npx/go-lang/go-rest/auth"
+	"github.com/manish-npx/go-lang/go-rest/models"
+)
+
+// liveComments connects to the live comments of a blog and returns the
+// channel of what it pushes, once the server reads the connection (it
+// answers pings from there, after the client joined the blog's room)
+func liveComments(t *testing.T, srv *httptest.Server, blogPath string) <-chan []byte {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + blogPath + "/comments/live"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	pong := make(chan struct{})
+	conn.SetPongHandler(func(string) error {
+		close(pong)
+		return nil
+	})
+	messages := make(chan []byte, 8)
+	go func() {
+		defer close(messages)
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			messages <- message
+		}
+	}()
+	conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Second))
+	select {
+	case <-pong:
+	case <-time.After(time.Second):
+		t.Fatal("no pong within a second")
+	}
+	return messages
+}
+
+func TestNewCommentsArePushedToEveryReader(t *testing.T) {
+	router, store, tokens := newTestAPI(t)
+	srv := httptest.NewServer(auth.Authenticate(tokens, store.Users)(router))
+	defer srv.Close()
+
+	readers := []<-chan []byte{
+		liveComments(t, srv, "/blogs/1"),
+		liveComments(t, srv, "/blogs/1"),
+		liveComments(t, srv, "/blogs/1"),
+	}
+	otherBlog := liveComments(t, srv, "/blogs/2")
+
+	w := asUser(t, srv.Config.Handler, tokens, 2, "POST", "/blogs/1/comments", `{"body": "First!"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST: status %d: %s", w.Code, w.Body)
+	}
+	var created models.Comment
+	json.Unmarshal(w.Body.Bytes(), &created)
+
+	for i, messages := range readers {
+		select {
+		case message := <-messages:
+			var pushed models.Comment
+			if err := json.Unmarshal(message, &pushed); err != nil || pushed != created {
+				t.Errorf("reader %d got %s, want %+v", i+1, message, created)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("reader %d got nothing", i+1)
+		}
+	}
+
+	// A comment on blog 2 is the next and only message its reader gets
+	if w := asUser(t, srv.Config.Handler, tokens, 2, "POST", "/blogs/2/comments", `{"body": "Second"}`); w.Code != http.StatusCreated {
+		t.Fatalf("POST: status %d: %s", w.Code, w.Body)
+	}
+	select {
+	case message := <-otherBlog:
+		if !strings.Contains(string(message), `"body":"Second"`) {
+			t.Fatalf("reader of blog 2 got %s", message)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("reader of blog 2 got nothing")
+	}
+}
+
+func TestLiveCommentsOfAMissingBlog(t *testing.T) {
+	router, store, tokens := newTestAPI(t)
+	srv := httptest.NewServer(auth.Authenticate(tokens, store.Users)(router))
+	defer srv.Close()
+
+	_, res, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/blogs/99/comments/live", nil)
+	if err == nil || res == nil || res.StatusCode != http.StatusNotFound {
+		t.Fatalf("dial of a missing blog: %v, %+v", err, res)
+	}
+}
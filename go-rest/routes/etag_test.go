@@ -0,0 +1,53 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/manish-npx/go-lang/go-rest/auth"
+)
+
+func TestEachFormatHasItsOwnETag(t *testing.T) {
+	router, store, tokens := newTestAPI(t)
+	h := auth.Authenticate(tokens, store.Users)(router)
+
+	for _, path := range []string{"/blogs/1", "/users/1"} {
+		t.Run(path, func(t *testing.T) {
+			etags := map[string]string{}
+			for _, format := range []string{"json", "xml", "csv", "msgpack"} {
+				w := asUser(t, h, tokens, 1, "GET", path+"?format="+format, "")
+				if w.Code != http.StatusOK {
+					t.Fatalf("%s: status %d: %s", format, w.Code, w.Body)
+				}
+				etag := w.Header().Get("ETag")
+				for other, seen := range etags {
+					if seen == etag {
+						t.Fatalf("%s and %s share the ETag %s", format, other, etag)
+					}
+				}
+				etags[format] = etag
+			}
+
+			// Having the JSON body does not make the XML one fresh
+			for format, etag := range etags {
+				for _, asked := range []string{"json", "xml"} {
+					pair, _ := tokens.Issue(1)
+					r := httptest.NewRequest("GET", path+"?format="+asked, nil)
+					r.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+					r.Header.Set("If-None-Match", etag)
+					w := httptest.NewRecorder()
+					h.ServeHTTP(w, r)
+
+					want := http.StatusOK
+					if format == asked {
+						want = http.StatusNotModified
+					}
+					if w.Code != want {
+						t.Errorf("GET ?format=%s with the %s ETag: status %d, want %d", asked, format, w.Code, want)
+					}
+				}
+			}
+		})
+	}
+}
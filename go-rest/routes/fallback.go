@@ -0,0 +1,58 @@
+package routes
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/manish-npx/go-lang/go-rest/apierror"
+)
+
+// Router wraps the mux so that its built-in plain-text 404 and 405
+// answers are replaced by the JSON error envelope.
+type Router struct {
+	mux *http.ServeMux
+	// patterns are the patterns registered on mux, in table order
+	patterns []string
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// An empty pattern means no route matched: the mux is about to answer
+	// with its own 404 or 405, so capture its status instead of its body
+	if _, pattern := rt.mux.Handler(r); pattern == "" {
+		rec := &statusRecorder{header: http.Header{}}
+		rt.mux.ServeHTTP(rec, r)
+
+		if rec.status == http.StatusMethodNotAllowed {
+			w.Header().Set("Allow", rec.header.Get("Allow"))
+			apierror.Write(w, r, apierror.New(http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "Method not allowed"))
+			return
+		}
+		apierror.Write(w, r, apierror.NotFound("Route not found"))
+		return
+	}
+
+	rt.mux.ServeHTTP(w, r)
+}
+
+// Pattern returns the route pattern r matches, e.g. "GET /users/{id}",
+// or "" when no route does
+func (rt *Router) Pattern(r *http.Request) string {
+	_, pattern := rt.mux.Handler(r)
+	return pattern
+}
+
+// Patterns returns every pattern the router serves, e.g. "GET /users/{id}"
+func (rt *Router) Patterns() []string {
+	return slices.Clone(rt.patterns)
+}
+
+// statusRecorder is a throwaway ResponseWriter that keeps headers and
+// status and drops the body
+type statusRecorder struct {
+	header http.Header
+	status int
+}
+
+func (s *statusRecorder) Header() http.Header         { return s.header }
+func (s *statusRecorder) Write(b []byte) (int, error) { return len(b), nil }
+func (s *statusRecorder) WriteHeader(status int)      { s.status = status }
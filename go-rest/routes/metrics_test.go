@@ -0,0 +1,43 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/manish-npx/go-lang/go-rest/metrics"
+	"github.com/manish-npx/go-lang/go-rest/middleware"
+)
+
+func TestMetricsAreLabelledByRoutePattern(t *testing.T) {
+	router := newTestRouter(t)
+	m := metrics.New()
+	h := middleware.Chain(router, middleware.Metrics(m, router.Pattern))
+
+	for _, path := range []string{"/users/1", "/users/2", "/users/99", "/blogs/1/comments", "/blogs/2/comments", "/no/such/page", "/users/1/nothing"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/healthz", nil))
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	out := w.Body.String()
+	for _, want := range []string{
+		`http_requests_total{code="200",route="GET /users/{id}"} 2`,
+		`http_requests_total{code="404",route="GET /users/{id}"} 1`,
+		`http_requests_total{code="200",route="GET /blogs/{id}/comments"} 2`,
+		// Paths no route serves share one label, whatever they are
+		`http_requests_total{code="404",route="unmatched"} 2`,
+		`http_requests_total{code="405",route="unmatched"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("no %s in\n%s", want, out)
+		}
+	}
+	for _, raw := range []string{"/users/1", "/users/99", "/no/such/page"} {
+		if strings.Contains(out, `route="GET `+raw+`"`) || strings.Contains(out, `route="`+raw+`"`) {
+			t.Errorf("raw path %s is a label", raw)
+		}
+	}
+}
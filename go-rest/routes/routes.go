@@ -1,41 +1,349 @@
 package routes
 
 import (
+	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 
+	"github.com/manish-npx/go-lang/go-rest/auth"
 	"github.com/manish-npx/go-lang/go-rest/controllers"
+	"github.com/manish-npx/go-lang/go-rest/events"
+	"github.com/manish-npx/go-lang/go-rest/live"
+	"github.com/manish-npx/go-lang/go-rest/metrics"
+	"github.com/manish-npx/go-lang/go-rest/models"
+	"github.com/manish-npx/go-lang/go-rest/openapi"
+	"github.com/manish-npx/go-lang/go-rest/render"
+	"github.com/manish-npx/go-lang/go-rest/repository"
+	"github.com/manish-npx/go-lang/go-rest/webhooks"
 )
 
-func RegisterRoutes() {
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodGet {
+// API title and version shown in the OpenAPI document
+const (
+	API_TITLE   = "go-rest"
+	API_VERSION = "1.0.0"
+)
+
+// route is one entry of the route table: its documentation and its handler.
+// Routes are only registered through the table, so a route cannot be added
+// without being documented; NewRouter refuses to start if a doc is incomplete.
+type route struct {
+	doc     openapi.Route
+	handler http.HandlerFunc
+}
+
+// NewRouter builds a dedicated ServeMux with every route of the API.
+// Patterns use the Go 1.22+ "METHOD /path/{wildcard}" syntax, so the mux
+// answers 405 Method Not Allowed for us when a method is not registered;
+// Router turns those answers into the JSON error envelope.
+//
+// Routes documented with Auth are wrapped in auth.RequireUser and need an
+// access token; the auth.Authenticate middleware must run before the router.
+// Routes without a fixed ContentType are wrapped in render.Negotiated and
+// answer in any format of formats.
+//
+// The OpenAPI document of the table is served at /openapi.json and
+// rendered at /docs; m is served at /metrics. Every change made through
+// the routes is published to broadcaster and streamed at /events; new
+// comments are also pushed to the WebSocket readers of their blog in hubs.
+// Webhooks are managed at /webhooks and sent by dispatcher.
+func NewRouter(store *repository.Store, tokens *auth.Tokens, m *metrics.Metrics, formats *render.Registry, broadcaster *events.Broadcaster, hubs *live.Hubs, dispatcher *webhooks.Dispatcher) *Router {
+	authn := controllers.NewAuthController(store.Users, tokens, store.Audit, broadcaster)
+	users := controllers.NewUserController(store.Users, store.Audit, broadcaster)
+	blogs := controllers.NewBlogController(store.Blogs, store.Users, store.BlogSearch, store.Audit, broadcaster)
+	comments := controllers.NewCommentController(store.Comments, store.Blogs, hubs, store.Audit, broadcaster)
+	audit := controllers.NewAuditController(store.Audit)
+	hooks := controllers.NewWebhookController(store.Webhooks, store.WebhookDeliveries, dispatcher, store.Audit)
+	stream := controllers.NewEventController(broadcaster)
+	health := controllers.NewHealthController(store)
+
+	// The spec handler needs the finished table, so it is filled in below
+	var spec http.HandlerFunc
+
+	table := []route{
+		{openapi.Route{
+			Method: "GET", Path: "/", Summary: "Welcome message", Tags: []string{"meta"},
+			Response: "", ContentType: "text/plain",
+		}, func(w http.ResponseWriter, r *http.Request) {
 			w.Write([]byte("Welcome to goLang"))
-		} else {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}},
+		{openapi.Route{
+			Method: "GET", Path: "/openapi.json", Summary: "This OpenAPI document", Tags: []string{"meta"},
+			Response: map[string]any{}, ContentType: "application/json",
+		}, func(w http.ResponseWriter, r *http.Request) { spec(w, r) }},
+		{openapi.Route{
+			Method: "GET", Path: "/docs", Summary: "API reference page", Tags: []string{"meta"},
+			Response: "", ContentType: "text/html",
+		}, openapi.UIHandler(API_TITLE+" API", "/openapi.json", "/docs/redoc.standalone.js")},
+		{openapi.Route{
+			Method: "GET", Path: "/docs/redoc.standalone.js", Summary: "Redoc script of the API reference page", Tags: []string{"meta"},
+			Response: "", ContentType: "text/javascript",
+		}, openapi.ScriptHandler()},
+		{openapi.Route{
+			Method: "GET", Path: "/healthz", Summary: "Liveness probe", Tags: []string{"meta"},
+			Response: controllers.HealthStatus{},
+		}, health.Healthz},
+		{openapi.Route{
+			Method: "GET", Path: "/readyz", Summary: "Readiness probe, checks the storage backend", Tags: []string{"meta"},
+			Response: controllers.HealthStatus{}, Errors: []int{http.StatusServiceUnavailable},
+		}, health.Readyz},
+		{openapi.Route{
+			Method: "GET", Path: "/metrics", Summary: "Prometheus metrics", Tags: []string{"meta"},
+			Response: "", ContentType: "text/plain",
+		}, m.Handler().ServeHTTP},
+
+		{openapi.Route{
+			Method: "POST", Path: "/auth/register", Summary: "Sign up with a password", Tags: []string{"auth"},
+			Request: controllers.RegisterRequest{}, Response: models.User{}, Status: http.StatusCreated,
+			Errors: []int{http.StatusConflict, http.StatusUnprocessableEntity},
+		}, authn.Register},
+		{openapi.Route{
+			Method: "POST", Path: "/auth/login", Summary: "Trade an email and password for tokens", Tags: []string{"auth"},
+			Request: controllers.LoginRequest{}, Response: auth.TokenPair{},
+			Errors: []int{http.StatusUnauthorized},
+		}, authn.Login},
+		{openapi.Route{
+			Method: "POST", Path: "/auth/refresh", Summary: "Trade a refresh token for new tokens", Tags: []string{"auth"},
+			Request: controllers.RefreshRequest{}, Response: auth.TokenPair{},
+			Errors: []int{http.StatusUnauthorized},
+		}, authn.Refresh},
+
+		{openapi.Route{
+			Method: "GET", Path: "/users", Conditional: true, Summary: "List users", Tags: []string{"users"},
+			Response: controllers.List[models.User]{}, Query: listQuery("id", "name", "email"),
+			Errors: []int{http.StatusBadRequest},
+		}, users.GetUsers},
+		{openapi.Route{
+			Method: "POST", Path: "/users", Summary: "Create a user (admins only)", Tags: []string{"users"}, Auth: true,
+			Request: models.User{}, Response: models.User{}, Status: http.StatusCreated,
+			Errors: []int{http.StatusConflict, http.StatusUnprocessableEntity},
+		}, users.CreateUser},
+		{openapi.Route{
+			Method: "POST", Path: "/users:bulk", Summary: "Create users from an NDJSON or CSV stream, reporting on each row (admins only)", Tags: []string{"users"}, Auth: true,
+			Request: models.User{}, RequestTypes: []string{controllers.APPLICATION_NDJSON, controllers.TEXT_CSV}, Response: controllers.BulkReport{},
+			Query: []openapi.Param{{Name: "atomic", Description: "true to create no user unless every row can be created (default false)"}},
+		}, users.BulkCreateUsers},
+		{openapi.Route{
+			Method: "GET", Path: "/users:export", Summary: "Stream every user as NDJSON or CSV (admins only)", Tags: []string{"users"}, Auth: true,
+			Response: models.User{}, ContentType: controllers.APPLICATION_NDJSON, Formats: []string{controllers.APPLICATION_NDJSON, controllers.TEXT_CSV},
+			Query: []openapi.Param{{Name: "format", Description: "ndjson (default) or csv, overrides Accept"}},
+		}, users.ExportUsers},
+		{openapi.Route{
+			Method: "GET", Path: "/users/{id}", Conditional: true, Summary: "Get a user", Tags: []string{"users"},
+			Response: models.User{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound},
+		}, users.GetUserByID},
+		{openapi.Route{
+			Method: "PUT", Path: "/users/{id}", Conditional: true, Summary: "Replace a user", Tags: []string{"users"}, Auth: true,
+			Request: models.User{}, Response: models.User{},
+			Errors: []int{http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity},
+		}, users.UpdateUser},
+		{openapi.Route{
+			Method: "PATCH", Path: "/users/{id}", Conditional: true, Summary: "Change some fields of a user", Tags: []string{"users"}, Auth: true,
+			Request: models.User{}, Response: models.User{},
+			Errors: []int{http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity},
+		}, users.PatchUser},
+		{openapi.Route{
+			Method: "DELETE", Path: "/users/{id}", Conditional: true, Summary: "Delete a user", Tags: []string{"users"}, Auth: true,
+			Status: http.StatusNoContent, Errors: []int{http.StatusBadRequest, http.StatusNotFound},
+		}, users.DeleteUser},
+		{openapi.Route{
+			Method: "POST", Path: "/users/{id}/restore", Summary: "Bring back a deleted user (admins only)", Tags: []string{"users"}, Auth: true,
+			Response: models.User{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound},
+		}, users.RestoreUser},
+		{openapi.Route{
+			Method: "GET", Path: "/users/{id}/blogs", Conditional: true, Summary: "List the blogs of a user", Tags: []string{"users", "blogs"},
+			Response: controllers.List[models.Blog]{}, Query: listQuery("id", "title", "body", "author_id"),
+			Errors: []int{http.StatusBadRequest, http.StatusNotFound},
+		}, blogs.GetUserBlogs},
+
+		{openapi.Route{
+			Method: "GET", Path: "/blogs", Conditional: true, Summary: "List blogs", Tags: []string{"blogs"},
+			Response: controllers.List[models.Blog]{}, Query: listQuery("id", "title", "body", "author_id"),
+			Errors: []int{http.StatusBadRequest},
+		}, blogs.GetBlogs},
+		{openapi.Route{
+			Method: "GET", Path: "/blogs/search", Summary: "Search blogs, best match first", Tags: []string{"blogs"},
+			Response: controllers.List[controllers.BlogSearchResult]{}, Query: searchQuery(),
+			Errors: []int{http.StatusBadRequest},
+		}, blogs.SearchBlogs},
+		{openapi.Route{
+			Method: "POST", Path: "/blogs", Summary: "Write a blog", Tags: []string{"blogs"}, Auth: true,
+			Request: models.Blog{}, Response: models.Blog{}, Status: http.StatusCreated,
+			Errors: []int{http.StatusUnprocessableEntity},
+		}, blogs.CreateBlog},
+		{openapi.Route{
+			Method: "GET", Path: "/blogs/{id}", Conditional: true, Summary: "Get a blog", Tags: []string{"blogs"},
+			Response: models.Blog{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound},
+		}, blogs.GetBlogByID},
+		{openapi.Route{
+			Method: "PUT", Path: "/blogs/{id}", Conditional: true, Summary: "Replace a blog", Tags: []string{"blogs"}, Auth: true,
+			Request: models.Blog{}, Response: models.Blog{},
+			Errors: []int{http.StatusNotFound, http.StatusUnprocessableEntity},
+		}, blogs.UpdateBlog},
+		{openapi.Route{
+			Method: "PATCH", Path: "/blogs/{id}", Conditional: true, Summary: "Change some fields of a blog", Tags: []string{"blogs"}, Auth: true,
+			Request: models.Blog{}, Response: models.Blog{},
+			Errors: []int{http.StatusNotFound, http.StatusUnprocessableEntity},
+		}, blogs.PatchBlog},
+		{openapi.Route{
+			Method: "DELETE", Path: "/blogs/{id}", Conditional: true, Summary: "Delete a blog", Tags: []string{"blogs"}, Auth: true,
+			Status: http.StatusNoContent, Errors: []int{http.StatusBadRequest, http.StatusNotFound},
+		}, blogs.DeleteBlog},
+		{openapi.Route{
+			Method: "POST", Path: "/blogs/{id}/restore", Summary: "Bring back a deleted blog", Tags: []string{"blogs"}, Auth: true,
+			Response: models.Blog{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound},
+		}, blogs.RestoreBlog},
+		{openapi.Route{
+			Method: "GET", Path: "/blogs/{id}/comments", Conditional: true, Summary: "List the comments of a blog", Tags: []string{"comments"},
+			Response: controllers.List[models.Comment]{}, Query: listQuery("id", "author_id"),
+			Errors: []int{http.StatusBadRequest, http.StatusNotFound},
+		}, comments.GetComments},
+		{openapi.Route{
+			Method: "POST", Path: "/blogs/{id}/comments", Summary: "Comment on a blog", Tags: []string{"comments"}, Auth: true,
+			Request: models.Comment{}, Response: models.Comment{}, Status: http.StatusCreated,
+			Errors: []int{http.StatusNotFound, http.StatusUnprocessableEntity},
+		}, comments.CreateComment},
+		{openapi.Route{
+			Method: "GET", Path: "/blogs/{id}/comments/live", Summary: "WebSocket pushing each new comment of a blog as a JSON message", Tags: []string{"comments"},
+			Response: models.Comment{}, Status: http.StatusSwitchingProtocols, ContentType: "application/json",
+			Errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound},
+		}, comments.LiveComments},
+
+		{openapi.Route{
+			Method: "GET", Path: "/audit", Summary: "Audit trail of every change (admins only)", Tags: []string{"audit"}, Auth: true,
+			Response: controllers.List[models.AuditRecord]{}, Query: listQuery("id", "actor_id", "action", "resource", "resource_id"),
+			Errors: []int{http.StatusBadRequest},
+		}, audit.GetAudit},
+
+		{openapi.Route{
+			Method: "GET", Path: "/webhooks", Summary: "List webhooks (admins only)", Tags: []string{"webhooks"}, Auth: true,
+			Response: controllers.List[models.Webhook]{}, Query: listQuery("id", "url", "active"),
+			Errors: []int{http.StatusBadRequest},
+		}, hooks.GetWebhooks},
+		{openapi.Route{
+			Method: "POST", Path: "/webhooks", Summary: "Subscribe a URL to events; the answer shows the signing secret (admins only)", Tags: []string{"webhooks"}, Auth: true,
+			Request: controllers.WebhookRequest{}, Response: models.Webhook{}, Status: http.StatusCreated,
+			Errors: []int{http.StatusUnprocessableEntity},
+		}, hooks.CreateWebhook},
+		{openapi.Route{
+			Method: "GET", Path: "/webhooks/{id}", Summary: "Get a webhook (admins only)", Tags: []string{"webhooks"}, Auth: true,
+			Response: models.Webhook{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound},
+		}, hooks.GetWebhookByID},
+		{openapi.Route{
+			Method: "PUT", Path: "/webhooks/{id}", Summary: "Replace a webhook (admins only)", Tags: []string{"webhooks"}, Auth: true,
+			Request: controllers.WebhookRequest{}, Response: models.Webhook{},
+			Errors: []int{http.StatusNotFound, http.StatusUnprocessableEntity},
+		}, hooks.UpdateWebhook},
+		{openapi.Route{
+			Method: "DELETE", Path: "/webhooks/{id}", Summary: "Delete a webhook (admins only)", Tags: []string{"webhooks"}, Auth: true,
+			Status: http.StatusNoContent, Errors: []int{http.StatusBadRequest, http.StatusNotFound},
+		}, hooks.DeleteWebhook},
+		{openapi.Route{
+			Method: "GET", Path: "/webhooks/{id}/deliveries", Summary: "Delivery log of a webhook, newest first (admins only)", Tags: []string{"webhooks"}, Auth: true,
+			Response: controllers.List[models.WebhookDelivery]{}, Query: listQuery("id", "event", "status"),
+			Errors: []int{http.StatusBadRequest, http.StatusNotFound},
+		}, hooks.GetDeliveries},
+		{openapi.Route{
+			Method: "GET", Path: "/webhooks/dead-letters", Summary: "Deliveries that ran out of attempts, newest first (admins only)", Tags: []string{"webhooks"}, Auth: true,
+			Response: controllers.List[models.WebhookDelivery]{}, Query: listQuery("id", "webhook_id", "event"),
+			Errors: []int{http.StatusBadRequest},
+		}, hooks.GetDeadLetters},
+		{openapi.Route{
+			Method: "POST", Path: "/webhooks/deliveries/{id}/retry", Summary: "Send a dead delivery again (admins only)", Tags: []string{"webhooks"}, Auth: true,
+			Response: models.WebhookDelivery{}, Status: http.StatusAccepted,
+			Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict},
+		}, hooks.RetryDelivery},
+
+		{openapi.Route{
+			Method: "GET", Path: "/events", Summary: "Live stream of changes to users, blogs and comments (Server-Sent Events)", Tags: []string{"events"},
+			Response: "", ContentType: "text/event-stream",
+			Query:  []openapi.Param{{Name: "topics", Description: "Comma separated topics, all by default: " + strings.Join(events.Topics, ", ")}},
+			Errors: []int{http.StatusBadRequest},
+		}, stream.Stream},
+	}
+
+	mux := http.NewServeMux()
+	patterns := make([]string, 0, len(table))
+	docs := make([]openapi.Route, 0, len(table))
+	for _, rt := range table {
+		handler := rt.handler
+		if rt.doc.ContentType == "" {
+			handler = render.Negotiated(formats, handler)
+			rt.doc.Formats, rt.doc.Query = formatDocs(formats, rt.doc.Query)
 		}
-	})
-	http.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodGet {
-			controllers.GetUsers(w, r)
-		} else {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		if rt.doc.Auth {
+			handler = auth.RequireUser(handler)
 		}
-	})
+		mux.HandleFunc(pattern(rt.doc), handler)
+		patterns = append(patterns, pattern(rt.doc))
+		docs = append(docs, rt.doc)
+	}
 
-	http.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodGet {
-			controllers.GetUserByID(w, r)
-		} else {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		}
-	})
+	doc, err := openapi.Build(API_TITLE, API_VERSION, docs)
+	if err != nil {
+		panic(fmt.Sprintf("routes: %v", err))
+	}
+	if spec, err = openapi.JSONHandler(doc); err != nil {
+		panic(fmt.Sprintf("routes: %v", err))
+	}
+
+	return &Router{mux: mux, patterns: patterns}
+}
 
-	http.HandleFunc("/blogs", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodGet {
+// pattern turns a documented route into a ServeMux pattern.
+// "/" becomes "/{$}", which matches only "/" itself instead of every path.
+func pattern(doc openapi.Route) string {
+	path := doc.Path
+	if path == "/" {
+		path = "/{$}"
+	}
+	return doc.Method + " " + path
+}
+
+// listQuery documents the query parameters of a list endpoint, see
+// controllers.paginate. filters are the fields that can be filtered on.
+func listQuery(filters ...string) []openapi.Param {
+	params := []openapi.Param{
+		{Name: "limit", Type: "integer", Description: fmt.Sprintf("Page size, 1 to %d (default %d)", controllers.MAX_LIMIT, controllers.DEFAULT_LIMIT)},
+		{Name: "offset", Type: "integer", Description: "Position of the first item; cannot be combined with cursor"},
+		{Name: "cursor", Description: "next_cursor of the previous page"},
+		{Name: "sort", Description: "Comma separated fields, prefix with - for descending, e.g. name,-id"},
+	}
+	for _, f := range filters {
+		params = append(params,
+			openapi.Param{Name: f, Description: "Exact match, case-insensitive"},
+			openapi.Param{Name: f + "[contains]", Description: "Substring match, case-insensitive"},
+		)
+	}
+	return params
+}
 
-			controllers.GetBlogs(w, r)
-		} else {
-			http.Error(w, "Method not Allowed", http.StatusMethodNotAllowed)
+// searchQuery documents the query parameters of GET /blogs/search
+func searchQuery() []openapi.Param {
+	q := openapi.Param{
+		Name:        "q",
+		Description: `Words that must all appear in the title, body or tags. "quoted words" must appear together, word* matches any word starting with it.`,
+	}
+	params := append([]openapi.Param{q}, listQuery("id", "author_id")...)
+	for i := range params {
+		if params[i].Name == "sort" {
+			params[i].Description = "Comma separated fields, prefix with - for descending (default -score)"
 		}
-	})
+	}
+	return params
+}
+
+// formatDocs returns the media types of formats and query with the
+// ?format= parameter added, see render.Registry.Negotiate
+func formatDocs(formats *render.Registry, query []openapi.Param) ([]string, []openapi.Param) {
+	var mediaTypes, names []string
+	for _, f := range formats.Formats() {
+		mediaTypes = append(mediaTypes, f.MediaType)
+		names = append(names, f.Name)
+	}
+	param := openapi.Param{
+		Name:        "format",
+		Description: "Response format, overrides Accept: " + strings.Join(names, ", "),
+	}
+	return mediaTypes, append(slices.Clone(query), param)
 }
@@ -0,0 +1,112 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/manish-npx/go-lang/go-rest/auth"
+	"github.com/manish-npx/go-lang/go-rest/events"
+	"github.com/manish-npx/go-lang/go-rest/live"
+	"github.com/manish-npx/go-lang/go-rest/metrics"
+	"github.com/manish-npx/go-lang/go-rest/render"
+	"github.com/manish-npx/go-lang/go-rest/repository"
+	"github.com/manish-npx/go-lang/go-rest/webhooks"
+)
+
+// newTestRouter builds the router on an in-memory store
+func newTestRouter(t *testing.T) *Router {
+	t.Helper()
+	router, _, _ := newTestAPI(t)
+	return router
+}
+
+// newTestAPI builds the router on an in-memory store, seeded with the
+// admin Alice (ID 1) and the editor Bob (ID 2), and returns it with the
+// store and the tokens it accepts
+func newTestAPI(t *testing.T) (*Router, *repository.Store, *auth.Tokens) {
+	t.Helper()
+	store := repository.NewMemoryStore()
+	tokens := auth.NewTokens([]byte("test-secret"))
+	return NewRouter(store,
+		tokens,
+		metrics.New(),
+		render.Default(),
+		events.NewBroadcaster(events.DEFAULT_LOG_SIZE),
+		live.NewHubs(),
+		webhooks.NewDispatcher(store.Webhooks, store.WebhookDeliveries, webhooks.Options{}),
+	), store, tokens
+}
+
+// servedPaths fetches /openapi.json and returns its paths with their methods
+func servedPaths(t *testing.T, router *Router) map[string]map[string]json.RawMessage {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json: status %d", w.Code)
+	}
+	var doc struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("GET /openapi.json: %v", err)
+	}
+	return doc.Paths
+}
+
+func TestEveryRouteIsDocumented(t *testing.T) {
+	router := newTestRouter(t)
+	paths := servedPaths(t, router)
+
+	patterns := router.Patterns()
+	if len(patterns) == 0 {
+		t.Fatal("the router serves no route")
+	}
+	for _, pattern := range patterns {
+		method, path, _ := strings.Cut(pattern, " ")
+		if path == "/{$}" {
+			path = "/"
+		}
+		if _, ok := paths[path][strings.ToLower(method)]; !ok {
+			t.Errorf("%s is served but missing from /openapi.json", pattern)
+		}
+	}
+}
+
+func TestEveryDocumentedRouteIsServed(t *testing.T) {
+	router := newTestRouter(t)
+
+	for path, operations := range servedPaths(t, router) {
+		// Every wildcard is an ID
+		url := strings.ReplaceAll(path, "{id}", "1")
+		for method := range operations {
+			r := httptest.NewRequest(strings.ToUpper(method), url, nil)
+			if pattern := router.Pattern(r); pattern == "" {
+				t.Errorf("%s %s is documented but not served", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+func TestDocsPageUsesBundledScript(t *testing.T) {
+	router := newTestRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /docs: status %d", w.Code)
+	}
+	if page := w.Body.String(); !strings.Contains(page, `src="/docs/redoc.standalone.js"`) || strings.Contains(page, "https://") {
+		t.Fatalf("GET /docs must only load the bundled script:\n%s", page)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs/redoc.standalone.js", nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/javascript") || w.Body.Len() == 0 {
+		t.Fatalf("GET /docs/redoc.standalone.js: status %d, type %q, %d bytes",
+			w.Code, w.Header().Get("Content-Type"), w.Body.Len())
+	}
+}
@@ -0,0 +1,234 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/manish-npx/go-lang/go-rest/auth"
+	"github.com/manish-npx/go-lang/go-rest/controllers"
+	"github.com/manish-npx/go-lang/go-rest/models"
+)
+
+// bulkRequest sends body as contentType through h, signed in as userID
+func bulkRequest(t *testing.T, h http.Handler, tokens *auth.Tokens, userID int, method, path, contentType, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	pair, err := tokens.Issue(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func decodeReport(t *testing.T, w *httptest.ResponseRecorder) controllers.BulkReport {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var report controllers.BulkReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+// rowStatuses returns "line:status" for every row of report
+func rowStatuses(report controllers.BulkReport) []string {
+	var statuses []string
+	for _, row := range report.Results {
+		statuses = append(statuses, fmt.Sprintf("%d:%s", row.Line, row.Status))
+	}
+	return statuses
+}
+
+func TestBulkImportReportsEveryRow(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{"ndjson", controllers.APPLICATION_NDJSON, strings.Join([]string{
+			`{"name": "Carol", "email": "carol@example.com"}`,
+			`{"name": "Dan", "email": "not an email"}`,
+			`{"name": "Bob", "email": "bob@example.com"}`,
+			`{"name": `,
+			``,
+			`{"name": "Erin", "email": "erin@example.com", "role": "editor"}`,
+		}, "\n")},
+		{"csv", controllers.TEXT_CSV, strings.Join([]string{
+			`name,email,role`,
+			`Carol,carol@example.com,`,
+			`Dan,not an email,`,
+			`Bob,bob@example.com,`,
+			`"Broken`,
+		}, "\n")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, store, tokens := newTestAPI(t)
+			h := auth.Authenticate(tokens, store.Users)(router)
+
+			report := decodeReport(t, bulkRequest(t, h, tokens, 1, "POST", "/users:bulk", tt.contentType, tt.body))
+			want := []string{"1:created", "2:failed", "3:failed", "4:failed", "6:created"}
+			if tt.name == "csv" {
+				want = []string{"2:created", "3:failed", "4:failed", "5:failed"}
+			}
+			if got := rowStatuses(report); !slices.Equal(got, want) {
+				t.Fatalf("rows %v, want %v", got, want)
+			}
+			created := strings.Count(strings.Join(want, ","), "created")
+			if report.Atomic || report.Total != len(want) || report.Created != created || report.Failed != len(want)-created {
+				t.Fatalf("report %+v", report)
+			}
+
+			// Why each row failed
+			invalid, duplicate := report.Results[1], report.Results[2]
+			if len(invalid.Errors) != 1 || invalid.Errors[0].Field != "email" {
+				t.Errorf("invalid email row: %+v", invalid)
+			}
+			if len(duplicate.Errors) != 1 || duplicate.Errors[0].Message != "is already in use" {
+				t.Errorf("duplicate email row: %+v", duplicate)
+			}
+			if malformed := report.Results[3]; len(malformed.Errors) != 1 || malformed.Errors[0].Field != "row" {
+				t.Errorf("malformed row: %+v", malformed)
+			}
+
+			// Created rows point to their user
+			carol, err := store.Users.GetByEmail(context.Background(), "carol@example.com")
+			if err != nil || report.Results[0].ID != carol.ID || carol.Role != models.DefaultRole {
+				t.Fatalf("Carol %+v, %v; row %+v", carol, err, report.Results[0])
+			}
+		})
+	}
+}
+
+func TestBulkImportAtomic(t *testing.T) {
+	router, store, tokens := newTestAPI(t)
+	h := auth.Authenticate(tokens, store.Users)(router)
+	ctx := context.Background()
+	before, _ := store.Users.List(ctx)
+
+	body := "name,email\nCarol,carol@example.com\nDan,\nErin,erin@example.com\n"
+	report := decodeReport(t, bulkRequest(t, h, tokens, 1, "POST", "/users:bulk?atomic=true", controllers.TEXT_CSV, body))
+	if got, want := rowStatuses(report), []string{"2:rolled_back", "3:failed", "4:rolled_back"}; !slices.Equal(got, want) {
+		t.Fatalf("rows %v, want %v", got, want)
+	}
+	if !report.Atomic || report.Created != 0 || report.Failed != 1 {
+		t.Fatalf("report %+v", report)
+	}
+	if after, _ := store.Users.List(ctx); len(after) != len(before) {
+		t.Fatalf("%d users after a failed atomic import, want %d", len(after), len(before))
+	}
+
+	// A duplicate found while inserting rolls back the batch too
+	body = "name,email\nCarol,carol@example.com\nBob,BOB@example.com\n"
+	report = decodeReport(t, bulkRequest(t, h, tokens, 1, "POST", "/users:bulk?atomic=true", controllers.TEXT_CSV, body))
+	if got, want := rowStatuses(report), []string{"2:rolled_back", "3:failed"}; !slices.Equal(got, want) {
+		t.Fatalf("rows %v, want %v", got, want)
+	}
+	if _, err := store.Users.GetByEmail(ctx, "carol@example.com"); err == nil {
+		t.Fatal("Carol was created by a rolled back import")
+	}
+
+	body = "name,email\nCarol,carol@example.com\n"
+	report = decodeReport(t, bulkRequest(t, h, tokens, 1, "POST", "/users:bulk?atomic=true", controllers.TEXT_CSV, body))
+	if report.Created != 1 {
+		t.Fatalf("report %+v", report)
+	}
+}
+
+func TestBulkImportRejectsBadRequests(t *testing.T) {
+	router, store, tokens := newTestAPI(t)
+	h := auth.Authenticate(tokens, store.Users)(router)
+
+	tests := []struct {
+		name        string
+		userID      int
+		path        string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"not an admin", 2, "/users:bulk", controllers.TEXT_CSV, "name,email\n", http.StatusForbidden},
+		{"JSON body", 1, "/users:bulk", "application/json", `[{"name": "Carol"}]`, http.StatusUnsupportedMediaType},
+		{"no header row", 1, "/users:bulk", controllers.TEXT_CSV, "", http.StatusBadRequest},
+		{"unknown column", 1, "/users:bulk", controllers.TEXT_CSV, "name,email,password\n", http.StatusBadRequest},
+		{"bad atomic", 1, "/users:bulk?atomic=maybe", controllers.TEXT_CSV, "name,email\n", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := bulkRequest(t, h, tokens, tt.userID, "POST", tt.path, tt.contentType, tt.body)
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+		}
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	router, store, tokens := newTestAPI(t)
+	h := auth.Authenticate(tokens, store.Users)(router)
+	ctx := context.Background()
+
+	// More than a page, with names spreadsheets would run
+	var users []models.User
+	for i := range controllers.EXPORT_PAGE_SIZE + 10 {
+		name := fmt.Sprintf("User %d", i)
+		if i%100 == 0 {
+			name = fmt.Sprintf("=HYPERLINK(\"x\", %d), \"quoted\"", i)
+		}
+		users = append(users, models.User{Name: name, Email: fmt.Sprintf("user%d@example.com", i), Role: models.RoleReader})
+	}
+	if _, err := store.Users.CreateMany(ctx, users, true); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := store.Users.List(ctx)
+
+	w := bulkRequest(t, h, tokens, 1, "GET", "/users:export?format=csv", "", "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != controllers.TEXT_CSV {
+		t.Fatalf("export: status %d, %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	export := w.Body.String()
+	if n := strings.Count(export, "id,name,email,role,version\n"); n != 1 {
+		t.Fatalf("export has %d header rows", n)
+	}
+
+	// Imported into another API, the export gives the same users back.
+	// Its seed users are already there, so their rows fail.
+	router2, store2, tokens2 := newTestAPI(t)
+	h2 := auth.Authenticate(tokens2, store2.Users)(router2)
+	report := decodeReport(t, bulkRequest(t, h2, tokens2, 1, "POST", "/users:bulk", controllers.TEXT_CSV, export))
+	if report.Created != len(want)-2 || report.Failed != 2 ||
+		report.Results[0].Status != controllers.BulkFailed || report.Results[1].Status != controllers.BulkFailed {
+		t.Fatalf("import of the export: %d created, %d failed, want %d created", report.Created, report.Failed, len(want)-2)
+	}
+	for _, u := range want {
+		got, err := store2.Users.GetByEmail(ctx, u.Email)
+		if err != nil || got.Name != u.Name || got.Role != u.Role {
+			t.Fatalf("%s came back as %+v, %v; want %+v", u.Email, got, err, u)
+		}
+	}
+
+	// NDJSON, the default, has one user per line
+	w = bulkRequest(t, h, tokens, 1, "GET", "/users:export", "", "")
+	lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+	if w.Header().Get("Content-Type") != controllers.APPLICATION_NDJSON || len(lines) != len(want) {
+		t.Fatalf("NDJSON export: %s with %d lines, want %d", w.Header().Get("Content-Type"), len(lines), len(want))
+	}
+	for i, line := range lines {
+		var u models.User
+		if err := json.Unmarshal([]byte(line), &u); err != nil || u.ID != want[i].ID || u.Name != want[i].Name {
+			t.Fatalf("line %d: %s, %v", i+1, line, err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/manish-npx/go-lang/go-rest/auth"
+	"github.com/manish-npx/go-lang/go-rest/models"
+)
+
+// asUser sends a JSON request through the router, signed in as userID
+func asUser(t *testing.T, h http.Handler, tokens *auth.Tokens, userID int, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	pair, err := tokens.Issue(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestDeletedAtCannotBeSent(t *testing.T) {
+	router, store, tokens := newTestAPI(t)
+	h := auth.Authenticate(tokens, store.Users)(router)
+	const deletedAt = `"deleted_at": "2020-01-01T00:00:00Z"`
+
+	tests := []struct {
+		name         string
+		method, path string
+		body         string
+		// id is the record the request creates or changes, 0 to read it
+		// from the Location header
+		id        int
+		isDeleted func(ctx context.Context, id int) error
+	}{
+		{
+			name: "create user", method: "POST", path: "/users",
+			body:      `{"name": "Carol", "email": "carol@example.com", ` + deletedAt + `}`,
+			isDeleted: func(ctx context.Context, id int) error { _, err := store.Users.GetDeleted(ctx, id); return err },
+		},
+		{
+			name: "replace user", method: "PUT", path: "/users/2", id: 2,
+			body:      `{"name": "Bob", "email": "bob@example.com", "role": "editor", ` + deletedAt + `}`,
+			isDeleted: func(ctx context.Context, id int) error { _, err := store.Users.GetDeleted(ctx, id); return err },
+		},
+		{
+			name: "patch user", method: "PATCH", path: "/users/2", id: 2,
+			body:      `{` + deletedAt + `}`,
+			isDeleted: func(ctx context.Context, id int) error { _, err := store.Users.GetDeleted(ctx, id); return err },
+		},
+		{
+			name: "create blog", method: "POST", path: "/blogs",
+			body:      `{"title": "Hello", "body": "World", ` + deletedAt + `}`,
+			isDeleted: func(ctx context.Context, id int) error { _, err := store.Blogs.GetDeleted(ctx, id); return err },
+		},
+		{
+			name: "replace blog", method: "PUT", path: "/blogs/1", id: 1,
+			body:      `{"title": "Hello", "body": "World", "author_id": 1, ` + deletedAt + `}`,
+			isDeleted: func(ctx context.Context, id int) error { _, err := store.Blogs.GetDeleted(ctx, id); return err },
+		},
+		{
+			name: "patch blog", method: "PATCH", path: "/blogs/1", id: 1,
+			body:      `{` + deletedAt + `}`,
+			isDeleted: func(ctx context.Context, id int) error { _, err := store.Blogs.GetDeleted(ctx, id); return err },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := asUser(t, h, tokens, 1, tt.method, tt.path, tt.body)
+			if w.Code >= 300 {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			if strings.Contains(w.Body.String(), "deleted_at") {
+				t.Errorf("the answer says the record is deleted: %s", w.Body)
+			}
+
+			id := tt.id
+			if id == 0 {
+				id, _ = strconv.Atoi(path.Base(w.Header().Get("Location")))
+			}
+			if err := tt.isDeleted(context.Background(), id); err == nil {
+				t.Fatalf("record %d was deleted by a %s", id, tt.method)
+			}
+		})
+	}
+
+	// Deleting still goes through DELETE, and leaves an audit entry
+	if w := asUser(t, h, tokens, 1, "DELETE", "/users/2", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE /users/2: status %d", w.Code)
+	}
+	entries, err := store.Audit.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	deletes := 0
+	for _, e := range entries {
+		if e.Action == models.AuditDelete {
+			deletes++
+		}
+	}
+	if deletes != 1 {
+		t.Fatalf("got %d delete audit entries, want 1", deletes)
+	}
+}
@@ -0,0 +1,75 @@
+package search
+
+import "strings"
+
+// clause is one part of a query: a word, a prefix or a phrase
+type clause struct {
+	// terms has one word, or several for a phrase
+	terms []string
+	// prefix means the last term only has to start the word
+	prefix bool
+}
+
+// span is the position of a match, in tokens: [from, to)
+type span struct {
+	from, to int
+}
+
+// parseQuery splits a query into clauses. Quotes make a phrase (an
+// unclosed quote runs to the end), a trailing * makes a prefix.
+func parseQuery(query string) []clause {
+	var clauses []clause
+	for i, part := range strings.Split(query, `"`) {
+		// Odd parts are between quotes
+		if i%2 == 1 {
+			if terms := words(part); len(terms) > 0 {
+				clauses = append(clauses, clause{terms: terms})
+			}
+			continue
+		}
+		for _, field := range strings.Fields(part) {
+			terms := words(field)
+			if len(terms) == 0 {
+				continue
+			}
+			// "c++" or "e-mail" give several words: search them as a phrase
+			clauses = append(clauses, clause{terms: terms, prefix: strings.HasSuffix(field, "*")})
+		}
+	}
+	return clauses
+}
+
+// words returns the lower-case words of s, tokenized like the documents
+func words(s string) []string {
+	var terms []string
+	for _, t := range tokenize(s) {
+		terms = append(terms, t.term)
+	}
+	return terms
+}
+
+// match returns every place in tokens where the clause matches
+func (c clause) match(tokens []token) []span {
+	var spans []span
+	n := len(c.terms)
+	for i := 0; i+n <= len(tokens); i++ {
+		if c.matchesAt(tokens, i) {
+			spans = append(spans, span{from: i, to: i + n})
+		}
+	}
+	return spans
+}
+
+func (c clause) matchesAt(tokens []token, at int) bool {
+	for j, term := range c.terms {
+		got := tokens[at+j].term
+		if c.prefix && j == len(c.terms)-1 {
+			if !strings.HasPrefix(got, term) {
+				return false
+			}
+		} else if got != term {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,242 @@
+// Package search is a small in-memory full-text index.
+//
+// Documents are made of weighted fields. Queries are a list of clauses
+// that must all match:
+//
+//	golang              a word
+//	gopher*             a word starting with "gopher"
+//	"rest api"          the words next to each other, in that order
+//
+// Results are ranked by TF-IDF, weighted by field, and come with
+// highlighted snippets of the fields that matched.
+package search
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Field is one searchable part of a document
+type Field struct {
+	Name string
+	Text string
+	// Weight multiplies the score of matches in this field
+	Weight float64
+}
+
+// Hit is one matching document
+type Hit struct {
+	ID    int
+	Score float64
+	// Highlights maps field names to snippets of the matches, see Index.Search
+	Highlights map[string]string
+}
+
+// token is one word of a field, with its byte offsets in the field text
+type token struct {
+	term       string
+	start, end int
+}
+
+type document struct {
+	fields []Field
+	tokens [][]token
+}
+
+// Index is an inverted index from words to documents.
+// It is safe for concurrent use.
+type Index struct {
+	mu   sync.RWMutex
+	docs map[int]*document
+	// postings lists the documents containing each term
+	postings map[string]map[int]struct{}
+}
+
+// NewIndex returns an empty index
+func NewIndex() *Index {
+	return &Index{docs: map[int]*document{}, postings: map[string]map[int]struct{}{}}
+}
+
+// Put adds the document with the given ID, replacing any previous version
+func (ix *Index) Put(id int, fields ...Field) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.remove(id)
+	doc := &document{fields: fields, tokens: make([][]token, len(fields))}
+	for i, f := range fields {
+		doc.tokens[i] = tokenize(f.Text)
+		for _, t := range doc.tokens[i] {
+			if ix.postings[t.term] == nil {
+				ix.postings[t.term] = map[int]struct{}{}
+			}
+			ix.postings[t.term][id] = struct{}{}
+		}
+	}
+	ix.docs[id] = doc
+}
+
+// Remove drops the document with the given ID, if it is indexed
+func (ix *Index) Remove(id int) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(id)
+}
+
+// remove drops a document. The caller must hold ix.mu for writing.
+func (ix *Index) remove(id int) {
+	doc, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+	for _, tokens := range doc.tokens {
+		for _, t := range tokens {
+			delete(ix.postings[t.term], id)
+			if len(ix.postings[t.term]) == 0 {
+				delete(ix.postings, t.term)
+			}
+		}
+	}
+	delete(ix.docs, id)
+}
+
+// ErrEmptyQuery is returned for queries without a single word
+var ErrEmptyQuery = errors.New("search query has no words")
+
+// Search returns the documents matching every clause of query, best first.
+// Highlights are HTML-escaped, with matches wrapped in <mark></mark>.
+func (ix *Index) Search(query string) ([]Hit, error) {
+	clauses := parseQuery(query)
+	if len(clauses) == 0 {
+		return nil, ErrEmptyQuery
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	// Candidates: documents having the words of every clause
+	var candidates map[int]struct{}
+	idf := make([]float64, len(clauses))
+	for i, c := range clauses {
+		docs := ix.clauseDocs(c)
+		idf[i] = math.Log(1 + float64(len(ix.docs))/float64(max(len(docs), 1)))
+		if candidates == nil {
+			candidates = docs
+			continue
+		}
+		for id := range candidates {
+			if _, ok := docs[id]; !ok {
+				delete(candidates, id)
+			}
+		}
+	}
+
+	hits := []Hit{}
+	for id := range candidates {
+		if hit, ok := ix.score(id, clauses, idf); ok {
+			hits = append(hits, hit)
+		}
+	}
+	slices.SortFunc(hits, func(a, b Hit) int {
+		if a.Score != b.Score {
+			if a.Score > b.Score {
+				return -1
+			}
+			return 1
+		}
+		return a.ID - b.ID
+	})
+	return hits, nil
+}
+
+// clauseDocs returns the documents that contain every word of c (for a
+// prefix, any word starting with it). Phrases are checked later, in score.
+func (ix *Index) clauseDocs(c clause) map[int]struct{} {
+	var docs map[int]struct{}
+	for i, term := range c.terms {
+		matching := map[int]struct{}{}
+		if c.prefix && i == len(c.terms)-1 {
+			for t, ids := range ix.postings {
+				if strings.HasPrefix(t, term) {
+					for id := range ids {
+						matching[id] = struct{}{}
+					}
+				}
+			}
+		} else {
+			for id := range ix.postings[term] {
+				matching[id] = struct{}{}
+			}
+		}
+
+		if docs == nil {
+			docs = matching
+			continue
+		}
+		for id := range docs {
+			if _, ok := matching[id]; !ok {
+				delete(docs, id)
+			}
+		}
+	}
+	return docs
+}
+
+// score finds where each clause matches document id and turns that into
+// a score and highlights. ok is false when a phrase does not really match.
+func (ix *Index) score(id int, clauses []clause, idf []float64) (Hit, bool) {
+	doc := ix.docs[id]
+	hit := Hit{ID: id, Highlights: map[string]string{}}
+	spans := make([][]span, len(doc.fields))
+
+	for i, c := range clauses {
+		found := false
+		for f, tokens := range doc.tokens {
+			matches := c.match(tokens)
+			if len(matches) == 0 {
+				continue
+			}
+			found = true
+			// Diminishing returns for repeated words, and short fields
+			// matching count for more than long ones
+			tf := 1 + math.Log(float64(len(matches)))
+			norm := 1 / math.Sqrt(float64(len(tokens)))
+			hit.Score += doc.fields[f].Weight * tf * norm * idf[i]
+			spans[f] = append(spans[f], matches...)
+		}
+		if !found {
+			return Hit{}, false
+		}
+	}
+
+	for f, s := range spans {
+		if len(s) > 0 {
+			hit.Highlights[doc.fields[f].Name] = snippet(doc.fields[f].Text, doc.tokens[f], s)
+		}
+	}
+	return hit, true
+}
+
+// tokenize splits text into lower-case words of letters and digits
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start < 0 {
+			start = i
+		}
+		if !isWord && start >= 0 {
+			tokens = append(tokens, token{term: strings.ToLower(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{term: strings.ToLower(text[start:]), start: start, end: len(text)})
+	}
+	return tokens
+}
@@ -0,0 +1,281 @@
+package search
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text string
+		want []token
+	}{
+		{"", nil},
+		{"  ,. ", nil},
+		{"Go", []token{{"go", 0, 2}}},
+		{"Hello, World!", []token{{"hello", 0, 5}, {"world", 7, 12}}},
+		{"REST-API v2", []token{{"rest", 0, 4}, {"api", 5, 8}, {"v2", 9, 11}}},
+		{"c++ and e-mail", []token{{"c", 0, 1}, {"and", 4, 7}, {"e", 8, 9}, {"mail", 10, 14}}},
+		// Offsets are in bytes, so snippets can cut the text at them
+		{"Ünïcode café", []token{{"ünïcode", 0, 9}, {"café", 10, 15}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := tokenize(tt.text); !slices.Equal(got, tt.want) {
+				t.Fatalf("tokenize(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []clause
+	}{
+		{"", nil},
+		{`  "" * `, nil},
+		{"Go", []clause{{terms: []string{"go"}}}},
+		{"go rest", []clause{{terms: []string{"go"}}, {terms: []string{"rest"}}}},
+		{"goph*", []clause{{terms: []string{"goph"}, prefix: true}}},
+		{`"Rest API" go`, []clause{{terms: []string{"rest", "api"}}, {terms: []string{"go"}}}},
+		// An unclosed quote runs to the end
+		{`go "rest api`, []clause{{terms: []string{"go"}}, {terms: []string{"rest", "api"}}}},
+		{"e-mail", []clause{{terms: []string{"e", "mail"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got := parseQuery(tt.query)
+			if !slices.EqualFunc(got, tt.want, func(a, b clause) bool {
+				return a.prefix == b.prefix && slices.Equal(a.terms, b.terms)
+			}) {
+				t.Fatalf("parseQuery(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+// blogIndex indexes documents shaped like blogs: a title weighing 3 and a body weighing 1
+func blogIndex(docs map[int][2]string) *Index {
+	ix := NewIndex()
+	for id, d := range docs {
+		ix.Put(id, Field{Name: "title", Text: d[0], Weight: 3}, Field{Name: "body", Text: d[1], Weight: 1})
+	}
+	return ix
+}
+
+func hitIDs(hits []Hit) []int {
+	var ids []int
+	for _, h := range hits {
+		ids = append(ids, h.ID)
+	}
+	return ids
+}
+
+func TestSearchMatching(t *testing.T) {
+	ix := blogIndex(map[int][2]string{
+		1: {"Go concurrency", "Goroutines and channels in Go."},
+		2: {"REST APIs", "Building a REST API with net/http."},
+		3: {"API design", "Naming a REST resource, then an API."},
+		4: {"Gophers", "All about the gopher mascot."},
+	})
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"go", []int{1}},
+		{"GO", []int{1}},
+		{"rest", []int{2, 3}},
+		{"rest api", []int{2, 3}},
+		{`"rest api"`, []int{2}},
+		{`"api rest"`, nil},
+		{"goph*", []int{4}},
+		{"go*", []int{1, 4}},
+		{"gopher", []int{4}},
+		{"go channels", []int{1}},
+		{"go mascot", nil},
+		{"nothing", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			hits, err := ix.Search(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := hitIDs(hits)
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchEmptyQuery(t *testing.T) {
+	for _, query := range []string{"", "   ", `""`, "*", "!?"} {
+		if _, err := NewIndex().Search(query); !errors.Is(err, ErrEmptyQuery) {
+			t.Errorf("Search(%q) err = %v, want ErrEmptyQuery", query, err)
+		}
+	}
+}
+
+func TestSearchRanking(t *testing.T) {
+	tests := []struct {
+		name  string
+		docs  map[int][2]string
+		query string
+		want  []int
+	}{
+		{
+			name: "title beats body",
+			docs: map[int][2]string{
+				1: {"Cooking", "A note about databases and more."},
+				2: {"Databases", "A note about cooking and more."},
+			},
+			query: "databases",
+			want:  []int{2, 1},
+		},
+		{
+			name: "repeated words score higher",
+			docs: map[int][2]string{
+				1: {"Notes", "sqlite is small, one two three four"},
+				2: {"Notes", "sqlite sqlite sqlite, one two three four"},
+			},
+			query: "sqlite",
+			want:  []int{2, 1},
+		},
+		{
+			name: "short fields beat long ones",
+			docs: map[int][2]string{
+				1: {"Notes", "caching " + strings.Repeat("filler ", 50)},
+				2: {"Notes", "caching tips"},
+			},
+			query: "caching",
+			want:  []int{2, 1},
+		},
+		{
+			name: "rare words count for more",
+			docs: map[int][2]string{
+				1: {"Notes", "common common rare"},
+				2: {"Notes", "common rare rare"},
+				3: {"Notes", "common filler words"},
+				4: {"Notes", "common filler text"},
+			},
+			query: "common rare",
+			want:  []int{2, 1},
+		},
+		{
+			name: "ties go by ID",
+			docs: map[int][2]string{
+				3: {"Same", "same text"},
+				1: {"Same", "same text"},
+				2: {"Same", "same text"},
+			},
+			query: "same",
+			want:  []int{1, 2, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits, err := blogIndex(tt.docs).Search(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hitIDs(hits); !slices.Equal(got, tt.want) {
+				t.Fatalf("Search(%q) ranked %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPutReplacesAndRemoveDrops(t *testing.T) {
+	ix := blogIndex(map[int][2]string{1: {"Old title", "old body"}})
+
+	ix.Put(1, Field{Name: "title", Text: "New title", Weight: 1})
+	if hits, _ := ix.Search("old"); len(hits) != 0 {
+		t.Fatalf("old words still match: %v", hitIDs(hits))
+	}
+	if hits, _ := ix.Search("new"); !slices.Equal(hitIDs(hits), []int{1}) {
+		t.Fatalf("new words do not match: %v", hitIDs(hits))
+	}
+
+	ix.Remove(1)
+	ix.Remove(1)
+	if hits, _ := ix.Search("title"); len(hits) != 0 {
+		t.Fatalf("removed document still matches: %v", hitIDs(hits))
+	}
+	if len(ix.postings) != 0 {
+		t.Fatalf("postings left behind: %v", ix.postings)
+	}
+}
+
+func TestHighlights(t *testing.T) {
+	long := strings.Repeat("word ", 20) + "needle " + strings.Repeat("more ", 40)
+	tests := []struct {
+		name  string
+		field string
+		query string
+		want  string
+	}{
+		{
+			name:  "marks every match",
+			field: "Go is fun. Go go!",
+			query: "go",
+			want:  "<mark>Go</mark> is fun. <mark>Go</mark> <mark>go</mark>!",
+		},
+		{
+			name:  "marks a phrase as one",
+			field: "Build a REST API today",
+			query: `"rest api"`,
+			want:  "Build a <mark>REST API</mark> today",
+		},
+		{
+			name:  "marks the whole word of a prefix",
+			field: "Gophers everywhere",
+			query: "goph*",
+			want:  "<mark>Gophers</mark> everywhere",
+		},
+		{
+			name:  "escapes HTML",
+			field: `<script>alert("x")</script> & tag`,
+			query: "tag",
+			want:  "&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt; &amp; <mark>tag</mark>",
+		},
+		{
+			name:  "cuts long fields around the first match",
+			field: long,
+			query: "needle",
+			want: "…" + strings.Repeat("word ", SNIPPET_BEFORE) + "<mark>needle</mark> " +
+				strings.TrimSuffix(strings.Repeat("more ", SNIPPET_AFTER), " ") + "…",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ix := NewIndex()
+			ix.Put(1, Field{Name: "body", Text: tt.field, Weight: 1})
+			hits, err := ix.Search(tt.query)
+			if err != nil || len(hits) != 1 {
+				t.Fatalf("Search(%q) = %v, %v", tt.query, hits, err)
+			}
+			if got := hits[0].Highlights["body"]; got != tt.want {
+				t.Fatalf("highlight\n got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHighlightsOnlyMatchingFields(t *testing.T) {
+	ix := blogIndex(map[int][2]string{1: {"Go tips", "Nothing here"}})
+	hits, _ := ix.Search("tips")
+	if len(hits) != 1 {
+		t.Fatalf("got %d hits", len(hits))
+	}
+	if _, ok := hits[0].Highlights["body"]; ok {
+		t.Errorf("body was highlighted without a match: %v", hits[0].Highlights)
+	}
+	if hits[0].Highlights["title"] != "Go <mark>tips</mark>" {
+		t.Errorf("title highlight = %q", hits[0].Highlights["title"])
+	}
+}
@@ -0,0 +1,56 @@
+package search
+
+import (
+	"html"
+	"slices"
+	"strings"
+)
+
+// Words of context kept around the first match of a long field
+const (
+	SNIPPET_BEFORE = 8
+	SNIPPET_AFTER  = 24
+)
+
+// snippet returns the part of text around the matches in spans, escaped
+// for HTML, with each match wrapped in <mark></mark>. Text cut off at
+// either end is marked with "…".
+func snippet(text string, tokens []token, spans []span) string {
+	slices.SortFunc(spans, func(a, b span) int { return a.from - b.from })
+
+	// The window of tokens to show, around the first match
+	from := max(spans[0].from-SNIPPET_BEFORE, 0)
+	to := min(spans[0].to+SNIPPET_AFTER, len(tokens))
+
+	var b strings.Builder
+	start := 0
+	if from > 0 {
+		b.WriteString("…")
+		start = tokens[from].start
+	}
+	end := len(text)
+	if to < len(tokens) {
+		end = tokens[to-1].end
+	}
+
+	pos := start
+	for _, s := range spans {
+		if s.from < from || s.to > to {
+			continue
+		}
+		matchStart, matchEnd := tokens[s.from].start, tokens[s.to-1].end
+		if matchStart < pos {
+			continue // overlaps the previous match
+		}
+		b.WriteString(html.EscapeString(text[pos:matchStart]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[matchStart:matchEnd]))
+		b.WriteString("</mark>")
+		pos = matchEnd
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
@@ -0,0 +1,55 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// SIGNATURE_PREFIX names the algorithm in front of the signature
+const SIGNATURE_PREFIX = "sha256="
+
+// ErrInvalidSignature is returned by Verify for a request that was not
+// signed with the secret, or was signed too long ago
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Sign returns the SIGNATURE_HEADER of a delivery: "sha256=" and the hex
+// HMAC-SHA256, keyed with secret, of the timestamp, a dot and the body.
+// Signing the timestamp too keeps old deliveries from being replayed.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return SIGNATURE_PREFIX + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature headers of a delivery received with body,
+// for receivers written in Go. Deliveries sent more than tolerance ago
+// (or in the future) are rejected; 0 accepts any age.
+func Verify(secret string, header http.Header, body []byte, tolerance time.Duration) error {
+	timestamp := header.Get(TIMESTAMP_HEADER)
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if age := time.Since(time.Unix(sent, 0)); tolerance > 0 && (age > tolerance || age < -tolerance) {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(header.Get(SIGNATURE_HEADER)), []byte(Sign(secret, timestamp, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// NewSecret returns a random signing secret
+func NewSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return "whsec_" + hex.EncodeToString(b)
+}
@@ -0,0 +1,459 @@
+// Package webhooks POSTs events to the URLs partners subscribed them to.
+//
+// The Dispatcher follows the events.Broadcaster like any other
+// subscriber. Every event matching a webhook becomes a delivery in the
+// log, which a pool of workers then sends. Failed deliveries are tried
+// again with exponential backoff; after the last attempt they are marked
+// dead and stay in the dead-letter list until retried by hand.
+//
+// Deliveries are kept in the repository, so those still pending when the
+// server stops are sent after the next start.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/manish-npx/go-lang/go-rest/events"
+	"github.com/manish-npx/go-lang/go-rest/models"
+	"github.com/manish-npx/go-lang/go-rest/repository"
+)
+
+// Delivery defaults, see Options
+const (
+	DEFAULT_WORKERS      = 4
+	DEFAULT_MAX_ATTEMPTS = 8
+	DEFAULT_MIN_BACKOFF  = 5 * time.Second
+	DEFAULT_MAX_BACKOFF  = time.Hour
+	// DEFAULT_TIMEOUT bounds each attempt, from connecting to reading the answer
+	DEFAULT_TIMEOUT       = 10 * time.Second
+	DEFAULT_POLL_INTERVAL = time.Second
+	// MAX_RESPONSE_BYTES is how much of an answer is read before it is dropped
+	MAX_RESPONSE_BYTES = 64 << 10
+)
+
+// Headers sent with every delivery
+const (
+	// EVENT_HEADER holds the event name, e.g. "users.created"
+	EVENT_HEADER = "X-Webhook-Event"
+	// DELIVERY_HEADER holds the delivery ID. It is the same on every
+	// attempt, so receivers can skip what they already handled.
+	DELIVERY_HEADER = "X-Webhook-Delivery"
+	// TIMESTAMP_HEADER holds when the attempt was sent, in Unix seconds
+	TIMESTAMP_HEADER = "X-Webhook-Timestamp"
+	// SIGNATURE_HEADER holds the signature of the attempt, see Sign
+	SIGNATURE_HEADER = "X-Webhook-Signature"
+	USER_AGENT       = "go-rest-webhooks/1.0"
+)
+
+// ErrNotDead is returned by Redeliver for a delivery that is not in the
+// dead-letter list
+var ErrNotDead = errors.New("delivery is not dead")
+
+// Options tune a Dispatcher. Zero fields take the defaults above.
+type Options struct {
+	// HTTPClient sends the deliveries. The default one times out after
+	// DEFAULT_TIMEOUT and does not follow redirects.
+	HTTPClient *http.Client
+	// Workers is how many deliveries are sent at the same time
+	Workers int
+	// MaxAttempts is how many times a delivery is tried before it is dead
+	MaxAttempts int
+	// The wait before a retry starts at MinBackoff and doubles after
+	// every failed attempt, up to MaxBackoff
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// PollInterval is how often due retries are looked for
+	PollInterval time.Duration
+}
+
+// Dispatcher turns events into deliveries and sends them
+type Dispatcher struct {
+	hooks      repository.WebhookRepository
+	deliveries repository.WebhookDeliveryRepository
+	opts       Options
+
+	// jobs hands the IDs of due deliveries to the workers
+	jobs chan int
+	// wake makes the scheduler look for due deliveries right away
+	wake chan struct{}
+
+	mu sync.Mutex
+	// inFlight holds the deliveries handed to a worker and not done yet,
+	// so the scheduler does not hand them out twice
+	inFlight map[int]bool
+}
+
+// NewDispatcher returns a Dispatcher sending to the webhooks of hooks
+// and logging to deliveries. Nothing is sent until Run is called.
+func NewDispatcher(hooks repository.WebhookRepository, deliveries repository.WebhookDeliveryRepository, opts Options) *Dispatcher {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{
+			Timeout: DEFAULT_TIMEOUT,
+			// A redirected POST turns into a GET: count it as a failure instead
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		}
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = DEFAULT_WORKERS
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DEFAULT_MAX_ATTEMPTS
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = DEFAULT_MIN_BACKOFF
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DEFAULT_MAX_BACKOFF
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DEFAULT_POLL_INTERVAL
+	}
+	return &Dispatcher{
+		hooks:      hooks,
+		deliveries: deliveries,
+		opts:       opts,
+		jobs:       make(chan int),
+		wake:       make(chan struct{}, 1),
+		inFlight:   map[int]bool{},
+	}
+}
+
+// Run delivers the events published to broadcaster until ctx is done,
+// then waits for the attempts under way. An attempt cut short this way
+// does not count: the delivery stays pending.
+func (d *Dispatcher) Run(ctx context.Context, broadcaster *events.Broadcaster) {
+	var wg sync.WaitGroup
+	for range d.opts.Workers {
+		wg.Go(func() { d.work(ctx) })
+	}
+	wg.Go(func() { d.listen(ctx, broadcaster) })
+	d.schedule(ctx)
+	wg.Wait()
+}
+
+// Redeliver moves a dead delivery back to pending, with all its
+// attempts ahead of it, and returns it
+func (d *Dispatcher) Redeliver(ctx context.Context, id int) (models.WebhookDelivery, error) {
+	delivery, err := d.deliveries.Get(ctx, id)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	if delivery.Status != models.DeliveryDead {
+		return models.WebhookDelivery{}, ErrNotDead
+	}
+
+	now := time.Now().UTC()
+	delivery.Status = models.DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = &now
+	if err := d.deliveries.Update(ctx, &delivery); err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	d.signal()
+	return delivery, nil
+}
+
+// listen turns the events of broadcaster into deliveries, starting with
+// those already in its log. If it falls so far behind that the
+// broadcaster drops it, it subscribes again from the last event it saw.
+//
+// The broadcaster is closed as soon as the server starts shutting down,
+// while the requests it drains still publish events: those are read from
+// the log until ctx is done, and once more after.
+func (d *Dispatcher) listen(ctx context.Context, broadcaster *events.Broadcaster) {
+	lastID := int64(0)
+	for ctx.Err() == nil && !broadcaster.Closed() {
+		sub, backlog, complete := broadcaster.Subscribe(nil, lastID)
+		if !complete {
+			slog.WarnContext(ctx, "webhooks missed events that left the event log", "after_id", lastID)
+		}
+		for _, e := range backlog {
+			d.enqueue(ctx, e)
+			lastID = e.ID
+		}
+		lastID = d.follow(ctx, sub, lastID)
+		sub.Close()
+	}
+
+	ticker := time.NewTicker(d.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// Logged as pending, to be sent after the next start
+			d.catchUp(context.WithoutCancel(ctx), broadcaster, lastID)
+			return
+		case <-ticker.C:
+			lastID = d.catchUp(ctx, broadcaster, lastID)
+		}
+	}
+}
+
+// catchUp enqueues the events after lastID in the log of broadcaster and
+// returns the ID of the last one
+func (d *Dispatcher) catchUp(ctx context.Context, broadcaster *events.Broadcaster, lastID int64) int64 {
+	backlog, complete := broadcaster.Since(lastID)
+	if !complete {
+		slog.WarnContext(ctx, "webhooks missed events that left the event log", "after_id", lastID)
+	}
+	for _, e := range backlog {
+		d.enqueue(ctx, e)
+		lastID = e.ID
+	}
+	return lastID
+}
+
+// follow enqueues the events of sub until it ends or ctx is done, and
+// returns the ID of the last one
+func (d *Dispatcher) follow(ctx context.Context, sub *events.Subscription, lastID int64) int64 {
+	for {
+		select {
+		case <-ctx.Done():
+			return lastID
+		case e, ok := <-sub.C:
+			if !ok {
+				return lastID
+			}
+			d.enqueue(ctx, e)
+			lastID = e.ID
+		}
+	}
+}
+
+// enqueue logs a pending delivery of e for every active webhook it matches
+func (d *Dispatcher) enqueue(ctx context.Context, e events.Event) {
+	hooks, err := d.hooks.List(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "webhooks not loaded, event not delivered", "error", err, "event_id", e.ID)
+		return
+	}
+
+	var payload []byte
+	for _, hook := range hooks {
+		if !hook.Active || !Matches(hook.Events, e.Name()) {
+			continue
+		}
+		if payload == nil {
+			if payload, err = json.Marshal(e); err != nil {
+				slog.ErrorContext(ctx, "event not encoded for webhooks", "error", err, "event_id", e.ID)
+				return
+			}
+		}
+		now := time.Now().UTC()
+		delivery := models.WebhookDelivery{
+			WebhookID:     hook.ID,
+			EventID:       e.ID,
+			Event:         e.Name(),
+			Payload:       payload,
+			Status:        models.DeliveryPending,
+			CreatedAt:     now,
+			NextAttemptAt: &now,
+		}
+		if err := d.deliveries.Create(ctx, &delivery); err != nil {
+			slog.ErrorContext(ctx, "webhook delivery lost", "error", err, "webhook_id", hook.ID, "event_id", e.ID)
+		}
+	}
+	d.signal()
+}
+
+// signal wakes the scheduler up, unless it is already due to wake up
+func (d *Dispatcher) signal() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// schedule hands the due deliveries to the workers, whenever one was
+// added and every PollInterval for the retries
+func (d *Dispatcher) schedule(ctx context.Context) {
+	ticker := time.NewTicker(d.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		d.dispatchDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+func (d *Dispatcher) dispatchDue(ctx context.Context) {
+	pending, err := d.deliveries.ListByStatus(ctx, models.DeliveryPending)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.ErrorContext(ctx, "pending webhook deliveries not loaded", "error", err)
+		}
+		return
+	}
+
+	now := time.Now()
+	for _, delivery := range pending {
+		if delivery.NextAttemptAt != nil && delivery.NextAttemptAt.After(now) {
+			continue
+		}
+		if !d.claim(delivery.ID) {
+			continue
+		}
+		select {
+		case d.jobs <- delivery.ID:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// claim marks a delivery in flight, or returns false if it already is
+func (d *Dispatcher) claim(id int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.inFlight[id] {
+		return false
+	}
+	d.inFlight[id] = true
+	return true
+}
+
+func (d *Dispatcher) release(id int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.inFlight, id)
+}
+
+func (d *Dispatcher) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-d.jobs:
+			d.attempt(ctx, id)
+			d.release(id)
+		}
+	}
+}
+
+// attempt sends a delivery once and records the outcome: succeeded,
+// pending with the time of the next attempt, or dead
+func (d *Dispatcher) attempt(ctx context.Context, id int) {
+	delivery, err := d.deliveries.Get(ctx, id)
+	if err != nil || delivery.Status != models.DeliveryPending {
+		return
+	}
+
+	hook, err := d.hooks.Get(ctx, delivery.WebhookID)
+	gone := err != nil
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		err = errors.New("webhook was deleted")
+	case err != nil:
+		// Tried again at the next poll
+		slog.ErrorContext(ctx, "webhook not loaded", "error", err, "webhook_id", delivery.WebhookID)
+		return
+	case !hook.Active:
+		gone, err = true, errors.New("webhook is disabled")
+	default:
+		now := time.Now().UTC()
+		delivery.ResponseStatus, err = d.post(ctx, hook, delivery)
+		if ctx.Err() != nil {
+			// Shutting down: sent again after the next start
+			return
+		}
+		delivery.Attempts++
+		delivery.LastAttemptAt = &now
+	}
+
+	delivery.NextAttemptAt = nil
+	delivery.Error = ""
+	switch {
+	case err == nil:
+		delivery.Status = models.DeliverySucceeded
+	case gone || delivery.Attempts >= d.opts.MaxAttempts:
+		delivery.Status = models.DeliveryDead
+		delivery.Error = err.Error()
+		slog.WarnContext(ctx, "webhook delivery dead", "delivery_id", delivery.ID, "webhook_id", delivery.WebhookID,
+			"attempts", delivery.Attempts, "error", err)
+	default:
+		next := time.Now().UTC().Add(d.backoff(delivery.Attempts))
+		delivery.NextAttemptAt = &next
+		delivery.Error = err.Error()
+	}
+	if err := d.deliveries.Update(ctx, &delivery); err != nil {
+		slog.ErrorContext(ctx, "webhook delivery outcome lost", "error", err, "delivery_id", delivery.ID)
+	}
+}
+
+// post sends delivery to hook and returns the status of the answer.
+// Anything but a 2xx is an error.
+func (d *Dispatcher) post(ctx context.Context, hook models.Webhook, delivery models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", USER_AGENT)
+	req.Header.Set(EVENT_HEADER, delivery.Event)
+	req.Header.Set(DELIVERY_HEADER, strconv.Itoa(delivery.ID))
+	req.Header.Set(TIMESTAMP_HEADER, timestamp)
+	req.Header.Set(SIGNATURE_HEADER, Sign(hook.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.opts.HTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Reading the answer lets the connection be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, MAX_RESPONSE_BYTES))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the wait after the attempt-th failed attempt:
+// MinBackoff doubled for every earlier failure, capped at MaxBackoff,
+// with jitter so the retries of many deliveries spread out
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	wait := d.opts.MinBackoff << min(attempt-1, 30)
+	if wait <= 0 || wait > d.opts.MaxBackoff {
+		wait = d.opts.MaxBackoff
+	}
+	return wait/2 + rand.N(wait/2+1)
+}
+
+// Matches reports whether the event called name is one of patterns:
+// the name itself, "topic.*" for its topic, or "*"
+func Matches(patterns []string, name string) bool {
+	topic, _, _ := strings.Cut(name, ".")
+	for _, p := range patterns {
+		if p == "*" || p == name || p == topic+".*" {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidPattern reports whether pattern can match events, see Matches
+func ValidPattern(pattern string) bool {
+	if pattern == "*" {
+		return true
+	}
+	topic, typ, ok := strings.Cut(pattern, ".")
+	return ok && events.ValidTopic(topic) && (typ == "*" || slices.Contains(events.Types, typ))
+}
@@ -0,0 +1,383 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/manish-npx/go-lang/go-rest/events"
+	"github.com/manish-npx/go-lang/go-rest/models"
+	"github.com/manish-npx/go-lang/go-rest/repository"
+)
+
+const testSecret = "whsec_test"
+
+// received is one request that reached a receiver
+type received struct {
+	at     time.Time
+	header http.Header
+	body   []byte
+}
+
+// receiver is a webhook endpoint answering with the statuses of answers
+// in turn, then 200
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	answers  []int
+	requests []received
+}
+
+func newReceiver(t *testing.T, answers ...int) *receiver {
+	t.Helper()
+	rc := &receiver{answers: answers}
+	rc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rc.mu.Lock()
+		defer rc.mu.Unlock()
+		rc.requests = append(rc.requests, received{time.Now(), r.Header.Clone(), body})
+		status := http.StatusOK
+		if len(rc.answers) > 0 {
+			status, rc.answers = rc.answers[0], rc.answers[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(rc.Close)
+	return rc
+}
+
+func (rc *receiver) received() []received {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]received(nil), rc.requests...)
+}
+
+// testDispatcher runs a dispatcher on an in-memory store
+type testDispatcher struct {
+	*Dispatcher
+	store       *repository.Store
+	broadcaster *events.Broadcaster
+}
+
+// startDispatcher runs a dispatcher with opts, quick to poll, until the
+// end of the test
+func startDispatcher(t *testing.T, opts Options) *testDispatcher {
+	t.Helper()
+	if opts.PollInterval == 0 {
+		opts.PollInterval = 5 * time.Millisecond
+	}
+	store := repository.NewMemoryStore()
+	td := &testDispatcher{
+		Dispatcher:  NewDispatcher(store.Webhooks, store.WebhookDeliveries, opts),
+		store:       store,
+		broadcaster: events.NewBroadcaster(events.DEFAULT_LOG_SIZE),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		td.Run(ctx, td.broadcaster)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return td
+}
+
+func (td *testDispatcher) addHook(t *testing.T, url string, patterns ...string) models.Webhook {
+	t.Helper()
+	hook := models.Webhook{URL: url, Events: patterns, Secret: testSecret, Active: true}
+	if err := td.store.Webhooks.Create(context.Background(), &hook); err != nil {
+		t.Fatal(err)
+	}
+	return hook
+}
+
+// waitForDelivery waits until the only delivery of hook has status
+func (td *testDispatcher) waitForDelivery(t *testing.T, hook models.Webhook, status string) models.WebhookDelivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		deliveries, err := td.store.WebhookDeliveries.ListByWebhook(context.Background(), hook.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deliveries) > 1 {
+			t.Fatalf("%d deliveries, want 1", len(deliveries))
+		}
+		if len(deliveries) == 1 && deliveries[0].Status == status {
+			return deliveries[0]
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no %s delivery, have %+v", status, deliveries)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDeliveriesAreSigned(t *testing.T) {
+	rc := newReceiver(t)
+	td := startDispatcher(t, Options{})
+	hook := td.addHook(t, rc.URL, "blogs.*")
+	e := td.broadcaster.Publish(events.TopicBlogs, events.Created, 7, map[string]string{"title": "Hello"})
+
+	delivery := td.waitForDelivery(t, hook, models.DeliverySucceeded)
+	reqs := rc.received()
+	if len(reqs) != 1 {
+		t.Fatalf("receiver got %d requests, want 1", len(reqs))
+	}
+	req := reqs[0]
+
+	if err := Verify(testSecret, req.header, req.body, time.Minute); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if err := Verify("whsec_other", req.header, req.body, time.Minute); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("Verify with another secret: %v", err)
+	}
+	if sig := req.header.Get(SIGNATURE_HEADER); sig != Sign(testSecret, req.header.Get(TIMESTAMP_HEADER), req.body) {
+		t.Fatalf("signature %q is not the HMAC-SHA256 of timestamp.body", sig)
+	}
+	for name, want := range map[string]string{
+		EVENT_HEADER:    "blogs.created",
+		DELIVERY_HEADER: "1",
+		"Content-Type":  "application/json",
+		"User-Agent":    USER_AGENT,
+	} {
+		if got := req.header.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	var sent events.Event
+	if err := json.Unmarshal(req.body, &sent); err != nil || sent.ID != e.ID || sent.ResourceID != 7 {
+		t.Fatalf("payload %s, %v", req.body, err)
+	}
+
+	// The log records the attempt
+	if delivery.Attempts != 1 || delivery.ResponseStatus != 200 || delivery.Error != "" ||
+		delivery.EventID != e.ID || delivery.Event != "blogs.created" ||
+		delivery.LastAttemptAt == nil || delivery.NextAttemptAt != nil {
+		t.Fatalf("delivery %+v", delivery)
+	}
+	if string(delivery.Payload) != string(req.body) {
+		t.Fatalf("logged payload %s, sent %s", delivery.Payload, req.body)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	signed := func(age time.Duration) http.Header {
+		timestamp := time.Now().Add(-age).Unix()
+		h := http.Header{}
+		h.Set(TIMESTAMP_HEADER, strconv.FormatInt(timestamp, 10))
+		h.Set(SIGNATURE_HEADER, Sign(testSecret, h.Get(TIMESTAMP_HEADER), body))
+		return h
+	}
+
+	tests := []struct {
+		name      string
+		header    http.Header
+		body      string
+		tolerance time.Duration
+		ok        bool
+	}{
+		{"valid", signed(0), string(body), time.Minute, true},
+		{"other body", signed(0), `{"id":2}`, time.Minute, false},
+		{"too old", signed(2 * time.Minute), string(body), time.Minute, false},
+		{"in the future", signed(-2 * time.Minute), string(body), time.Minute, false},
+		{"any age", signed(24 * time.Hour), string(body), 0, true},
+		{"no timestamp", http.Header{SIGNATURE_HEADER: {Sign(testSecret, "", body)}}, string(body), 0, false},
+		{"no signature", http.Header{TIMESTAMP_HEADER: {"1"}}, string(body), 0, false},
+	}
+	for _, tt := range tests {
+		err := Verify(testSecret, tt.header, []byte(tt.body), tt.tolerance)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: Verify = %v", tt.name, err)
+		}
+	}
+}
+
+func TestRetriesBackOff(t *testing.T) {
+	const minBackoff = 40 * time.Millisecond
+	rc := newReceiver(t, 500, 503)
+	td := startDispatcher(t, Options{MinBackoff: minBackoff, MaxBackoff: time.Second})
+	hook := td.addHook(t, rc.URL, "*")
+	td.broadcaster.Publish(events.TopicUsers, events.Updated, 1, nil)
+
+	delivery := td.waitForDelivery(t, hook, models.DeliverySucceeded)
+	reqs := rc.received()
+	if len(reqs) != 3 || delivery.Attempts != 3 || delivery.ResponseStatus != 200 {
+		t.Fatalf("%d requests, delivery %+v", len(reqs), delivery)
+	}
+	// Waits of at least half of MinBackoff, then of MinBackoff
+	for i, least := range []time.Duration{minBackoff / 2, minBackoff} {
+		if wait := reqs[i+1].at.Sub(reqs[i].at); wait < least {
+			t.Errorf("retry %d came after %v, want at least %v", i+1, wait, least)
+		}
+	}
+	for _, req := range reqs {
+		if req.header.Get(DELIVERY_HEADER) != reqs[0].header.Get(DELIVERY_HEADER) {
+			t.Fatal("retries changed the delivery ID")
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(nil, nil, Options{MinBackoff: time.Second, MaxBackoff: 10 * time.Second})
+	for attempt, limit := range map[int]time.Duration{1: 1, 2: 2, 3: 4, 4: 8, 5: 10, 50: 10} {
+		limit *= time.Second
+		for range 20 {
+			if wait := d.backoff(attempt); wait < limit/2 || wait > limit {
+				t.Fatalf("backoff(%d) = %v, want between %v and %v", attempt, wait, limit/2, limit)
+			}
+		}
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	rc := newReceiver(t, 503, 503, 503)
+	td := startDispatcher(t, Options{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+	hook := td.addHook(t, rc.URL, "comments.created")
+	td.broadcaster.Publish(events.TopicComments, events.Created, 1, nil)
+
+	dead := td.waitForDelivery(t, hook, models.DeliveryDead)
+	if dead.Attempts != 3 || dead.ResponseStatus != 503 || !strings.Contains(dead.Error, "503") || dead.NextAttemptAt != nil {
+		t.Fatalf("dead delivery %+v", dead)
+	}
+	// Nothing more is sent on its own
+	time.Sleep(30 * time.Millisecond)
+	if n := len(rc.received()); n != 3 {
+		t.Fatalf("receiver got %d requests, want 3", n)
+	}
+
+	// Retrying by hand gives it all its attempts again
+	ctx := context.Background()
+	if _, err := td.Redeliver(ctx, dead.ID); err != nil {
+		t.Fatal(err)
+	}
+	succeeded := td.waitForDelivery(t, hook, models.DeliverySucceeded)
+	if succeeded.Attempts != 1 {
+		t.Fatalf("redelivered %+v", succeeded)
+	}
+	if _, err := td.Redeliver(ctx, dead.ID); !errors.Is(err, ErrNotDead) {
+		t.Fatalf("Redeliver of a succeeded delivery: %v", err)
+	}
+}
+
+func TestGoneWebhooksAreNotRetried(t *testing.T) {
+	// The receiver fails the first attempt, then the hook is disabled
+	rc := newReceiver(t, 500)
+	// A long backoff: only the first attempt can happen on its own
+	td := startDispatcher(t, Options{MinBackoff: time.Hour})
+	ctx := context.Background()
+
+	disabled := td.addHook(t, rc.URL, "users.*")
+	td.broadcaster.Publish(events.TopicUsers, events.Created, 1, nil)
+	pending := td.waitForDelivery(t, disabled, models.DeliveryPending)
+	for pending.Attempts == 0 {
+		pending = td.waitForDelivery(t, disabled, models.DeliveryPending)
+	}
+	disabled.Active = false
+	if err := td.store.Webhooks.Update(ctx, &disabled); err != nil {
+		t.Fatal(err)
+	}
+	// Due now
+	now := time.Now().UTC()
+	pending.NextAttemptAt = &now
+	td.store.WebhookDeliveries.Update(ctx, &pending)
+
+	dead := td.waitForDelivery(t, disabled, models.DeliveryDead)
+	if dead.Error != "webhook is disabled" || dead.Attempts != 1 {
+		t.Fatalf("delivery %+v", dead)
+	}
+	if n := len(rc.received()); n != 1 {
+		t.Fatalf("receiver got %d requests, want 1", n)
+	}
+}
+
+func TestOnlyMatchingActiveWebhooksGetDeliveries(t *testing.T) {
+	rc := newReceiver(t)
+	td := startDispatcher(t, Options{})
+	ctx := context.Background()
+
+	blogs := td.addHook(t, rc.URL, "blogs.*")
+	users := td.addHook(t, rc.URL, "users.deleted")
+	inactive := td.addHook(t, rc.URL, "*")
+	inactive.Active = false
+	td.store.Webhooks.Update(ctx, &inactive)
+
+	td.broadcaster.Publish(events.TopicUsers, events.Created, 1, nil)
+	td.broadcaster.Publish(events.TopicBlogs, events.Deleted, 1, nil)
+
+	td.waitForDelivery(t, blogs, models.DeliverySucceeded)
+	for _, hook := range []models.Webhook{users, inactive} {
+		if deliveries, _ := td.store.WebhookDeliveries.ListByWebhook(ctx, hook.ID); len(deliveries) != 0 {
+			t.Errorf("webhook for %v got %+v", hook.Events, deliveries)
+		}
+	}
+}
+
+func TestEventsPublishedDuringShutdownAreKept(t *testing.T) {
+	store := repository.NewMemoryStore()
+	broadcaster := events.NewBroadcaster(events.DEFAULT_LOG_SIZE)
+	// The receiver is never reached: every delivery stays pending
+	d := NewDispatcher(store.Webhooks, store.WebhookDeliveries, Options{PollInterval: time.Hour})
+	ctx := context.Background()
+	hook := models.Webhook{URL: "http://127.0.0.1:1/hook", Events: []string{"*"}, Secret: testSecret, Active: true}
+	store.Webhooks.Create(ctx, &hook)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		d.Run(runCtx, broadcaster)
+		close(done)
+	}()
+
+	// The server closes the broadcaster when it starts shutting down,
+	// then the requests it drains publish their events
+	broadcaster.Close()
+	e := broadcaster.Publish(events.TopicBlogs, events.Updated, 3, nil)
+	cancel()
+	<-done
+
+	deliveries, err := store.WebhookDeliveries.ListByWebhook(ctx, hook.ID)
+	if err != nil || len(deliveries) != 1 || deliveries[0].EventID != e.ID || deliveries[0].Status != models.DeliveryPending {
+		t.Fatalf("deliveries %+v, %v; want the event logged as pending", deliveries, err)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		name     string
+		want     bool
+	}{
+		{[]string{"*"}, "users.created", true},
+		{[]string{"users.created"}, "users.created", true},
+		{[]string{"users.*"}, "users.deleted", true},
+		{[]string{"users.*"}, "blogs.deleted", false},
+		{[]string{"users.created"}, "users.updated", false},
+		{[]string{"blogs.*", "users.updated"}, "users.updated", true},
+		{nil, "users.updated", false},
+	}
+	for _, tt := range tests {
+		if got := Matches(tt.patterns, tt.name); got != tt.want {
+			t.Errorf("Matches(%v, %q) = %v, want %v", tt.patterns, tt.name, got, tt.want)
+		}
+	}
+	for pattern, want := range map[string]bool{"*": true, "blogs.*": true, "users.restored": true, "users": false, "users.sent": false, "pets.*": false} {
+		if got := ValidPattern(pattern); got != want {
+			t.Errorf("ValidPattern(%q) = %v, want %v", pattern, got, want)
+		}
+	}
+}